@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +kubebuilder:skip
+package v1alpha3
+
+import (
+	"bufio"
+	"bytes"
+
+	"github.com/gogo/protobuf/jsonpb"
+
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SidecarSpec defines the desired state of Sidecar
+type SidecarSpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+	istiov1alpha3.Sidecar `json:",inline"`
+}
+
+// SidecarStatus defines the observed state of Sidecar
+type SidecarStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+// +kubebuilder:object:root=true
+
+// Sidecar is the Schema for the sidecars API
+type Sidecar struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SidecarSpec   `json:"spec,omitempty"`
+	Status SidecarStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SidecarList contains a list of Sidecar
+type SidecarList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Sidecar `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Sidecar{}, &SidecarList{})
+}
+
+func (p *SidecarSpec) MarshalJSON() ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := bufio.NewWriter(&buffer)
+	marshaler := jsonpb.Marshaler{}
+	err := marshaler.Marshal(writer, &p.Sidecar)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), nil
+}
+
+func (p *SidecarSpec) UnmarshalJSON(b []byte) error {
+	reader := bytes.NewReader(b)
+	unmarshaler := jsonpb.Unmarshaler{}
+	err := unmarshaler.Unmarshal(reader, &p.Sidecar)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 contains API Schema definitions for the networking v1alpha1 API group
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Protocols of SecurityGroup rules
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+	ProtocolAll = "all"
+)
+
+// SecurityGroupSpec defines the desired state of SecurityGroup
+type SecurityGroupSpec struct {
+	// Rules allow egress from the apps the security group is bound to
+	Rules []SecurityGroupRule `json:"rules"`
+
+	// Apps in the orgs or spaces the security group is bound to only reach
+	// what the rules of their security groups allow, other apps, DNS and the
+	// mesh. Apps of other orgs and spaces are not restricted unless default
+	// deny is enabled.
+	// +optional
+	Bindings SecurityGroupBindings `json:"bindings,omitempty"`
+}
+
+type SecurityGroupRule struct {
+	// +kubebuilder:validation:Enum=tcp;udp;all
+	Protocol string `json:"protocol"`
+
+	// CIDR of the destinations the rule allows
+	// +kubebuilder:validation:Format=cidr
+	Destination string `json:"destination"`
+
+	// Ports the rule allows, all ports when empty
+	// +kubebuilder:validation:MaxItems=100
+	// +optional
+	Ports []int32 `json:"ports,omitempty"`
+}
+
+type SecurityGroupBindings struct {
+	// Guids of the orgs whose apps the security group is bound to, matched
+	// with the cloudfoundry.org/org_guid label of their pods
+	// +optional
+	Orgs []string `json:"orgs,omitempty"`
+
+	// Guids of the spaces whose apps the security group is bound to, matched
+	// with the cloudfoundry.org/space_guid label of their pods
+	// +optional
+	Spaces []string `json:"spaces,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// SecurityGroup allows apps in the orgs and spaces it is bound to egress to
+// destinations outside the cluster, like an Application Security Group
+type SecurityGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SecurityGroupSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SecurityGroupList contains a list of SecurityGroup
+type SecurityGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SecurityGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SecurityGroup{}, &SecurityGroupList{})
+}
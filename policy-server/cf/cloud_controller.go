@@ -0,0 +1,82 @@
+package cf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CloudControllerClient looks up apps and roles with the Cloud Controller V3
+// API, using the access token of the user the lookup is for
+type CloudControllerClient struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// IsSpaceDeveloper is true when the user is a space developer in the space of
+// the app. Apps the user cannot see are treated like apps of other spaces.
+func (c *CloudControllerClient) IsSpaceDeveloper(ctx context.Context, token, userID, appGUID string) (bool, error) {
+	app := struct {
+		Relationships struct {
+			Space struct {
+				Data struct {
+					GUID string `json:"guid"`
+				} `json:"data"`
+			} `json:"space"`
+		} `json:"relationships"`
+	}{}
+	found, err := c.get(ctx, token, "/v3/apps/"+url.PathEscape(appGUID), nil, &app)
+	if err != nil || !found {
+		return false, err
+	}
+
+	roles := struct {
+		Pagination struct {
+			TotalResults int `json:"total_results"`
+		} `json:"pagination"`
+	}{}
+	query := url.Values{
+		"types":       {"space_developer"},
+		"space_guids": {app.Relationships.Space.Data.GUID},
+		"user_guids":  {userID},
+	}
+	if _, err := c.get(ctx, token, "/v3/roles", query, &roles); err != nil {
+		return false, err
+	}
+	return roles.Pagination.TotalResults > 0, nil
+}
+
+// get returns false when the resource was not found
+func (c *CloudControllerClient) get(ctx context.Context, token, path string, query url.Values, body interface{}) (bool, error) {
+	target := strings.TrimSuffix(c.URL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "bearer "+token)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("cloud controller responded to %s with status %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(body); err != nil {
+		return false, fmt.Errorf("invalid cloud controller response to %s: %s", path, err)
+	}
+	return true, nil
+}
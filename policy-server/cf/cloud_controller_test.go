@@ -0,0 +1,67 @@
+package cf_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/cf"
+)
+
+var _ = Describe("CloudControllerClient", func() {
+	var (
+		cloudController *httptest.Server
+		client          *cf.CloudControllerClient
+	)
+
+	BeforeEach(func() {
+		cloudController = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "bearer user-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/v3/apps/app-guid":
+				_, _ = w.Write([]byte(`{"guid": "app-guid", "relationships": {"space": {"data": {"guid": "space-guid"}}}}`))
+			case "/v3/apps/broken-app-guid":
+				w.WriteHeader(http.StatusInternalServerError)
+			case "/v3/roles":
+				query := r.URL.Query()
+				Expect(query.Get("types")).To(Equal("space_developer"))
+				Expect(query.Get("space_guids")).To(Equal("space-guid"))
+				total := "0"
+				if query.Get("user_guids") == "developer-guid" {
+					total = "1"
+				}
+				_, _ = w.Write([]byte(`{"pagination": {"total_results": ` + total + `}, "resources": []}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		client = &cf.CloudControllerClient{URL: cloudController.URL, HTTPClient: cloudController.Client()}
+	})
+
+	AfterEach(func() {
+		cloudController.Close()
+	})
+
+	It("is true for space developers of the app's space", func() {
+		Expect(client.IsSpaceDeveloper(context.Background(), "user-token", "developer-guid", "app-guid")).To(BeTrue())
+	})
+
+	It("is false for other users", func() {
+		Expect(client.IsSpaceDeveloper(context.Background(), "user-token", "auditor-guid", "app-guid")).To(BeFalse())
+	})
+
+	It("is false for apps the user cannot see", func() {
+		Expect(client.IsSpaceDeveloper(context.Background(), "user-token", "developer-guid", "other-app-guid")).To(BeFalse())
+	})
+
+	It("fails when the cloud controller fails", func() {
+		_, err := client.IsSpaceDeveloper(context.Background(), "user-token", "developer-guid", "broken-app-guid")
+		Expect(err).To(MatchError("cloud controller responded to /v3/apps/broken-app-guid with status 500"))
+	})
+})
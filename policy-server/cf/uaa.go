@@ -0,0 +1,71 @@
+package cf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Only this much of a response is read
+const maxResponseBytes = 1 << 20
+
+// Token is what UAA knows about a valid access token
+type Token struct {
+	UserID string   `json:"user_id"`
+	Scopes []string `json:"scope"`
+}
+
+// HasScope is true when the token was granted the scope
+func (t Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// UAAClient checks access tokens with UAA's check_token endpoint, which
+// requires a client with the uaa.resource authority
+type UAAClient struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+	HTTPClient   *http.Client
+}
+
+// CheckToken returns an error when UAA does not accept the token, e.g. because
+// it expired or was revoked
+func (c *UAAClient) CheckToken(ctx context.Context, token string) (Token, error) {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.URL, "/")+"/check_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return Token{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Token{}, fmt.Errorf("uaa rejected the token with status %d", resp.StatusCode)
+	}
+
+	checked := Token{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&checked); err != nil {
+		return Token{}, fmt.Errorf("invalid check_token response: %s", err)
+	}
+	if checked.UserID == "" {
+		return Token{}, fmt.Errorf("token has no user")
+	}
+	return checked, nil
+}
@@ -0,0 +1,59 @@
+package cf_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/cf"
+)
+
+var _ = Describe("UAAClient", func() {
+	var (
+		uaa    *httptest.Server
+		client *cf.UAAClient
+	)
+
+	BeforeEach(func() {
+		uaa = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID, clientSecret, _ := r.BasicAuth()
+			if r.URL.Path != "/check_token" || r.Method != http.MethodPost || clientID != "policy-server" || clientSecret != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.FormValue("token") != "valid-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "invalid_token"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"user_id": "user-guid", "scope": ["openid", "network.write"]}`))
+		}))
+		client = &cf.UAAClient{URL: uaa.URL, ClientID: "policy-server", ClientSecret: "secret", HTTPClient: uaa.Client()}
+	})
+
+	AfterEach(func() {
+		uaa.Close()
+	})
+
+	It("returns the user and scopes of a valid token", func() {
+		token, err := client.CheckToken(context.Background(), "valid-token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.UserID).To(Equal("user-guid"))
+		Expect(token.HasScope("network.write")).To(BeTrue())
+		Expect(token.HasScope("network.admin")).To(BeFalse())
+	})
+
+	It("fails for tokens UAA rejects", func() {
+		_, err := client.CheckToken(context.Background(), "expired-token")
+		Expect(err).To(MatchError("uaa rejected the token with status 400"))
+	})
+
+	It("fails when the client credentials are wrong", func() {
+		client.ClientSecret = "wrong"
+		_, err := client.CheckToken(context.Background(), "valid-token")
+		Expect(err).To(MatchError("uaa rejected the token with status 401"))
+	})
+})
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/security/v1beta1"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// MeshPolicyReconciler keeps the AuthorizationPolicy and Sidecar of an app in
+// line with the policies it is the destination or source of. Requests are
+// named after the app guid.
+type MeshPolicyReconciler struct {
+	client.Client
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	Builder policies.MeshBuilder
+	// Namespaces selected by this can always reach the apps and are always
+	// visible to them, as with the NetworkPolicies
+	AllowedNamespaces *metav1.LabelSelector
+}
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods;services;namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=security.istio.io,resources=authorizationpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.istio.io,resources=sidecars,verbs=get;list;watch;create;update;patch;delete
+
+func (r *MeshPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("app", req.Name)
+
+	allowedNamespaces, err := r.allowedNamespaces(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileAuthorizationPolicy(ctx, req.Name, allowedNamespaces); err != nil {
+		log.Error(err, "failed to reconcile AuthorizationPolicy")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileSidecar(ctx, req.Name, allowedNamespaces); err != nil {
+		log.Error(err, "failed to reconcile Sidecar")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *MeshPolicyReconciler) reconcileAuthorizationPolicy(ctx context.Context, appGUID string, allowedNamespaces []string) error {
+	destinationPolicies, err := r.policies(ctx, policies.DestinationAppGUIDLabel, appGUID)
+	if err != nil {
+		return err
+	}
+
+	authorizationPolicy := &istiosecurityv1beta1.AuthorizationPolicy{}
+	authorizationPolicy.ObjectMeta.Name = policies.AuthorizationPolicyName(appGUID)
+	authorizationPolicy.ObjectMeta.Namespace = r.Builder.Namespace
+	if len(destinationPolicies) == 0 {
+		return client.IgnoreNotFound(r.Delete(ctx, authorizationPolicy))
+	}
+
+	principals, err := r.principals(ctx)
+	if err != nil {
+		return err
+	}
+
+	desired := r.Builder.BuildAuthorizationPolicy(appGUID, destinationPolicies, principals, allowedNamespaces)
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, authorizationPolicy, func() error {
+		authorizationPolicy.ObjectMeta.Labels = desired.ObjectMeta.Labels
+		authorizationPolicy.Spec = desired.Spec
+		return nil
+	})
+	return err
+}
+
+func (r *MeshPolicyReconciler) reconcileSidecar(ctx context.Context, appGUID string, allowedNamespaces []string) error {
+	sourcePolicies, err := r.policies(ctx, policies.SourceAppGUIDLabel, appGUID)
+	if err != nil {
+		return err
+	}
+
+	sidecar := &istionetworkingv1alpha3.Sidecar{}
+	sidecar.ObjectMeta.Name = policies.SidecarName(appGUID)
+	sidecar.ObjectMeta.Namespace = r.Builder.Namespace
+	if len(sourcePolicies) == 0 {
+		return client.IgnoreNotFound(r.Delete(ctx, sidecar))
+	}
+
+	services := []corev1.Service{}
+	seen := map[string]bool{}
+	for _, policy := range sourcePolicies {
+		destinationGUID := policy.Destination.ID
+		if seen[destinationGUID] {
+			continue
+		}
+		seen[destinationGUID] = true
+		serviceList := &corev1.ServiceList{}
+		if err := r.List(ctx, serviceList, client.MatchingLabels{policies.AppGUIDLabel: destinationGUID}); err != nil {
+			return err
+		}
+		services = append(services, serviceList.Items...)
+	}
+
+	desired := r.Builder.BuildSidecar(appGUID, services, allowedNamespaces)
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, sidecar, func() error {
+		sidecar.ObjectMeta.Labels = desired.ObjectMeta.Labels
+		sidecar.Spec = desired.Spec
+		return nil
+	})
+	return err
+}
+
+// policies returns the policies stored in the NetworkPolicies with the label
+// set to the app guid
+func (r *MeshPolicyReconciler) policies(ctx context.Context, label, appGUID string) ([]policies.Policy, error) {
+	list := &networkingv1.NetworkPolicyList{}
+	if err := r.List(ctx, list,
+		client.InNamespace(r.Builder.Namespace),
+		client.MatchingLabels{policies.ManagedByLabel: policies.ManagedBy, label: appGUID},
+	); err != nil {
+		return nil, err
+	}
+
+	result := []policies.Policy{}
+	for _, networkPolicy := range list.Items {
+		if policy, ok := policies.FromNetworkPolicy(networkPolicy); ok {
+			result = append(result, policy)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result, nil
+}
+
+// principals returns the workload identities of the running pods of each app.
+// Apps sharing a service account share its identity, so the sidecars can't
+// tell them apart. Such principals are left out, and a source without other
+// principals gets no rule, rather than allowing every app with the service
+// account.
+func (r *MeshPolicyReconciler) principals(ctx context.Context) (map[string][]string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(r.Builder.Namespace), client.HasLabels{policies.AppGUIDLabel}); err != nil {
+		return nil, err
+	}
+
+	apps := map[string]map[string]bool{}
+	for _, pod := range pods.Items {
+		principal := r.Builder.Principal(pod.ObjectMeta.Namespace, serviceAccountName(pod))
+		if apps[principal] == nil {
+			apps[principal] = map[string]bool{}
+		}
+		apps[principal][pod.ObjectMeta.Labels[policies.AppGUIDLabel]] = true
+	}
+
+	principals := map[string][]string{}
+	for principal, appGUIDs := range apps {
+		if len(appGUIDs) > 1 {
+			r.Log.Info("ignoring principal shared by several apps, each app needs its own service account", "principal", principal, "apps", sortedKeys(appGUIDs))
+			continue
+		}
+		for appGUID := range appGUIDs {
+			principals[appGUID] = append(principals[appGUID], principal)
+		}
+	}
+	for appGUID := range principals {
+		sort.Strings(principals[appGUID])
+	}
+	return principals, nil
+}
+
+func serviceAccountName(pod corev1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return pod.Spec.ServiceAccountName
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (r *MeshPolicyReconciler) allowedNamespaces(ctx context.Context) ([]string, error) {
+	if r.AllowedNamespaces == nil {
+		return nil, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(r.AllowedNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &corev1.NamespaceList{}
+	if err := r.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, namespace := range list.Items {
+		names = append(names, namespace.ObjectMeta.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (r *MeshPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("meshpolicy").
+		Watches(&source.Kind{Type: &networkingv1.NetworkPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.networkPolicyApps)).
+		Watches(&source.Kind{Type: &istiosecurityv1beta1.AuthorizationPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.labeledApp(policies.DestinationAppGUIDLabel))).
+		Watches(&source.Kind{Type: &istionetworkingv1alpha3.Sidecar{}}, handler.EnqueueRequestsFromMapFunc(r.labeledApp(policies.SourceAppGUIDLabel))).
+		Watches(&source.Kind{Type: &corev1.Pod{}}, handler.EnqueueRequestsFromMapFunc(r.podApps)).
+		Watches(&source.Kind{Type: &corev1.Service{}}, handler.EnqueueRequestsFromMapFunc(r.relatedApps(policies.DestinationAppGUIDLabel, policies.SourceAppGUIDLabel))).
+		Complete(r)
+}
+
+func (r *MeshPolicyReconciler) appRequest(appGUID string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: r.Builder.Namespace, Name: appGUID}}
+}
+
+// networkPolicyApps enqueues both apps of a policy, the destination for its
+// AuthorizationPolicy and the source for its Sidecar
+func (r *MeshPolicyReconciler) networkPolicyApps(obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	// NetworkPolicies of SecurityGroups don't store a policy
+	if labels[policies.ManagedByLabel] != policies.ManagedBy || labels[policies.SourceAppGUIDLabel] == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		r.appRequest(labels[policies.SourceAppGUIDLabel]),
+		r.appRequest(labels[policies.DestinationAppGUIDLabel]),
+	}
+}
+
+// labeledApp enqueues the app of a generated resource, so changes to it are
+// reverted
+func (r *MeshPolicyReconciler) labeledApp(label string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		labels := obj.GetLabels()
+		if labels[policies.ManagedByLabel] != policies.ManagedBy || labels[label] == "" {
+			return nil
+		}
+		return []reconcile.Request{r.appRequest(labels[label])}
+	}
+}
+
+// podApps enqueues the destinations of the policies of the app of a pod and
+// of the other apps running with its service account, whose principals stop
+// or start being shared
+func (r *MeshPolicyReconciler) podApps(obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.ObjectMeta.Labels[policies.AppGUIDLabel] == "" {
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(context.Background(), pods, client.InNamespace(r.Builder.Namespace), client.HasLabels{policies.AppGUIDLabel}); err != nil {
+		r.Log.Error(err, "failed to list Pods", "app", pod.ObjectMeta.Labels[policies.AppGUIDLabel])
+		return nil
+	}
+	appGUIDs := map[string]bool{pod.ObjectMeta.Labels[policies.AppGUIDLabel]: true}
+	for _, other := range pods.Items {
+		if serviceAccountName(other) == serviceAccountName(*pod) {
+			appGUIDs[other.ObjectMeta.Labels[policies.AppGUIDLabel]] = true
+		}
+	}
+
+	requests := []reconcile.Request{}
+	for _, appGUID := range sortedKeys(appGUIDs) {
+		requests = append(requests, r.relatedAppRequests(appGUID, policies.SourceAppGUIDLabel, policies.DestinationAppGUIDLabel)...)
+	}
+	return requests
+}
+
+// relatedApps enqueues the other apps of the policies the app of a service is
+// in, the sources whose Sidecars list its services
+func (r *MeshPolicyReconciler) relatedApps(label, relatedLabel string) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		appGUID := obj.GetLabels()[policies.AppGUIDLabel]
+		if appGUID == "" {
+			return nil
+		}
+		return r.relatedAppRequests(appGUID, label, relatedLabel)
+	}
+}
+
+func (r *MeshPolicyReconciler) relatedAppRequests(appGUID, label, relatedLabel string) []reconcile.Request {
+	list := &networkingv1.NetworkPolicyList{}
+	if err := r.List(context.Background(), list,
+		client.InNamespace(r.Builder.Namespace),
+		client.MatchingLabels{policies.ManagedByLabel: policies.ManagedBy, label: appGUID},
+	); err != nil {
+		r.Log.Error(err, "failed to list NetworkPolicies", "app", appGUID)
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, networkPolicy := range list.Items {
+		requests = append(requests, r.appRequest(networkPolicy.ObjectMeta.Labels[relatedLabel]))
+	}
+	return requests
+}
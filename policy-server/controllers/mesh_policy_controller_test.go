@@ -0,0 +1,179 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/security/v1beta1"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/controllers"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("MeshPolicyReconciler", func() {
+	var (
+		ctx        context.Context
+		k8sClient  client.Client
+		reconciler *controllers.MeshPolicyReconciler
+		store      *policies.Store
+		policy     policies.Policy
+	)
+
+	reconcileApp := func(appGUID string) {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "cf-workloads", Name: appGUID}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(istionetworkingv1alpha3.AddToScheme(scheme)).To(Succeed())
+		Expect(istiosecurityv1beta1.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "istio-system", Labels: map[string]string{"kubernetes.io/metadata.name": "istio-system"}}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cf-workloads", Labels: map[string]string{"kubernetes.io/metadata.name": "cf-workloads"}}},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "source-0", Namespace: "cf-workloads", Labels: map[string]string{"cloudfoundry.org/app_guid": "source-app-guid"}},
+				Spec:       corev1.PodSpec{ServiceAccountName: "source-sa"},
+			},
+			&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: "s-destination", Namespace: "cf-workloads", Labels: map[string]string{"cloudfoundry.org/app_guid": "destination-app-guid"}},
+			},
+		).Build()
+
+		allowedNamespaces := &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "istio-system"}}
+		reconciler = &controllers.MeshPolicyReconciler{
+			Client: k8sClient,
+			Log:    log.Log,
+			Scheme: scheme,
+			Builder: policies.MeshBuilder{
+				Namespace:   "cf-workloads",
+				TrustDomain: "cluster.local",
+			},
+			AllowedNamespaces: allowedNamespaces,
+		}
+		store = &policies.Store{
+			Client:     k8sClient,
+			Translator: policies.Translator{Namespace: "cf-workloads", AllowedNamespaces: allowedNamespaces},
+		}
+
+		policy = policies.Policy{
+			Source: policies.Source{ID: "source-app-guid"},
+			Destination: policies.Destination{
+				ID:       "destination-app-guid",
+				Protocol: "tcp",
+				Ports:    policies.Ports{Start: 8080, End: 8080},
+			},
+		}
+		Expect(store.Create(ctx, []policies.Policy{policy})).To(Succeed())
+	})
+
+	It("creates an AuthorizationPolicy for the destination app allowing the source's principals", func() {
+		reconcileApp("destination-app-guid")
+
+		authorizationPolicy := &istiosecurityv1beta1.AuthorizationPolicy{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.AuthorizationPolicyName("destination-app-guid")}, authorizationPolicy)).To(Succeed())
+		Expect(authorizationPolicy.Spec.Selector.MatchLabels).To(Equal(map[string]string{"cloudfoundry.org/app_guid": "destination-app-guid"}))
+		Expect(authorizationPolicy.Spec.Rules).To(HaveLen(2))
+		Expect(authorizationPolicy.Spec.Rules[0].From[0].Source.Principals).To(Equal([]string{"cluster.local/ns/cf-workloads/sa/source-sa"}))
+		Expect(authorizationPolicy.Spec.Rules[0].To[0].Operation.Ports).To(Equal([]string{"8080"}))
+		Expect(authorizationPolicy.Spec.Rules[1].From[0].Source.Namespaces).To(Equal([]string{"istio-system"}))
+
+		sidecar := &istionetworkingv1alpha3.Sidecar{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.SidecarName("destination-app-guid")}, sidecar)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("creates a Sidecar for the source app making the destination's services visible", func() {
+		reconcileApp("source-app-guid")
+
+		sidecar := &istionetworkingv1alpha3.Sidecar{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.SidecarName("source-app-guid")}, sidecar)).To(Succeed())
+		Expect(sidecar.Spec.WorkloadSelector.Labels).To(Equal(map[string]string{"cloudfoundry.org/app_guid": "source-app-guid"}))
+		Expect(sidecar.Spec.Egress[0].Hosts).To(Equal([]string{
+			"./*",
+			"cf-workloads/s-destination.cf-workloads.svc.cluster.local",
+			"istio-system/*",
+		}))
+
+		authorizationPolicy := &istiosecurityv1beta1.AuthorizationPolicy{}
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.AuthorizationPolicyName("source-app-guid")}, authorizationPolicy)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("updates the resources when the policies change", func() {
+		reconcileApp("destination-app-guid")
+
+		policy.Destination.Ports = policies.Ports{Start: 9000, End: 9001}
+		Expect(store.Create(ctx, []policies.Policy{policy})).To(Succeed())
+		reconcileApp("destination-app-guid")
+
+		authorizationPolicy := &istiosecurityv1beta1.AuthorizationPolicy{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.AuthorizationPolicyName("destination-app-guid")}, authorizationPolicy)).To(Succeed())
+		Expect(authorizationPolicy.Spec.Rules).To(HaveLen(3))
+		Expect(authorizationPolicy.Spec.Rules[0].To[0].Operation.Ports).To(Equal([]string{"8080"}))
+		Expect(authorizationPolicy.Spec.Rules[1].To[0].Operation.Ports).To(Equal([]string{"9000", "9001"}))
+	})
+
+	It("deletes the resources once the app has no policies left", func() {
+		reconcileApp("destination-app-guid")
+		reconcileApp("source-app-guid")
+
+		Expect(store.Delete(ctx, []policies.Policy{policy})).To(Succeed())
+		reconcileApp("destination-app-guid")
+		reconcileApp("source-app-guid")
+
+		authorizationPolicies := &istiosecurityv1beta1.AuthorizationPolicyList{}
+		Expect(k8sClient.List(ctx, authorizationPolicies)).To(Succeed())
+		Expect(authorizationPolicies.Items).To(BeEmpty())
+		sidecars := &istionetworkingv1alpha3.SidecarList{}
+		Expect(k8sClient.List(ctx, sidecars)).To(Succeed())
+		Expect(sidecars.Items).To(BeEmpty())
+	})
+
+	Context("when another app runs with the service account of the source", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-0", Namespace: "cf-workloads", Labels: map[string]string{"cloudfoundry.org/app_guid": "other-app-guid"}},
+				Spec:       corev1.PodSpec{ServiceAccountName: "source-sa"},
+			})).To(Succeed())
+		})
+
+		It("leaves the shared principal out of the AuthorizationPolicy", func() {
+			reconcileApp("destination-app-guid")
+
+			authorizationPolicy := &istiosecurityv1beta1.AuthorizationPolicy{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.AuthorizationPolicyName("destination-app-guid")}, authorizationPolicy)).To(Succeed())
+			Expect(authorizationPolicy.Spec.Rules).To(HaveLen(1))
+			Expect(authorizationPolicy.Spec.Rules[0].From[0].Source.Namespaces).To(Equal([]string{"istio-system"}))
+		})
+
+		It("allows the source again once it has its own service account", func() {
+			reconcileApp("destination-app-guid")
+
+			other := &corev1.Pod{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: "other-0"}, other)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, other)).To(Succeed())
+			reconcileApp("destination-app-guid")
+
+			authorizationPolicy := &istiosecurityv1beta1.AuthorizationPolicy{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: policies.AuthorizationPolicyName("destination-app-guid")}, authorizationPolicy)).To(Succeed())
+			Expect(authorizationPolicy.Spec.Rules).To(HaveLen(2))
+			Expect(authorizationPolicy.Spec.Rules[0].From[0].Source.Principals).To(Equal([]string{"cluster.local/ns/cf-workloads/sa/source-sa"}))
+		})
+	})
+})
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	"github.com/go-logr/logr"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// SecurityGroupReconciler restricts egress of the apps bound to SecurityGroups
+// to what their rules allow. The resources it builds are owned by their
+// SecurityGroup, so they are garbage collected with it.
+type SecurityGroupReconciler struct {
+	client.Client
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+	Builder policies.EgressBuilder
+	// EnableMesh also adds the destinations of the SecurityGroups to the mesh
+	EnableMesh bool
+	// DefaultDenyEgress also restricts egress of apps that aren't bound to any
+	// SecurityGroup
+	DefaultDenyEgress bool
+}
+
+// +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=securitygroups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.istio.io,resources=serviceentries;sidecars,verbs=get;list;watch;create;update;patch;delete
+
+func (r *SecurityGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("securitygroup", req.Name)
+
+	securityGroup := &networkingv1alpha1.SecurityGroup{}
+	if err := r.Get(ctx, req.NamespacedName, securityGroup); err != nil {
+		// resources of deleted SecurityGroups are garbage collected
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if err := r.reconcileNetworkPolicies(ctx, securityGroup); err != nil {
+		log.Error(err, "failed to reconcile NetworkPolicies")
+		return ctrl.Result{}, err
+	}
+	if r.EnableMesh {
+		if err := r.reconcileServiceEntries(ctx, securityGroup); err != nil {
+			log.Error(err, "failed to reconcile ServiceEntries")
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *SecurityGroupReconciler) reconcileNetworkPolicies(ctx context.Context, securityGroup *networkingv1alpha1.SecurityGroup) error {
+	desiredNames := map[string]bool{}
+	for _, desired := range r.Builder.BuildNetworkPolicies(*securityGroup) {
+		desiredNames[desired.ObjectMeta.Name] = true
+		networkPolicy := &networkingv1.NetworkPolicy{}
+		networkPolicy.ObjectMeta.Name = desired.ObjectMeta.Name
+		networkPolicy.ObjectMeta.Namespace = desired.ObjectMeta.Namespace
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, networkPolicy, func() error {
+			networkPolicy.ObjectMeta.Labels = desired.ObjectMeta.Labels
+			networkPolicy.Spec = desired.Spec
+			return controllerutil.SetControllerReference(securityGroup, networkPolicy, r.Scheme)
+		}); err != nil {
+			return err
+		}
+	}
+
+	// a binding that was removed leaves its NetworkPolicy behind
+	existing := &networkingv1.NetworkPolicyList{}
+	if err := r.List(ctx, existing, client.InNamespace(r.Builder.Namespace), r.ownedBy(securityGroup)); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		if !desiredNames[existing.Items[i].ObjectMeta.Name] {
+			if err := r.Delete(ctx, &existing.Items[i]); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *SecurityGroupReconciler) reconcileServiceEntries(ctx context.Context, securityGroup *networkingv1alpha1.SecurityGroup) error {
+	desiredNames := map[string]bool{}
+	for _, desired := range r.Builder.BuildServiceEntries(*securityGroup) {
+		desiredNames[desired.ObjectMeta.Name] = true
+		serviceEntry := &istionetworkingv1alpha3.ServiceEntry{}
+		serviceEntry.ObjectMeta.Name = desired.ObjectMeta.Name
+		serviceEntry.ObjectMeta.Namespace = desired.ObjectMeta.Namespace
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, serviceEntry, func() error {
+			serviceEntry.ObjectMeta.Labels = desired.ObjectMeta.Labels
+			serviceEntry.Spec = desired.Spec
+			return controllerutil.SetControllerReference(securityGroup, serviceEntry, r.Scheme)
+		}); err != nil {
+			return err
+		}
+	}
+
+	// ServiceEntries are named after their rule, so changed rules leave the
+	// ServiceEntries of their old version behind
+	existing := &istionetworkingv1alpha3.ServiceEntryList{}
+	if err := r.List(ctx, existing, client.InNamespace(r.Builder.Namespace), r.ownedBy(securityGroup)); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		if !desiredNames[existing.Items[i].ObjectMeta.Name] {
+			if err := r.Delete(ctx, &existing.Items[i]); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *SecurityGroupReconciler) ownedBy(securityGroup *networkingv1alpha1.SecurityGroup) client.MatchingLabels {
+	return client.MatchingLabels{
+		policies.ManagedByLabel:     policies.ManagedBy,
+		policies.SecurityGroupLabel: securityGroup.ObjectMeta.Name,
+	}
+}
+
+// ReconcileDefaultDenyEgress creates the resources denying egress of apps not
+// bound to a SecurityGroup when default deny is enabled, and deletes them
+// otherwise. They don't depend on any SecurityGroup, so it runs once on
+// start.
+func (r *SecurityGroupReconciler) ReconcileDefaultDenyEgress(ctx context.Context) error {
+	desiredNetworkPolicy := r.Builder.BuildDefaultDenyNetworkPolicy()
+	networkPolicy := &networkingv1.NetworkPolicy{}
+	networkPolicy.ObjectMeta.Name = desiredNetworkPolicy.ObjectMeta.Name
+	networkPolicy.ObjectMeta.Namespace = desiredNetworkPolicy.ObjectMeta.Namespace
+	if !r.DefaultDenyEgress {
+		if err := r.Delete(ctx, networkPolicy); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	} else if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, networkPolicy, func() error {
+		networkPolicy.ObjectMeta.Labels = desiredNetworkPolicy.ObjectMeta.Labels
+		networkPolicy.Spec = desiredNetworkPolicy.Spec
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if !r.EnableMesh {
+		return nil
+	}
+	desiredSidecar := r.Builder.BuildDefaultDenySidecar()
+	sidecar := &istionetworkingv1alpha3.Sidecar{}
+	sidecar.ObjectMeta.Name = desiredSidecar.ObjectMeta.Name
+	sidecar.ObjectMeta.Namespace = desiredSidecar.ObjectMeta.Namespace
+	if !r.DefaultDenyEgress {
+		return client.IgnoreNotFound(r.Delete(ctx, sidecar))
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, sidecar, func() error {
+		sidecar.ObjectMeta.Labels = desiredSidecar.ObjectMeta.Labels
+		sidecar.Spec = desiredSidecar.Spec
+		return nil
+	})
+	return err
+}
+
+func (r *SecurityGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&networkingv1alpha1.SecurityGroup{}).
+		Owns(&networkingv1.NetworkPolicy{})
+	if r.EnableMesh {
+		builder = builder.Owns(&istionetworkingv1alpha3.ServiceEntry{})
+	}
+	return builder.Complete(r)
+}
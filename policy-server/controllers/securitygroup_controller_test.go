@@ -0,0 +1,162 @@
+package controllers_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/controllers"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("SecurityGroupReconciler", func() {
+	var (
+		ctx           context.Context
+		k8sClient     client.Client
+		reconciler    *controllers.SecurityGroupReconciler
+		securityGroup *networkingv1alpha1.SecurityGroup
+	)
+
+	reconcileSecurityGroup := func() {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "public-https"}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	listNetworkPolicies := func() []networkingv1.NetworkPolicy {
+		list := &networkingv1.NetworkPolicyList{}
+		Expect(k8sClient.List(ctx, list, client.InNamespace("cf-workloads"))).To(Succeed())
+		return list.Items
+	}
+
+	listServiceEntries := func() []istionetworkingv1alpha3.ServiceEntry {
+		list := &istionetworkingv1alpha3.ServiceEntryList{}
+		Expect(k8sClient.List(ctx, list, client.InNamespace("cf-workloads"))).To(Succeed())
+		return list.Items
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(istionetworkingv1alpha3.AddToScheme(scheme)).To(Succeed())
+		Expect(networkingv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		securityGroup = &networkingv1alpha1.SecurityGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "public-https", UID: "security-group-uid"},
+			Spec: networkingv1alpha1.SecurityGroupSpec{
+				Rules: []networkingv1alpha1.SecurityGroupRule{
+					{Protocol: "tcp", Destination: "0.0.0.0/0", Ports: []int32{443}},
+				},
+				Bindings: networkingv1alpha1.SecurityGroupBindings{
+					Orgs:   []string{"org-guid"},
+					Spaces: []string{"space-guid"},
+				},
+			},
+		}
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(securityGroup).Build()
+
+		reconciler = &controllers.SecurityGroupReconciler{
+			Client:     k8sClient,
+			Log:        log.Log,
+			Scheme:     scheme,
+			Builder:    policies.EgressBuilder{Namespace: "cf-workloads"},
+			EnableMesh: true,
+		}
+	})
+
+	It("creates NetworkPolicies and ServiceEntries owned by the security group", func() {
+		reconcileSecurityGroup()
+
+		networkPolicies := listNetworkPolicies()
+		Expect(networkPolicies).To(HaveLen(2))
+		for _, networkPolicy := range networkPolicies {
+			Expect(networkPolicy.ObjectMeta.Labels).To(HaveKeyWithValue("cloudfoundry.org/security_group", "public-https"))
+			Expect(networkPolicy.ObjectMeta.OwnerReferences).To(ConsistOf(metav1.OwnerReference{
+				APIVersion:         "networking.cloudfoundry.org/v1alpha1",
+				Kind:               "SecurityGroup",
+				Name:               "public-https",
+				UID:                "security-group-uid",
+				Controller:         boolPtr(true),
+				BlockOwnerDeletion: boolPtr(true),
+			}))
+		}
+
+		serviceEntries := listServiceEntries()
+		Expect(serviceEntries).To(HaveLen(1))
+		Expect(serviceEntries[0].Spec.Addresses).To(Equal([]string{"0.0.0.0/0"}))
+		Expect(serviceEntries[0].ObjectMeta.OwnerReferences).To(HaveLen(1))
+	})
+
+	It("deletes the resources of removed bindings and changed rules", func() {
+		reconcileSecurityGroup()
+		oldServiceEntry := listServiceEntries()[0].ObjectMeta.Name
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "public-https"}, securityGroup)).To(Succeed())
+		securityGroup.Spec.Bindings.Orgs = nil
+		securityGroup.Spec.Rules[0].Ports = []int32{8443}
+		Expect(k8sClient.Update(ctx, securityGroup)).To(Succeed())
+		reconcileSecurityGroup()
+
+		networkPolicies := listNetworkPolicies()
+		Expect(networkPolicies).To(HaveLen(1))
+		Expect(networkPolicies[0].ObjectMeta.Name).To(Equal(policies.SecurityGroupResourceName("public-https", "cloudfoundry.org/space_guid")))
+
+		serviceEntries := listServiceEntries()
+		Expect(serviceEntries).To(HaveLen(1))
+		Expect(serviceEntries[0].ObjectMeta.Name).NotTo(Equal(oldServiceEntry))
+		Expect(serviceEntries[0].Spec.Ports[0].Number).To(Equal(uint32(8443)))
+	})
+
+	It("doesn't create ServiceEntries without the mesh", func() {
+		reconciler.EnableMesh = false
+		reconcileSecurityGroup()
+
+		Expect(listNetworkPolicies()).To(HaveLen(2))
+		Expect(listServiceEntries()).To(BeEmpty())
+	})
+
+	It("ignores deleted security groups", func() {
+		Expect(k8sClient.Delete(ctx, securityGroup)).To(Succeed())
+		reconcileSecurityGroup()
+
+		Expect(listNetworkPolicies()).To(BeEmpty())
+	})
+
+	Describe("ReconcileDefaultDenyEgress", func() {
+		It("creates the default deny resources when enabled and deletes them otherwise", func() {
+			reconciler.DefaultDenyEgress = true
+			Expect(reconciler.ReconcileDefaultDenyEgress(ctx)).To(Succeed())
+
+			networkPolicy := &networkingv1.NetworkPolicy{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: "default-deny-egress"}, networkPolicy)).To(Succeed())
+			Expect(networkPolicy.Spec.PolicyTypes).To(Equal([]networkingv1.PolicyType{networkingv1.PolicyTypeEgress}))
+			sidecar := &istionetworkingv1alpha3.Sidecar{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: "default-deny-egress"}, sidecar)).To(Succeed())
+
+			reconciler.DefaultDenyEgress = false
+			Expect(reconciler.ReconcileDefaultDenyEgress(ctx)).To(Succeed())
+
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: "default-deny-egress"}, networkPolicy)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Namespace: "cf-workloads", Name: "default-deny-egress"}, sidecar)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
+
+func boolPtr(b bool) *bool {
+	return &b
+}
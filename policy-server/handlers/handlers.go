@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/cf"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	"github.com/go-logr/logr"
+)
+
+const (
+	PoliciesPath       = "/networking/v1/external/policies"
+	DeletePoliciesPath = "/networking/v1/external/policies/delete"
+)
+
+// Requests with larger bodies are rejected
+const maxBodyBytes = 1 << 20
+
+const (
+	// AdminScope allows managing the policies of any app
+	AdminScope = "network.admin"
+	// WriteScope allows managing the policies between apps the user is a
+	// space developer of
+	WriteScope = "network.write"
+)
+
+// PolicyStore is implemented by policies.Store
+type PolicyStore interface {
+	List(ctx context.Context, appGUIDs []string) ([]policies.Policy, error)
+	Create(ctx context.Context, policies []policies.Policy) error
+	Delete(ctx context.Context, policies []policies.Policy) error
+}
+
+// TokenChecker is implemented by cf.UAAClient
+type TokenChecker interface {
+	CheckToken(ctx context.Context, token string) (cf.Token, error)
+}
+
+// SpaceDeveloperChecker is implemented by cf.CloudControllerClient
+type SpaceDeveloperChecker interface {
+	IsSpaceDeveloper(ctx context.Context, token, userID, appGUID string) (bool, error)
+}
+
+// New returns a handler serving the CF Network Policy API v1 external
+// endpoints for listing, creating and deleting policies. Like in CF, requests
+// need a UAA access token with the network.admin scope, or with the
+// network.write scope for users that are space developers of both apps of
+// the policies.
+func New(store PolicyStore, tokens TokenChecker, spaces SpaceDeveloperChecker, log logr.Logger) http.Handler {
+	h := &handler{store: store, tokens: tokens, spaces: spaces, log: log}
+	mux := http.NewServeMux()
+	mux.HandleFunc(PoliciesPath, h.authenticated(h.policies))
+	mux.HandleFunc(DeletePoliciesPath, h.authenticated(h.deletePolicies))
+	return mux
+}
+
+type handler struct {
+	store  PolicyStore
+	tokens TokenChecker
+	spaces SpaceDeveloperChecker
+	log    logr.Logger
+}
+
+// user is the caller of a request with a valid access token
+type user struct {
+	cf.Token
+	accessToken string
+}
+
+func (h *handler) authenticated(next func(http.ResponseWriter, *http.Request, user)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if len(authorization) < len("bearer ") || !strings.EqualFold(authorization[:len("bearer ")], "bearer ") {
+			h.writeError(w, http.StatusUnauthorized, fmt.Errorf("missing bearer token"))
+			return
+		}
+		accessToken := strings.TrimSpace(authorization[len("bearer "):])
+
+		token, err := h.tokens.CheckToken(r.Context(), accessToken)
+		if err != nil {
+			h.log.Info("rejected access token", "reason", err.Error())
+			h.writeError(w, http.StatusUnauthorized, fmt.Errorf("failed to verify token with uaa"))
+			return
+		}
+		if !token.HasScope(AdminScope) && !token.HasScope(WriteScope) {
+			h.writeError(w, http.StatusForbidden, fmt.Errorf("provided scopes %s do not include allowed scopes %s", token.Scopes, []string{AdminScope, WriteScope}))
+			return
+		}
+
+		next(w, r, user{Token: token, accessToken: accessToken})
+	}
+}
+
+// canAccess is true when the user may manage policies between the apps
+func (h *handler) canAccess(ctx context.Context, u user, appGUIDs ...string) (bool, error) {
+	if u.HasScope(AdminScope) {
+		return true, nil
+	}
+	for _, appGUID := range appGUIDs {
+		developer, err := h.spaces.IsSpaceDeveloper(ctx, u.accessToken, u.UserID, appGUID)
+		if err != nil || !developer {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// canAccessAll writes the error response itself when the user may not manage
+// one of the policies
+func (h *handler) canAccessAll(w http.ResponseWriter, r *http.Request, u user, requested []policies.Policy) bool {
+	appGUIDs := []string{}
+	seen := map[string]bool{}
+	for _, policy := range requested {
+		for _, appGUID := range []string{policy.Source.ID, policy.Destination.ID} {
+			if !seen[appGUID] {
+				seen[appGUID] = true
+				appGUIDs = append(appGUIDs, appGUID)
+			}
+		}
+	}
+
+	allowed, err := h.canAccess(r.Context(), u, appGUIDs...)
+	if err != nil {
+		h.log.Error(err, "unable to check space developer roles")
+		h.writeError(w, http.StatusInternalServerError, fmt.Errorf("cloud controller request failed"))
+		return false
+	}
+	if !allowed {
+		h.writeError(w, http.StatusForbidden, fmt.Errorf("one or more applications cannot be found or accessed"))
+		return false
+	}
+	return true
+}
+
+func (h *handler) policies(w http.ResponseWriter, r *http.Request, u user) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listPolicies(w, r, u)
+	case http.MethodPost:
+		h.createPolicies(w, r, u)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		h.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// The id parameter is a comma separated list of app guids. Users without the
+// admin scope only get the policies between apps they are a space developer
+// of.
+func (h *handler) listPolicies(w http.ResponseWriter, r *http.Request, u user) {
+	appGUIDs := []string{}
+	for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			appGUIDs = append(appGUIDs, id)
+		}
+	}
+
+	list, err := h.store.List(r.Context(), appGUIDs)
+	if err != nil {
+		h.log.Error(err, "unable to list policies")
+		h.writeError(w, http.StatusInternalServerError, fmt.Errorf("database read failed"))
+		return
+	}
+
+	if !u.HasScope(AdminScope) {
+		accessible := map[string]bool{}
+		visible := []policies.Policy{}
+		for _, policy := range list {
+			allowed := true
+			for _, appGUID := range []string{policy.Source.ID, policy.Destination.ID} {
+				developer, checked := accessible[appGUID]
+				if !checked {
+					if developer, err = h.canAccess(r.Context(), u, appGUID); err != nil {
+						h.log.Error(err, "unable to check space developer roles")
+						h.writeError(w, http.StatusInternalServerError, fmt.Errorf("cloud controller request failed"))
+						return
+					}
+					accessible[appGUID] = developer
+				}
+				allowed = allowed && developer
+			}
+			if allowed {
+				visible = append(visible, policy)
+			}
+		}
+		list = visible
+	}
+
+	total := len(list)
+	h.writeJSON(w, http.StatusOK, policies.Policies{TotalPolicies: &total, Policies: list})
+}
+
+func (h *handler) createPolicies(w http.ResponseWriter, r *http.Request, u user) {
+	requested, ok := h.readPolicies(w, r)
+	if !ok || !h.canAccessAll(w, r, u, requested) {
+		return
+	}
+
+	if err := h.store.Create(r.Context(), requested); err != nil {
+		h.log.Error(err, "unable to create policies")
+		h.writeError(w, http.StatusInternalServerError, fmt.Errorf("database create failed"))
+		return
+	}
+	h.log.Info("Policies have been created", "policies", len(requested))
+	h.writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (h *handler) deletePolicies(w http.ResponseWriter, r *http.Request, u user) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		h.writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	requested, ok := h.readPolicies(w, r)
+	if !ok || !h.canAccessAll(w, r, u, requested) {
+		return
+	}
+
+	if err := h.store.Delete(r.Context(), requested); err != nil {
+		h.log.Error(err, "unable to delete policies")
+		h.writeError(w, http.StatusInternalServerError, fmt.Errorf("database delete failed"))
+		return
+	}
+	h.log.Info("Policies have been deleted", "policies", len(requested))
+	h.writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// readPolicies writes the error response itself when the body is invalid
+func (h *handler) readPolicies(w http.ResponseWriter, r *http.Request) ([]policies.Policy, bool) {
+	body := policies.Policies{}
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxBodyBytes))
+	if err := decoder.Decode(&body); err != nil {
+		h.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid json: %s", err))
+		return nil, false
+	}
+
+	if len(body.Policies) == 0 {
+		h.writeError(w, http.StatusBadRequest, fmt.Errorf("missing policies"))
+		return nil, false
+	}
+	for _, policy := range body.Policies {
+		if err := policy.Validate(); err != nil {
+			h.writeError(w, http.StatusBadRequest, err)
+			return nil, false
+		}
+	}
+
+	return body.Policies, true
+}
+
+func (h *handler) writeError(w http.ResponseWriter, status int, err error) {
+	h.writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.log.Error(err, "unable to write response")
+	}
+}
@@ -0,0 +1,241 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/cf"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/handlers"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type failingStore struct{}
+
+func (failingStore) List(ctx context.Context, appGUIDs []string) ([]policies.Policy, error) {
+	return nil, errors.New("api server unavailable")
+}
+
+func (failingStore) Create(ctx context.Context, policies []policies.Policy) error {
+	return errors.New("api server unavailable")
+}
+
+func (failingStore) Delete(ctx context.Context, policies []policies.Policy) error {
+	return errors.New("api server unavailable")
+}
+
+// fakeUAA knows the tokens by their value
+type fakeUAA map[string]cf.Token
+
+func (u fakeUAA) CheckToken(ctx context.Context, token string) (cf.Token, error) {
+	checked, ok := u[token]
+	if !ok {
+		return cf.Token{}, errors.New("uaa rejected the token with status 401")
+	}
+	return checked, nil
+}
+
+// fakeCloudController knows the app guids each user is a space developer of
+type fakeCloudController struct {
+	developers map[string][]string
+	err        error
+}
+
+func (c *fakeCloudController) IsSpaceDeveloper(ctx context.Context, token, userID, appGUID string) (bool, error) {
+	if c.err != nil {
+		return false, c.err
+	}
+	for _, guid := range c.developers[userID] {
+		if guid == appGUID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var _ = Describe("Handlers", func() {
+	var (
+		handler         http.Handler
+		store           handlers.PolicyStore
+		cloudController *fakeCloudController
+		token           string
+	)
+
+	const policyJSON = `{"source": {"id": "app-a"}, "destination": {"id": "app-b", "protocol": "tcp", "ports": {"start": 8080, "end": 8080}}}`
+
+	uaa := fakeUAA{
+		"admin-token":     {UserID: "admin", Scopes: []string{"openid", handlers.AdminScope}},
+		"developer-token": {UserID: "developer", Scopes: []string{"openid", handlers.WriteScope}},
+		"reader-token":    {UserID: "reader", Scopes: []string{"openid"}},
+	}
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		store = &policies.Store{
+			Client:     fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build(),
+			Translator: policies.Translator{Namespace: "cf-workloads"},
+		}
+		cloudController = &fakeCloudController{developers: map[string][]string{"developer": {"app-a", "app-b"}}}
+		token = "admin-token"
+	})
+
+	JustBeforeEach(func() {
+		handler = handlers.New(store, uaa, cloudController, log.Log)
+	})
+
+	It("creates, lists and deletes policies", func() {
+		response := serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`]}`)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(MatchJSON(`{}`))
+
+		response = serve(http.MethodGet, "/networking/v1/external/policies", "")
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(response.Body.String()).To(MatchJSON(`{"total_policies": 1, "policies": [` + policyJSON + `]}`))
+
+		response = serve(http.MethodPost, "/networking/v1/external/policies/delete", `{"policies": [`+policyJSON+`]}`)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		response = serve(http.MethodGet, "/networking/v1/external/policies", "")
+		Expect(response.Body.String()).To(MatchJSON(`{"total_policies": 0, "policies": []}`))
+	})
+
+	It("lists the policies of the apps in the id parameter", func() {
+		serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`]}`)
+
+		response := serve(http.MethodGet, "/networking/v1/external/policies?id=app-b,app-c", "")
+		Expect(response.Body.String()).To(MatchJSON(`{"total_policies": 1, "policies": [` + policyJSON + `]}`))
+
+		response = serve(http.MethodGet, "/networking/v1/external/policies?id=app-c", "")
+		Expect(response.Body.String()).To(MatchJSON(`{"total_policies": 0, "policies": []}`))
+	})
+
+	It("rejects invalid policies", func() {
+		response := serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [{"source": {"id": "app-a"}, "destination": {"id": "app-b", "protocol": "icmp", "ports": {"start": 8080, "end": 8080}}}]}`)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(MatchJSON(`{"error": "invalid destination protocol \"icmp\", specify either tcp or udp"}`))
+
+		response = serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [{"source": {"id": "app a"}, "destination": {"id": "app-b", "protocol": "tcp", "ports": {"start": 8080, "end": 8080}}}]}`)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring(`invalid source id \"app a\"`))
+
+		response = serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": []}`)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(MatchJSON(`{"error": "missing policies"}`))
+
+		response = serve(http.MethodPost, "/networking/v1/external/policies/delete", `not json`)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects other methods", func() {
+		response := serve(http.MethodPut, "/networking/v1/external/policies", "")
+		Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(response.Header().Get("Allow")).To(Equal("GET, POST"))
+
+		response = serve(http.MethodGet, "/networking/v1/external/policies/delete", "")
+		Expect(response.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	Context("when the request has no valid token", func() {
+		It("responds with unauthorized", func() {
+			token = ""
+			response := serve(http.MethodGet, "/networking/v1/external/policies", "")
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			Expect(response.Body.String()).To(MatchJSON(`{"error": "missing bearer token"}`))
+
+			token = "expired-token"
+			response = serve(http.MethodPost, "/networking/v1/external/policies/delete", `{"policies": [`+policyJSON+`]}`)
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+			Expect(response.Body.String()).To(MatchJSON(`{"error": "failed to verify token with uaa"}`))
+		})
+	})
+
+	Context("when the token has neither network scope", func() {
+		It("responds with forbidden", func() {
+			token = "reader-token"
+			response := serve(http.MethodGet, "/networking/v1/external/policies", "")
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+			Expect(response.Body.String()).To(MatchJSON(`{"error": "provided scopes [openid] do not include allowed scopes [network.admin network.write]"}`))
+		})
+	})
+
+	Context("when the token has the network.write scope", func() {
+		const otherPolicyJSON = `{"source": {"id": "app-a"}, "destination": {"id": "app-c", "protocol": "tcp", "ports": {"start": 8080, "end": 8080}}}`
+
+		BeforeEach(func() {
+			token = "developer-token"
+		})
+
+		It("manages the policies between apps the user is a space developer of", func() {
+			response := serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`]}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			response = serve(http.MethodPost, "/networking/v1/external/policies/delete", `{"policies": [`+policyJSON+`]}`)
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("rejects policies with other apps", func() {
+			response := serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`, `+otherPolicyJSON+`]}`)
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+			Expect(response.Body.String()).To(MatchJSON(`{"error": "one or more applications cannot be found or accessed"}`))
+
+			response = serve(http.MethodPost, "/networking/v1/external/policies/delete", `{"policies": [`+otherPolicyJSON+`]}`)
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("only lists the policies between apps the user is a space developer of", func() {
+			token = "admin-token"
+			serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`, `+otherPolicyJSON+`]}`)
+
+			token = "developer-token"
+			response := serve(http.MethodGet, "/networking/v1/external/policies", "")
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Body.String()).To(MatchJSON(`{"total_policies": 1, "policies": [` + policyJSON + `]}`))
+		})
+
+		Context("when the cloud controller fails", func() {
+			BeforeEach(func() {
+				cloudController.err = errors.New("cloud controller unavailable")
+			})
+
+			It("responds with an internal server error", func() {
+				response := serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`]}`)
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				Expect(response.Body.String()).To(MatchJSON(`{"error": "cloud controller request failed"}`))
+			})
+		})
+	})
+
+	Context("when the store fails", func() {
+		BeforeEach(func() {
+			store = failingStore{}
+		})
+
+		It("responds with an internal server error", func() {
+			response := serve(http.MethodGet, "/networking/v1/external/policies", "")
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"error": "database read failed"}`))
+
+			response = serve(http.MethodPost, "/networking/v1/external/policies", `{"policies": [`+policyJSON+`]}`)
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			Expect(response.Body.String()).To(MatchJSON(`{"error": "database create failed"}`))
+		})
+	})
+})
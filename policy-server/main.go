@@ -0,0 +1,235 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/security/v1beta1"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/cf"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/controllers"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/handlers"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var (
+	scheme   = runtime.NewScheme()
+	setupLog = ctrl.Log.WithName("setup")
+)
+
+func init() {
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = istionetworkingv1alpha3.AddToScheme(scheme)
+	_ = istiosecurityv1beta1.AddToScheme(scheme)
+	_ = networkingv1alpha1.AddToScheme(scheme)
+}
+
+func main() {
+	var listenAddr string
+	var tlsCertFile string
+	var tlsKeyFile string
+	var uaaURL string
+	var uaaClientID string
+	var uaaClientSecretFile string
+	var cloudControllerURL string
+	var workloadsNamespace string
+	var allowedNamespaces string
+	var enableMeshPolicies bool
+	var defaultDenyEgress bool
+	var trustDomain string
+	var metricsAddr string
+	var enableLeaderElection bool
+	flag.StringVar(&listenAddr, "listen-addr", ":8080", "The address the policy API is served on.")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", "",
+		"The certificate the policy API is served with. Without it the API is served over plain HTTP and must only be exposed inside a mesh with strict mTLS.")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", "", "The private key of the certificate the policy API is served with.")
+	flag.StringVar(&uaaURL, "uaa-url", "", "The URL of the UAA that issues the access tokens of policy API requests.")
+	flag.StringVar(&uaaClientID, "uaa-client-id", "network-policy", "The UAA client with the uaa.resource authority used to check access tokens.")
+	flag.StringVar(&uaaClientSecretFile, "uaa-client-secret-file", "", "The file with the secret of the UAA client.")
+	flag.StringVar(&cloudControllerURL, "cloud-controller-url", "", "The URL of the Cloud Controller whose space developers can manage the policies of their apps.")
+	flag.StringVar(&workloadsNamespace, "workloads-namespace", "cf-workloads", "The namespace apps run in, where their NetworkPolicies are stored.")
+	flag.StringVar(&allowedNamespaces, "allowed-namespaces-selector", "kubernetes.io/metadata.name=istio-system",
+		"Label selector of namespaces that can always reach apps with policies and that apps with restricted egress can always reach, such as the one of the ingress gateway. Empty allows none.")
+	flag.BoolVar(&enableMeshPolicies, "enable-mesh-policies", false,
+		"Also enforce policies with Istio AuthorizationPolicies and Sidecars, for clusters with strict mTLS. "+
+			"Sources are identified by the service account of their pods, so each app must run with its own; "+
+			"service accounts shared by several apps are left out of the AuthorizationPolicies.")
+	flag.BoolVar(&defaultDenyEgress, "default-deny-egress", false,
+		"Deny egress of apps to destinations outside the cluster unless a SecurityGroup bound to their org or space allows it.")
+	flag.StringVar(&trustDomain, "trust-domain", "cluster.local", "The Istio trust domain of the workload identities of apps.")
+	flag.StringVar(&metricsAddr, "metrics-addr", "0", "The address the metric endpoint of the controllers binds to, 0 disables it.")
+	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
+		"Enable leader election for the controllers. Enabling this will ensure there is only one active controller.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if uaaURL == "" || cloudControllerURL == "" {
+		setupLog.Info("the uaa-url and cloud-controller-url flags are required")
+		os.Exit(1)
+	}
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		setupLog.Info("the tls-cert-file and tls-key-file flags must be set together")
+		os.Exit(1)
+	}
+	uaaClientSecret := ""
+	if uaaClientSecretFile != "" {
+		secret, err := ioutil.ReadFile(uaaClientSecretFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read uaa client secret")
+			os.Exit(1)
+		}
+		uaaClientSecret = strings.TrimSpace(string(secret))
+	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	translator := policies.Translator{Namespace: workloadsNamespace}
+	if allowedNamespaces != "" {
+		selector, err := metav1.ParseToLabelSelector(allowedNamespaces)
+		if err != nil {
+			setupLog.Error(err, "invalid allowed namespaces selector")
+			os.Exit(1)
+		}
+		translator.AllowedNamespaces = selector
+	}
+
+	restConfig := ctrl.GetConfigOrDie()
+
+	// the API server is the only store, so reads are not cached
+	k8sClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr: listenAddr,
+		Handler: handlers.New(
+			&policies.Store{
+				Client:     k8sClient,
+				Translator: translator,
+			},
+			&cf.UAAClient{
+				URL:          uaaURL,
+				ClientID:     uaaClientID,
+				ClientSecret: uaaClientSecret,
+				HTTPClient:   httpClient,
+			},
+			&cf.CloudControllerClient{
+				URL:        cloudControllerURL,
+				HTTPClient: httpClient,
+			},
+			ctrl.Log.WithName("handlers"),
+		),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                  scheme,
+		MetricsBindAddress:      metricsAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        "cf-k8s-networking-policy-server",
+		LeaderElectionNamespace: workloadsNamespace,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		os.Exit(1)
+	}
+
+	securityGroupReconciler := &controllers.SecurityGroupReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("SecurityGroup"),
+		Scheme: mgr.GetScheme(),
+		Builder: policies.EgressBuilder{
+			Namespace:         workloadsNamespace,
+			AllowedNamespaces: translator.AllowedNamespaces,
+		},
+		EnableMesh:        enableMeshPolicies,
+		DefaultDenyEgress: defaultDenyEgress,
+	}
+	if err = securityGroupReconciler.SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SecurityGroup")
+		os.Exit(1)
+	}
+	if err = mgr.Add(manager.RunnableFunc(securityGroupReconciler.ReconcileDefaultDenyEgress)); err != nil {
+		setupLog.Error(err, "unable to set up default deny egress")
+		os.Exit(1)
+	}
+
+	if enableMeshPolicies {
+		if err = (&controllers.MeshPolicyReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("MeshPolicy"),
+			Scheme: mgr.GetScheme(),
+			Builder: policies.MeshBuilder{
+				Namespace:         workloadsNamespace,
+				TrustDomain:       trustDomain,
+				DefaultDenyEgress: defaultDenyEgress,
+			},
+			AllowedNamespaces: translator.AllowedNamespaces,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "MeshPolicy")
+			os.Exit(1)
+		}
+	}
+
+	go func() {
+		setupLog.Info("starting manager")
+		if err := mgr.Start(ctx); err != nil {
+			setupLog.Error(err, "problem running manager")
+			os.Exit(1)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	if tlsCertFile != "" {
+		setupLog.Info("starting policy server", "addr", listenAddr, "tls", true)
+		err = server.ListenAndServeTLS(tlsCertFile, tlsKeyFile)
+	} else {
+		setupLog.Info("starting policy server without tls, only expose it inside a mesh with strict mTLS", "addr", listenAddr)
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		setupLog.Error(err, "problem running policy server")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,152 @@
+package policies
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/security/v1beta1"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	istiosecurity "istio.io/api/security/v1beta1"
+	istiotype "istio.io/api/type/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MeshBuilder builds the Istio resources enforcing policies between apps in
+// the mesh. With strict mTLS the sidecars see connections from other apps
+// coming from their sidecars, so NetworkPolicies can't tell the apps apart,
+// but the sidecars can by the workload identity in the client certificate.
+type MeshBuilder struct {
+	// Namespace the apps are in, where the resources are created
+	Namespace string
+	// TrustDomain of the workload identities, usually cluster.local
+	TrustDomain string
+	// DefaultDenyEgress makes the Sidecars only pass through connections to
+	// destinations in the mesh, including those of the SecurityGroups
+	DefaultDenyEgress bool
+}
+
+// AuthorizationPolicyName is the name of the AuthorizationPolicy of the
+// destination app with the given guid
+func AuthorizationPolicyName(appGUID string) string {
+	sum := sha256.Sum256([]byte(appGUID))
+	return fmt.Sprintf("ap-%x", sum)
+}
+
+// SidecarName is the name of the Sidecar of the source app with the given guid
+func SidecarName(appGUID string) string {
+	sum := sha256.Sum256([]byte(appGUID))
+	return fmt.Sprintf("sc-%x", sum)
+}
+
+// Principal is the workload identity of the pods running with the service
+// account
+func (b MeshBuilder) Principal(namespace, serviceAccount string) string {
+	return fmt.Sprintf("%s/ns/%s/sa/%s", b.TrustDomain, namespace, serviceAccount)
+}
+
+// MaxMeshPortRange is the widest port range whose ports are listed in an
+// AuthorizationPolicy rule
+const MaxMeshPortRange = 100
+
+// BuildAuthorizationPolicy allows the sources of the policies of a destination
+// app to connect to it on the ports of their policies, identified by the
+// principals of their pods, and the allowed namespaces to connect on any port.
+// Every other connection through the sidecar of the destination app is
+// denied.
+//
+// UDP isn't proxied by the sidecars, so UDP policies are only enforced by
+// their NetworkPolicies. Istio rules cannot have port ranges, so the ports of
+// a range are listed, and rules of ranges wider than MaxMeshPortRange allow
+// any port, leaving the range to their NetworkPolicies. A source without
+// principals, e.g. because none of its pods are running, gets no rule as a
+// rule without principals would allow any source.
+func (b MeshBuilder) BuildAuthorizationPolicy(destinationGUID string, policies []Policy, principals map[string][]string, allowedNamespaces []string) istiosecurityv1beta1.AuthorizationPolicy {
+	rules := []*istiosecurity.Rule{}
+	for _, policy := range policies {
+		sourcePrincipals := principals[policy.Source.ID]
+		if policy.Destination.Protocol != "tcp" || len(sourcePrincipals) == 0 {
+			continue
+		}
+		rule := &istiosecurity.Rule{
+			From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{Principals: sourcePrincipals}}},
+		}
+		if policy.Destination.Ports.End-policy.Destination.Ports.Start < MaxMeshPortRange {
+			ports := []string{}
+			for port := policy.Destination.Ports.Start; port <= policy.Destination.Ports.End; port++ {
+				ports = append(ports, strconv.Itoa(port))
+			}
+			rule.To = []*istiosecurity.Rule_To{{Operation: &istiosecurity.Operation{Ports: ports}}}
+		}
+		rules = append(rules, rule)
+	}
+	if len(allowedNamespaces) > 0 {
+		rules = append(rules, &istiosecurity.Rule{
+			From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{Namespaces: allowedNamespaces}}},
+		})
+	}
+
+	return istiosecurityv1beta1.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AuthorizationPolicyName(destinationGUID),
+			Namespace: b.Namespace,
+			Labels: map[string]string{
+				ManagedByLabel:          ManagedBy,
+				DestinationAppGUIDLabel: destinationGUID,
+			},
+		},
+		Spec: istiosecurityv1beta1.AuthorizationPolicySpec{
+			AuthorizationPolicy: istiosecurity.AuthorizationPolicy{
+				Selector: &istiotype.WorkloadSelector{
+					MatchLabels: map[string]string{AppGUIDLabel: destinationGUID},
+				},
+				Action: istiosecurity.AuthorizationPolicy_ALLOW,
+				Rules:  rules,
+			},
+		},
+	}
+}
+
+// BuildSidecar limits the services visible to the sidecar of a source app to
+// the services of the destination apps of its policies, the services in the
+// allowed namespaces, such as the gateways, and the hosts of its own
+// namespace, which hold the ServiceEntries of internal routes
+func (b MeshBuilder) BuildSidecar(sourceGUID string, destinationServices []corev1.Service, allowedNamespaces []string) istionetworkingv1alpha3.Sidecar {
+	hosts := []string{"./*"}
+	for _, namespace := range allowedNamespaces {
+		hosts = append(hosts, namespace+"/*")
+	}
+	for _, service := range destinationServices {
+		namespace := service.ObjectMeta.Namespace
+		hosts = append(hosts, fmt.Sprintf("%s/%s.%s.svc.cluster.local", namespace, service.ObjectMeta.Name, namespace))
+	}
+	var outboundTrafficPolicy *istiov1alpha3.OutboundTrafficPolicy
+	if b.DefaultDenyEgress {
+		hosts = append(hosts, fmt.Sprintf("%s/*.%s", b.Namespace, SecurityGroupHostSuffix))
+		outboundTrafficPolicy = &istiov1alpha3.OutboundTrafficPolicy{Mode: istiov1alpha3.OutboundTrafficPolicy_REGISTRY_ONLY}
+	}
+	sort.Strings(hosts)
+
+	return istionetworkingv1alpha3.Sidecar{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SidecarName(sourceGUID),
+			Namespace: b.Namespace,
+			Labels: map[string]string{
+				ManagedByLabel:     ManagedBy,
+				SourceAppGUIDLabel: sourceGUID,
+			},
+		},
+		Spec: istionetworkingv1alpha3.SidecarSpec{
+			Sidecar: istiov1alpha3.Sidecar{
+				WorkloadSelector: &istiov1alpha3.WorkloadSelector{
+					Labels: map[string]string{AppGUIDLabel: sourceGUID},
+				},
+				Egress:                []*istiov1alpha3.IstioEgressListener{{Hosts: hosts}},
+				OutboundTrafficPolicy: outboundTrafficPolicy,
+			},
+		},
+	}
+}
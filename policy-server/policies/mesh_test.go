@@ -0,0 +1,154 @@
+package policies_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	istiosecurity "istio.io/api/security/v1beta1"
+	istiotype "istio.io/api/type/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("MeshBuilder", func() {
+	var builder policies.MeshBuilder
+
+	newPolicy := func(source, protocol string, start, end int) policies.Policy {
+		return policies.Policy{
+			Source: policies.Source{ID: source},
+			Destination: policies.Destination{
+				ID:       "destination-app-guid",
+				Protocol: protocol,
+				Ports:    policies.Ports{Start: start, End: end},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		builder = policies.MeshBuilder{Namespace: "cf-workloads", TrustDomain: "cluster.local"}
+	})
+
+	It("builds principals from the trust domain and service account", func() {
+		Expect(builder.Principal("cf-workloads", "app-sa")).To(Equal("cluster.local/ns/cf-workloads/sa/app-sa"))
+	})
+
+	Describe("BuildAuthorizationPolicy", func() {
+		It("allows the principals of each source on the ports of its policy and the allowed namespaces", func() {
+			authorizationPolicy := builder.BuildAuthorizationPolicy("destination-app-guid",
+				[]policies.Policy{
+					newPolicy("source-a", "tcp", 8080, 8081),
+					newPolicy("source-b", "tcp", 9000, 9000),
+				},
+				map[string][]string{
+					"source-a": {"cluster.local/ns/cf-workloads/sa/source-a"},
+					"source-b": {"cluster.local/ns/cf-workloads/sa/source-b"},
+				},
+				[]string{"istio-system"},
+			)
+
+			Expect(authorizationPolicy.ObjectMeta.Name).To(Equal(policies.AuthorizationPolicyName("destination-app-guid")))
+			Expect(authorizationPolicy.ObjectMeta.Namespace).To(Equal("cf-workloads"))
+			Expect(authorizationPolicy.ObjectMeta.Labels).To(Equal(map[string]string{
+				"app.kubernetes.io/managed-by":                 "cf-k8s-networking-policy-server",
+				"cloudfoundry.org/policy_destination_app_guid": "destination-app-guid",
+			}))
+			Expect(authorizationPolicy.Spec.AuthorizationPolicy).To(Equal(istiosecurity.AuthorizationPolicy{
+				Selector: &istiotype.WorkloadSelector{
+					MatchLabels: map[string]string{"cloudfoundry.org/app_guid": "destination-app-guid"},
+				},
+				Action: istiosecurity.AuthorizationPolicy_ALLOW,
+				Rules: []*istiosecurity.Rule{
+					{
+						From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{Principals: []string{"cluster.local/ns/cf-workloads/sa/source-a"}}}},
+						To:   []*istiosecurity.Rule_To{{Operation: &istiosecurity.Operation{Ports: []string{"8080", "8081"}}}},
+					},
+					{
+						From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{Principals: []string{"cluster.local/ns/cf-workloads/sa/source-b"}}}},
+						To:   []*istiosecurity.Rule_To{{Operation: &istiosecurity.Operation{Ports: []string{"9000"}}}},
+					},
+					{
+						From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{Namespaces: []string{"istio-system"}}}},
+					},
+				},
+			}))
+		})
+
+		It("leaves the ports of wide ranges to the NetworkPolicy", func() {
+			authorizationPolicy := builder.BuildAuthorizationPolicy("destination-app-guid",
+				[]policies.Policy{newPolicy("source-a", "tcp", 1024, 65535)},
+				map[string][]string{"source-a": {"cluster.local/ns/cf-workloads/sa/source-a"}},
+				nil,
+			)
+
+			Expect(authorizationPolicy.Spec.Rules).To(Equal([]*istiosecurity.Rule{
+				{From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{Principals: []string{"cluster.local/ns/cf-workloads/sa/source-a"}}}}},
+			}))
+		})
+
+		It("skips UDP policies and sources without principals", func() {
+			authorizationPolicy := builder.BuildAuthorizationPolicy("destination-app-guid",
+				[]policies.Policy{
+					newPolicy("source-a", "udp", 8080, 8080),
+					newPolicy("source-b", "tcp", 8080, 8080),
+				},
+				map[string][]string{"source-a": {"cluster.local/ns/cf-workloads/sa/source-a"}},
+				nil,
+			)
+
+			Expect(authorizationPolicy.Spec.Action).To(Equal(istiosecurity.AuthorizationPolicy_ALLOW))
+			Expect(authorizationPolicy.Spec.Rules).To(BeEmpty())
+		})
+	})
+
+	Describe("BuildSidecar", func() {
+		It("only makes the services of the destinations and the allowed namespaces visible", func() {
+			services := []corev1.Service{
+				{ObjectMeta: metav1.ObjectMeta{Name: "s-destination", Namespace: "cf-workloads"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "s-other", Namespace: "other-space"}},
+			}
+
+			sidecar := builder.BuildSidecar("source-app-guid", services, []string{"istio-system"})
+
+			Expect(sidecar.ObjectMeta.Name).To(Equal(policies.SidecarName("source-app-guid")))
+			Expect(sidecar.ObjectMeta.Namespace).To(Equal("cf-workloads"))
+			Expect(sidecar.ObjectMeta.Labels).To(Equal(map[string]string{
+				"app.kubernetes.io/managed-by":            "cf-k8s-networking-policy-server",
+				"cloudfoundry.org/policy_source_app_guid": "source-app-guid",
+			}))
+			Expect(sidecar.Spec.Sidecar).To(Equal(istiov1alpha3.Sidecar{
+				WorkloadSelector: &istiov1alpha3.WorkloadSelector{
+					Labels: map[string]string{"cloudfoundry.org/app_guid": "source-app-guid"},
+				},
+				Egress: []*istiov1alpha3.IstioEgressListener{
+					{Hosts: []string{
+						"./*",
+						"cf-workloads/s-destination.cf-workloads.svc.cluster.local",
+						"istio-system/*",
+						"other-space/s-other.other-space.svc.cluster.local",
+					}},
+				},
+			}))
+		})
+
+		It("keeps the internal routes of its own namespace visible without policies", func() {
+			sidecar := builder.BuildSidecar("source-app-guid", nil, nil)
+
+			Expect(sidecar.Spec.Egress[0].Hosts).To(Equal([]string{"./*"}))
+		})
+
+		It("only passes through connections to the mesh and the security groups with default deny egress", func() {
+			builder.DefaultDenyEgress = true
+
+			sidecar := builder.BuildSidecar("source-app-guid", nil, []string{"istio-system"})
+
+			Expect(sidecar.Spec.Egress[0].Hosts).To(Equal([]string{
+				"./*",
+				"cf-workloads/*.securitygroup.cf.internal",
+				"istio-system/*",
+			}))
+			Expect(sidecar.Spec.OutboundTrafficPolicy.Mode).To(Equal(istiov1alpha3.OutboundTrafficPolicy_REGISTRY_ONLY))
+		})
+	})
+})
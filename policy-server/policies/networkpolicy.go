@@ -0,0 +1,142 @@
+package policies
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AppGUIDLabel selects the pods of an app. Services built for routes select
+// the same pods with it.
+const AppGUIDLabel = "cloudfoundry.org/app_guid"
+
+// Labels of the NetworkPolicies the policy server manages, so they can be
+// listed by app
+const (
+	ManagedByLabel          = "app.kubernetes.io/managed-by"
+	ManagedBy               = "cf-k8s-networking-policy-server"
+	SourceAppGUIDLabel      = "cloudfoundry.org/policy_source_app_guid"
+	DestinationAppGUIDLabel = "cloudfoundry.org/policy_destination_app_guid"
+)
+
+// PortsAnnotation holds the port range of the policy, e.g. "8080-8090", since
+// the end port of the range is not part of the typed NetworkPolicies
+const PortsAnnotation = "cloudfoundry.org/policy_ports"
+
+// Translator converts between policies and the NetworkPolicies storing them
+type Translator struct {
+	// Namespace the apps and their NetworkPolicies are in
+	Namespace string
+	// A NetworkPolicy denies every connection to the pods it selects that it
+	// does not allow, so each one also allows the namespaces selected by this,
+	// e.g. the one of the ingress gateway. Without it routes to the
+	// destination app stop working.
+	AllowedNamespaces *metav1.LabelSelector
+}
+
+// NetworkPolicyName is the same for equal policies, so a policy is only
+// stored once
+func NetworkPolicyName(p Policy) string {
+	sum := sha256.Sum256([]byte(p.String()))
+	return fmt.Sprintf("np-%x", sum)
+}
+
+// ToNetworkPolicy allows ingress to the pods of the destination app from the
+// pods of the source app on the ports of the policy. The port range is a single
+// NetworkPolicyPort, but its endPort is newer than the Kubernetes API types the
+// policy server is built with, so it is set by EndPortPatch.
+func (t Translator) ToNetworkPolicy(p Policy) networkingv1.NetworkPolicy {
+	protocol := corev1.Protocol(strings.ToUpper(p.Destination.Protocol))
+	port := intstr.FromInt(p.Destination.Ports.Start)
+
+	networkPolicy := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      NetworkPolicyName(p),
+			Namespace: t.Namespace,
+			Labels: map[string]string{
+				ManagedByLabel:          ManagedBy,
+				SourceAppGUIDLabel:      p.Source.ID,
+				DestinationAppGUIDLabel: p.Destination.ID,
+			},
+			Annotations: map[string]string{
+				PortsAnnotation: fmt.Sprintf("%d-%d", p.Destination.Ports.Start, p.Destination.Ports.End),
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{AppGUIDLabel: p.Destination.ID},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							PodSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{AppGUIDLabel: p.Source.ID},
+							},
+						},
+					},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &protocol, Port: &port}},
+				},
+			},
+		},
+	}
+
+	if t.AllowedNamespaces != nil {
+		networkPolicy.Spec.Ingress = append(networkPolicy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{NamespaceSelector: t.AllowedNamespaces.DeepCopy()},
+			},
+		})
+	}
+
+	return networkPolicy
+}
+
+// EndPortPatch sets the endPort of the range of the NetworkPolicy of
+// ToNetworkPolicy. Clusters without support for endPort drop it and only allow
+// the first port of the range.
+func EndPortPatch(p Policy) client.Patch {
+	return client.RawPatch(types.JSONPatchType, []byte(fmt.Sprintf(
+		`[{"op": "add", "path": "/spec/ingress/0/ports/0/endPort", "value": %d}]`, p.Destination.Ports.End)))
+}
+
+// FromNetworkPolicy reads the policy a NetworkPolicy was created for. It
+// returns false for NetworkPolicies that were not created by ToNetworkPolicy.
+func FromNetworkPolicy(networkPolicy networkingv1.NetworkPolicy) (Policy, bool) {
+	labels := networkPolicy.ObjectMeta.Labels
+	if labels[ManagedByLabel] != ManagedBy || len(networkPolicy.Spec.Ingress) == 0 {
+		return Policy{}, false
+	}
+
+	rule := networkPolicy.Spec.Ingress[0]
+	if len(rule.Ports) == 0 || rule.Ports[0].Protocol == nil {
+		return Policy{}, false
+	}
+
+	policy := Policy{
+		Source: Source{ID: labels[SourceAppGUIDLabel]},
+		Destination: Destination{
+			ID:       labels[DestinationAppGUIDLabel],
+			Protocol: strings.ToLower(string(*rule.Ports[0].Protocol)),
+		},
+	}
+	// the endPort of the range is not part of the NetworkPolicy types this
+	// client reads, so the range is only known from the annotation
+	ports, ok := networkPolicy.ObjectMeta.Annotations[PortsAnnotation]
+	if !ok {
+		return Policy{}, false
+	}
+	if _, err := fmt.Sscanf(ports, "%d-%d", &policy.Destination.Ports.Start, &policy.Destination.Ports.End); err != nil {
+		return Policy{}, false
+	}
+
+	return policy, true
+}
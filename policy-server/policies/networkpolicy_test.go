@@ -0,0 +1,120 @@
+package policies_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("NetworkPolicy translation", func() {
+	var (
+		policy     policies.Policy
+		translator policies.Translator
+	)
+
+	BeforeEach(func() {
+		policy = policies.Policy{
+			Source: policies.Source{ID: "source-app-guid"},
+			Destination: policies.Destination{
+				ID:       "destination-app-guid",
+				Protocol: "tcp",
+				Ports:    policies.Ports{Start: 8080, End: 8081},
+			},
+		}
+		translator = policies.Translator{Namespace: "cf-workloads"}
+	})
+
+	It("allows the source app's pods to reach the destination app's pods on the ports", func() {
+		networkPolicy := translator.ToNetworkPolicy(policy)
+
+		Expect(networkPolicy.ObjectMeta.Name).To(Equal(policies.NetworkPolicyName(policy)))
+		Expect(networkPolicy.ObjectMeta.Namespace).To(Equal("cf-workloads"))
+		Expect(networkPolicy.ObjectMeta.Labels).To(Equal(map[string]string{
+			"app.kubernetes.io/managed-by":                 "cf-k8s-networking-policy-server",
+			"cloudfoundry.org/policy_source_app_guid":      "source-app-guid",
+			"cloudfoundry.org/policy_destination_app_guid": "destination-app-guid",
+		}))
+		Expect(networkPolicy.ObjectMeta.Annotations).To(Equal(map[string]string{
+			"cloudfoundry.org/policy_ports": "8080-8081",
+		}))
+
+		tcp := corev1.ProtocolTCP
+		port8080 := intstr.FromInt(8080)
+		Expect(networkPolicy.Spec).To(Equal(networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"cloudfoundry.org/app_guid": "destination-app-guid"},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"cloudfoundry.org/app_guid": "source-app-guid"}}},
+					},
+					Ports: []networkingv1.NetworkPolicyPort{
+						{Protocol: &tcp, Port: &port8080},
+					},
+				},
+			},
+		}))
+	})
+
+	It("patches the end of the range into the endPort of the NetworkPolicy", func() {
+		patch := policies.EndPortPatch(policy)
+
+		Expect(patch.Type()).To(Equal(types.JSONPatchType))
+		data, err := patch.Data(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(`[{"op": "add", "path": "/spec/ingress/0/ports/0/endPort", "value": 8081}]`))
+	})
+
+	It("also allows the allowed namespaces, so routes keep working", func() {
+		translator.AllowedNamespaces = &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "istio-system"}}
+
+		networkPolicy := translator.ToNetworkPolicy(policy)
+
+		Expect(networkPolicy.Spec.Ingress).To(HaveLen(2))
+		Expect(networkPolicy.Spec.Ingress[1]).To(Equal(networkingv1.NetworkPolicyIngressRule{
+			From: []networkingv1.NetworkPolicyPeer{
+				{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "istio-system"}}},
+			},
+		}))
+	})
+
+	It("names equal policies the same and different policies differently", func() {
+		other := policy
+		other.Destination.Protocol = "udp"
+
+		Expect(policies.NetworkPolicyName(policy)).To(Equal(policies.NetworkPolicyName(policy)))
+		Expect(policies.NetworkPolicyName(policy)).NotTo(Equal(policies.NetworkPolicyName(other)))
+		Expect(policies.NetworkPolicyName(policy)).To(MatchRegexp(`^np-[0-9a-f]{64}$`))
+	})
+
+	It("reads the policy back from the NetworkPolicy", func() {
+		policy.Destination.Protocol = "udp"
+		read, ok := policies.FromNetworkPolicy(translator.ToNetworkPolicy(policy))
+		Expect(ok).To(BeTrue())
+		Expect(read).To(Equal(policy))
+	})
+
+	It("ignores NetworkPolicies without the range of their ports", func() {
+		networkPolicy := translator.ToNetworkPolicy(policy)
+		delete(networkPolicy.ObjectMeta.Annotations, "cloudfoundry.org/policy_ports")
+
+		_, ok := policies.FromNetworkPolicy(networkPolicy)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("ignores NetworkPolicies it did not create", func() {
+		networkPolicy := translator.ToNetworkPolicy(policy)
+		delete(networkPolicy.ObjectMeta.Labels, "app.kubernetes.io/managed-by")
+
+		_, ok := policies.FromNetworkPolicy(networkPolicy)
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,73 @@
+package policies
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Policies are the request and response bodies of the CF Network Policy API
+// v1 external endpoints
+type Policies struct {
+	TotalPolicies *int     `json:"total_policies,omitempty"`
+	Policies      []Policy `json:"policies"`
+}
+
+// Policy allows the source app to reach the destination app on the given
+// protocol and ports
+type Policy struct {
+	Source      Source      `json:"source"`
+	Destination Destination `json:"destination"`
+}
+
+type Source struct {
+	ID string `json:"id"`
+}
+
+type Destination struct {
+	ID       string `json:"id"`
+	Protocol string `json:"protocol"`
+	Ports    Ports  `json:"ports"`
+}
+
+// Ports is an inclusive range of ports
+type Ports struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Validate returns an error describing the first invalid field of the policy
+func (p Policy) Validate() error {
+	if p.Source.ID == "" {
+		return fmt.Errorf("missing source id")
+	}
+	if p.Destination.ID == "" {
+		return fmt.Errorf("missing destination id")
+	}
+	// ids are stored in labels of the NetworkPolicy
+	if errs := validation.IsValidLabelValue(p.Source.ID); len(errs) > 0 {
+		return fmt.Errorf("invalid source id %q: %s", p.Source.ID, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(p.Destination.ID); len(errs) > 0 {
+		return fmt.Errorf("invalid destination id %q: %s", p.Destination.ID, strings.Join(errs, ", "))
+	}
+	if p.Destination.Protocol != "tcp" && p.Destination.Protocol != "udp" {
+		return fmt.Errorf("invalid destination protocol %q, specify either tcp or udp", p.Destination.Protocol)
+	}
+
+	ports := p.Destination.Ports
+	if ports.Start < 1 || ports.Start > 65535 || ports.End < 1 || ports.End > 65535 {
+		return fmt.Errorf("invalid destination ports %d-%d, ports must be between 1 and 65535", ports.Start, ports.End)
+	}
+	if ports.Start > ports.End {
+		return fmt.Errorf("invalid destination ports %d-%d, start must not be greater than end", ports.Start, ports.End)
+	}
+
+	return nil
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%s -> %s %s:%d-%d", p.Source.ID, p.Destination.ID,
+		strings.ToLower(p.Destination.Protocol), p.Destination.Ports.Start, p.Destination.Ports.End)
+}
@@ -0,0 +1,68 @@
+package policies_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+)
+
+var _ = Describe("Policy", func() {
+	var policy policies.Policy
+
+	BeforeEach(func() {
+		policy = policies.Policy{
+			Source: policies.Source{ID: "source-app-guid"},
+			Destination: policies.Destination{
+				ID:       "destination-app-guid",
+				Protocol: "tcp",
+				Ports:    policies.Ports{Start: 8080, End: 8081},
+			},
+		}
+	})
+
+	Describe("Validate", func() {
+		It("accepts valid policies", func() {
+			Expect(policy.Validate()).To(Succeed())
+		})
+
+		It("requires the source and destination ids", func() {
+			policy.Source.ID = ""
+			Expect(policy.Validate()).To(MatchError("missing source id"))
+
+			policy.Source.ID = "source-app-guid"
+			policy.Destination.ID = ""
+			Expect(policy.Validate()).To(MatchError("missing destination id"))
+		})
+
+		It("requires tcp or udp", func() {
+			policy.Destination.Protocol = "icmp"
+			Expect(policy.Validate()).To(MatchError(`invalid destination protocol "icmp", specify either tcp or udp`))
+		})
+
+		It("requires a valid port range", func() {
+			policy.Destination.Ports = policies.Ports{Start: 0, End: 8080}
+			Expect(policy.Validate()).To(MatchError("invalid destination ports 0-8080, ports must be between 1 and 65535"))
+
+			policy.Destination.Ports = policies.Ports{Start: 8081, End: 8080}
+			Expect(policy.Validate()).To(MatchError("invalid destination ports 8081-8080, start must not be greater than end"))
+
+		})
+
+		It("accepts any range, like the cf CLI", func() {
+			policy.Destination.Ports = policies.Ports{Start: 1, End: 65535}
+			Expect(policy.Validate()).To(Succeed())
+		})
+
+		It("requires ids that can be label values", func() {
+			policy.Source.ID = "not/a guid"
+			Expect(policy.Validate()).To(MatchError(ContainSubstring(`invalid source id "not/a guid": a valid label must be`)))
+
+			policy.Source.ID = "source-app-guid"
+			policy.Destination.ID = strings.Repeat("a", 64)
+			Expect(policy.Validate()).To(MatchError(ContainSubstring("invalid destination id")))
+		})
+	})
+})
@@ -0,0 +1,244 @@
+package policies
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/istio/networking/v1alpha3"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/networking/v1alpha1"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Labels of app pods selecting the security groups bound to their org and
+// space
+const (
+	OrgGUIDLabel   = "cloudfoundry.org/org_guid"
+	SpaceGUIDLabel = "cloudfoundry.org/space_guid"
+)
+
+// SecurityGroupLabel is the name of the SecurityGroup a NetworkPolicy or
+// ServiceEntry was built for
+const SecurityGroupLabel = "cloudfoundry.org/security_group"
+
+// SecurityGroupHostSuffix is the domain of the hosts of the ServiceEntries
+// built for SecurityGroups. They are only names, the ServiceEntries match
+// connections by address.
+const SecurityGroupHostSuffix = "securitygroup.cf.internal"
+
+// DefaultDenyEgressName is the name of the NetworkPolicy and Sidecar denying
+// egress of apps not allowed by a SecurityGroup
+const DefaultDenyEgressName = "default-deny-egress"
+
+// EgressBuilder builds the resources restricting egress of apps to what their
+// SecurityGroups allow
+type EgressBuilder struct {
+	// Namespace the apps are in, where the resources are created
+	Namespace string
+	// Apps can always reach the namespaces selected by this, e.g. the one of
+	// istiod and the gateways
+	AllowedNamespaces *metav1.LabelSelector
+}
+
+// SecurityGroupResourceName is the name of the resource built for the part of
+// a SecurityGroup, e.g. its space bindings or one of its rules
+func SecurityGroupResourceName(securityGroup, part string) string {
+	sum := sha256.Sum256([]byte(securityGroup + "/" + part))
+	return fmt.Sprintf("sg-%x", sum)
+}
+
+// BuildNetworkPolicies allows egress to the rules of the SecurityGroup from
+// the pods of the apps in its orgs and in its spaces. A NetworkPolicy can only
+// select pods by one of the labels, so each binding gets its own.
+//
+// Once a NetworkPolicy restricts egress of a pod, everything it doesn't allow
+// is denied, so they also allow the egress every app needs.
+func (b EgressBuilder) BuildNetworkPolicies(securityGroup networkingv1alpha1.SecurityGroup) []networkingv1.NetworkPolicy {
+	egress := []networkingv1.NetworkPolicyEgressRule{}
+	for _, rule := range securityGroup.Spec.Rules {
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: rule.Destination}}},
+			Ports: networkPolicyPorts(rule),
+		})
+	}
+	egress = append(egress, b.baselineEgress()...)
+
+	networkPolicies := []networkingv1.NetworkPolicy{}
+	bindings := []struct {
+		label string
+		guids []string
+	}{
+		{OrgGUIDLabel, securityGroup.Spec.Bindings.Orgs},
+		{SpaceGUIDLabel, securityGroup.Spec.Bindings.Spaces},
+	}
+	for _, binding := range bindings {
+		if len(binding.guids) == 0 {
+			continue
+		}
+		networkPolicies = append(networkPolicies, networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      SecurityGroupResourceName(securityGroup.ObjectMeta.Name, binding.label),
+				Namespace: b.Namespace,
+				Labels: map[string]string{
+					ManagedByLabel:     ManagedBy,
+					SecurityGroupLabel: securityGroup.ObjectMeta.Name,
+				},
+			},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: binding.label, Operator: metav1.LabelSelectorOpIn, Values: binding.guids},
+					},
+				},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      egress,
+			},
+		})
+	}
+	return networkPolicies
+}
+
+// BuildServiceEntries adds the destinations of the TCP rules of the
+// SecurityGroup to the mesh, so sidecars denying egress to destinations
+// outside of it still pass their connections through. Envoy doesn't proxy
+// UDP, and a ServiceEntry can't cover all ports, so the other rules are only
+// enforced by the NetworkPolicies.
+//
+// The ServiceEntries are visible to every app in the namespace, which app can
+// reach them is enforced by the NetworkPolicies.
+func (b EgressBuilder) BuildServiceEntries(securityGroup networkingv1alpha1.SecurityGroup) []istionetworkingv1alpha3.ServiceEntry {
+	serviceEntries := []istionetworkingv1alpha3.ServiceEntry{}
+	for _, rule := range securityGroup.Spec.Rules {
+		if rule.Protocol == networkingv1alpha1.ProtocolUDP || len(rule.Ports) == 0 {
+			continue
+		}
+
+		name := SecurityGroupResourceName(securityGroup.ObjectMeta.Name, ruleString(rule))
+		ports := []*istiov1alpha3.Port{}
+		for _, port := range rule.Ports {
+			ports = append(ports, &istiov1alpha3.Port{
+				Number:   uint32(port),
+				Protocol: "TCP",
+				Name:     fmt.Sprintf("tcp-%d", port),
+			})
+		}
+		serviceEntries = append(serviceEntries, istionetworkingv1alpha3.ServiceEntry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: b.Namespace,
+				Labels: map[string]string{
+					ManagedByLabel:     ManagedBy,
+					SecurityGroupLabel: securityGroup.ObjectMeta.Name,
+				},
+			},
+			Spec: istionetworkingv1alpha3.ServiceEntrySpec{
+				ServiceEntry: istiov1alpha3.ServiceEntry{
+					Hosts:      []string{fmt.Sprintf("%s.%s", name, SecurityGroupHostSuffix)},
+					Addresses:  []string{rule.Destination},
+					Ports:      ports,
+					Location:   istiov1alpha3.ServiceEntry_MESH_EXTERNAL,
+					Resolution: istiov1alpha3.ServiceEntry_NONE,
+					ExportTo:   []string{"."},
+				},
+			},
+		})
+	}
+	return serviceEntries
+}
+
+// BuildDefaultDenyNetworkPolicy restricts egress of every app to the egress
+// every app needs, so apps only reach anything else through their
+// SecurityGroups
+func (b EgressBuilder) BuildDefaultDenyNetworkPolicy() networkingv1.NetworkPolicy {
+	return networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultDenyEgressName,
+			Namespace: b.Namespace,
+			Labels:    map[string]string{ManagedByLabel: ManagedBy},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: AppGUIDLabel, Operator: metav1.LabelSelectorOpExists},
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      b.baselineEgress(),
+		},
+	}
+}
+
+// BuildDefaultDenySidecar makes the sidecars of apps without their own
+// Sidecar only pass through connections to destinations in the mesh. Apps
+// with policies get the same outbound traffic policy from their own Sidecar.
+func (b EgressBuilder) BuildDefaultDenySidecar() istionetworkingv1alpha3.Sidecar {
+	return istionetworkingv1alpha3.Sidecar{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DefaultDenyEgressName,
+			Namespace: b.Namespace,
+			Labels:    map[string]string{ManagedByLabel: ManagedBy},
+		},
+		Spec: istionetworkingv1alpha3.SidecarSpec{
+			Sidecar: istiov1alpha3.Sidecar{
+				Egress: []*istiov1alpha3.IstioEgressListener{{Hosts: []string{"*/*"}}},
+				OutboundTrafficPolicy: &istiov1alpha3.OutboundTrafficPolicy{
+					Mode: istiov1alpha3.OutboundTrafficPolicy_REGISTRY_ONLY,
+				},
+			},
+		},
+	}
+}
+
+// baselineEgress allows DNS, the other apps, whose ingress is restricted by
+// their policies, and the allowed namespaces
+func (b EgressBuilder) baselineEgress() []networkingv1.NetworkPolicyEgressRule {
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	dns := intstr.FromInt(53)
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &dns}, {Protocol: &tcp, Port: &dns}}},
+		{To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}},
+	}
+	if b.AllowedNamespaces != nil {
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: b.AllowedNamespaces.DeepCopy()}},
+		})
+	}
+	return egress
+}
+
+func networkPolicyPorts(rule networkingv1alpha1.SecurityGroupRule) []networkingv1.NetworkPolicyPort {
+	if rule.Protocol == networkingv1alpha1.ProtocolAll && len(rule.Ports) == 0 {
+		// no ports allow every protocol
+		return nil
+	}
+
+	protocols := []corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP}
+	switch rule.Protocol {
+	case networkingv1alpha1.ProtocolTCP:
+		protocols = []corev1.Protocol{corev1.ProtocolTCP}
+	case networkingv1alpha1.ProtocolUDP:
+		protocols = []corev1.Protocol{corev1.ProtocolUDP}
+	}
+
+	ports := []networkingv1.NetworkPolicyPort{}
+	for i := range protocols {
+		protocol := &protocols[i]
+		if len(rule.Ports) == 0 {
+			// no port allows every port of the protocol
+			ports = append(ports, networkingv1.NetworkPolicyPort{Protocol: protocol})
+			continue
+		}
+		for _, port := range rule.Ports {
+			portNumber := intstr.FromInt(int(port))
+			ports = append(ports, networkingv1.NetworkPolicyPort{Protocol: protocol, Port: &portNumber})
+		}
+	}
+	return ports
+}
+
+func ruleString(rule networkingv1alpha1.SecurityGroupRule) string {
+	return fmt.Sprintf("%s:%s:%v", rule.Protocol, rule.Destination, rule.Ports)
+}
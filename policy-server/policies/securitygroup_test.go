@@ -0,0 +1,171 @@
+package policies_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/policy-server/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("EgressBuilder", func() {
+	var (
+		builder       policies.EgressBuilder
+		securityGroup networkingv1alpha1.SecurityGroup
+		baseline      []networkingv1.NetworkPolicyEgressRule
+	)
+
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	port := func(number int) *intstr.IntOrString {
+		p := intstr.FromInt(number)
+		return &p
+	}
+
+	BeforeEach(func() {
+		allowedNamespaces := &metav1.LabelSelector{MatchLabels: map[string]string{"kubernetes.io/metadata.name": "istio-system"}}
+		builder = policies.EgressBuilder{Namespace: "cf-workloads", AllowedNamespaces: allowedNamespaces}
+		securityGroup = networkingv1alpha1.SecurityGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "public-https"},
+			Spec: networkingv1alpha1.SecurityGroupSpec{
+				Rules: []networkingv1alpha1.SecurityGroupRule{
+					{Protocol: "tcp", Destination: "0.0.0.0/0", Ports: []int32{443}},
+					{Protocol: "udp", Destination: "10.0.0.0/8"},
+				},
+				Bindings: networkingv1alpha1.SecurityGroupBindings{
+					Orgs:   []string{"org-guid"},
+					Spaces: []string{"space-a-guid", "space-b-guid"},
+				},
+			},
+		}
+		baseline = []networkingv1.NetworkPolicyEgressRule{
+			{Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: port(53)}, {Protocol: &tcp, Port: port(53)}}},
+			{To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}}},
+			{To: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: allowedNamespaces}}},
+		}
+	})
+
+	Describe("BuildNetworkPolicies", func() {
+		It("allows the rules and the baseline egress from the pods of each binding", func() {
+			networkPolicies := builder.BuildNetworkPolicies(securityGroup)
+			Expect(networkPolicies).To(HaveLen(2))
+
+			egress := append([]networkingv1.NetworkPolicyEgressRule{
+				{
+					To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "0.0.0.0/0"}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: port(443)}},
+				},
+				{
+					To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp}},
+				},
+			}, baseline...)
+
+			Expect(networkPolicies[0].ObjectMeta).To(Equal(metav1.ObjectMeta{
+				Name:      policies.SecurityGroupResourceName("public-https", "cloudfoundry.org/org_guid"),
+				Namespace: "cf-workloads",
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by":    "cf-k8s-networking-policy-server",
+					"cloudfoundry.org/security_group": "public-https",
+				},
+			}))
+			Expect(networkPolicies[0].Spec).To(Equal(networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "cloudfoundry.org/org_guid", Operator: metav1.LabelSelectorOpIn, Values: []string{"org-guid"}},
+					},
+				},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      egress,
+			}))
+
+			Expect(networkPolicies[1].ObjectMeta.Name).To(Equal(policies.SecurityGroupResourceName("public-https", "cloudfoundry.org/space_guid")))
+			Expect(networkPolicies[1].Spec.PodSelector.MatchExpressions).To(Equal([]metav1.LabelSelectorRequirement{
+				{Key: "cloudfoundry.org/space_guid", Operator: metav1.LabelSelectorOpIn, Values: []string{"space-a-guid", "space-b-guid"}},
+			}))
+			Expect(networkPolicies[1].Spec.Egress).To(Equal(egress))
+		})
+
+		It("allows every protocol for rules with protocol all and no ports", func() {
+			securityGroup.Spec.Rules = []networkingv1alpha1.SecurityGroupRule{
+				{Protocol: "all", Destination: "10.0.0.0/8"},
+				{Protocol: "all", Destination: "192.168.0.0/16", Ports: []int32{80}},
+			}
+
+			networkPolicies := builder.BuildNetworkPolicies(securityGroup)
+			Expect(networkPolicies[0].Spec.Egress[0].Ports).To(BeNil())
+			Expect(networkPolicies[0].Spec.Egress[1].Ports).To(Equal([]networkingv1.NetworkPolicyPort{
+				{Protocol: &tcp, Port: port(80)},
+				{Protocol: &udp, Port: port(80)},
+			}))
+		})
+
+		It("builds nothing for unbound security groups", func() {
+			securityGroup.Spec.Bindings = networkingv1alpha1.SecurityGroupBindings{}
+			Expect(builder.BuildNetworkPolicies(securityGroup)).To(BeEmpty())
+		})
+	})
+
+	Describe("BuildServiceEntries", func() {
+		It("adds the destinations of TCP rules with ports to the mesh", func() {
+			securityGroup.Spec.Rules = append(securityGroup.Spec.Rules,
+				networkingv1alpha1.SecurityGroupRule{Protocol: "tcp", Destination: "192.168.0.0/16"},
+				networkingv1alpha1.SecurityGroupRule{Protocol: "all", Destination: "172.16.0.0/12", Ports: []int32{80, 8080}},
+			)
+
+			serviceEntries := builder.BuildServiceEntries(securityGroup)
+			Expect(serviceEntries).To(HaveLen(2))
+
+			name := serviceEntries[0].ObjectMeta.Name
+			Expect(name).To(HavePrefix("sg-"))
+			Expect(serviceEntries[0].ObjectMeta.Namespace).To(Equal("cf-workloads"))
+			Expect(serviceEntries[0].ObjectMeta.Labels).To(HaveKeyWithValue("cloudfoundry.org/security_group", "public-https"))
+			Expect(serviceEntries[0].Spec.ServiceEntry).To(Equal(istiov1alpha3.ServiceEntry{
+				Hosts:      []string{name + ".securitygroup.cf.internal"},
+				Addresses:  []string{"0.0.0.0/0"},
+				Ports:      []*istiov1alpha3.Port{{Number: 443, Protocol: "TCP", Name: "tcp-443"}},
+				Location:   istiov1alpha3.ServiceEntry_MESH_EXTERNAL,
+				Resolution: istiov1alpha3.ServiceEntry_NONE,
+				ExportTo:   []string{"."},
+			}))
+
+			Expect(serviceEntries[1].ObjectMeta.Name).NotTo(Equal(name))
+			Expect(serviceEntries[1].Spec.Addresses).To(Equal([]string{"172.16.0.0/12"}))
+			Expect(serviceEntries[1].Spec.Ports).To(Equal([]*istiov1alpha3.Port{
+				{Number: 80, Protocol: "TCP", Name: "tcp-80"},
+				{Number: 8080, Protocol: "TCP", Name: "tcp-8080"},
+			}))
+		})
+	})
+
+	Describe("default deny", func() {
+		It("restricts egress of every app to the baseline", func() {
+			networkPolicy := builder.BuildDefaultDenyNetworkPolicy()
+
+			Expect(networkPolicy.ObjectMeta.Name).To(Equal("default-deny-egress"))
+			Expect(networkPolicy.ObjectMeta.Namespace).To(Equal("cf-workloads"))
+			Expect(networkPolicy.Spec).To(Equal(networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "cloudfoundry.org/app_guid", Operator: metav1.LabelSelectorOpExists},
+					},
+				},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      baseline,
+			}))
+		})
+
+		It("only lets sidecars pass through connections to the mesh", func() {
+			sidecar := builder.BuildDefaultDenySidecar()
+
+			Expect(sidecar.ObjectMeta.Name).To(Equal("default-deny-egress"))
+			Expect(sidecar.Spec.WorkloadSelector).To(BeNil())
+			Expect(sidecar.Spec.Egress).To(Equal([]*istiov1alpha3.IstioEgressListener{{Hosts: []string{"*/*"}}}))
+			Expect(sidecar.Spec.OutboundTrafficPolicy.Mode).To(Equal(istiov1alpha3.OutboundTrafficPolicy_REGISTRY_ONLY))
+		})
+	})
+})
@@ -0,0 +1,96 @@
+package policies
+
+import (
+	"context"
+	"sort"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Store keeps policies as NetworkPolicies, the API server is the only source
+// of truth so the policy server itself is stateless
+type Store struct {
+	Client     client.Client
+	Translator Translator
+}
+
+// List returns every policy, or the policies whose source or destination is
+// one of the given app guids
+func (s *Store) List(ctx context.Context, appGUIDs []string) ([]Policy, error) {
+	networkPolicies := []networkingv1.NetworkPolicy{}
+	if len(appGUIDs) == 0 {
+		list, err := s.list(ctx, client.MatchingLabels{ManagedByLabel: ManagedBy})
+		if err != nil {
+			return nil, err
+		}
+		networkPolicies = list
+	}
+	for _, guid := range appGUIDs {
+		for _, label := range []string{SourceAppGUIDLabel, DestinationAppGUIDLabel} {
+			list, err := s.list(ctx, client.MatchingLabels{ManagedByLabel: ManagedBy, label: guid})
+			if err != nil {
+				return nil, err
+			}
+			networkPolicies = append(networkPolicies, list...)
+		}
+	}
+
+	// a policy between two of the apps is listed for both of them
+	seen := map[string]bool{}
+	policies := []Policy{}
+	for _, networkPolicy := range networkPolicies {
+		if seen[networkPolicy.ObjectMeta.Name] {
+			continue
+		}
+		seen[networkPolicy.ObjectMeta.Name] = true
+		if policy, ok := FromNetworkPolicy(networkPolicy); ok {
+			policies = append(policies, policy)
+		}
+	}
+
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].String() < policies[j].String()
+	})
+	return policies, nil
+}
+
+func (s *Store) list(ctx context.Context, labels client.MatchingLabels) ([]networkingv1.NetworkPolicy, error) {
+	list := &networkingv1.NetworkPolicyList{}
+	if err := s.Client.List(ctx, list, client.InNamespace(s.Translator.Namespace), labels); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Create stores the policies, policies that already exist are left alone.
+// Until the end port of a range is patched, only its first port is allowed.
+func (s *Store) Create(ctx context.Context, policies []Policy) error {
+	for _, policy := range policies {
+		networkPolicy := s.Translator.ToNetworkPolicy(policy)
+		if err := s.Client.Create(ctx, &networkPolicy); err != nil && !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		if policy.Destination.Ports.End == policy.Destination.Ports.Start {
+			continue
+		}
+		if err := s.Client.Patch(ctx, &networkPolicy, EndPortPatch(policy)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the policies, policies that do not exist are ignored
+func (s *Store) Delete(ctx context.Context, policies []Policy) error {
+	for _, policy := range policies {
+		networkPolicy := &networkingv1.NetworkPolicy{}
+		networkPolicy.ObjectMeta.Name = NetworkPolicyName(policy)
+		networkPolicy.ObjectMeta.Namespace = s.Translator.Namespace
+		if err := s.Client.Delete(ctx, networkPolicy); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
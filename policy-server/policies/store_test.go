@@ -0,0 +1,99 @@
+package policies_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/policy-server/policies"
+	networkingv1 "k8s.io/api/networking/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		ctx       context.Context
+		k8sClient client.Client
+		store     *policies.Store
+	)
+
+	newPolicy := func(source, destination string, port int) policies.Policy {
+		return policies.Policy{
+			Source: policies.Source{ID: source},
+			Destination: policies.Destination{
+				ID:       destination,
+				Protocol: "tcp",
+				Ports:    policies.Ports{Start: port, End: port},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		k8sClient = fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+		store = &policies.Store{
+			Client:     k8sClient,
+			Translator: policies.Translator{Namespace: "cf-workloads"},
+		}
+	})
+
+	It("stores policies as NetworkPolicies in the workloads namespace", func() {
+		Expect(store.Create(ctx, []policies.Policy{newPolicy("app-a", "app-b", 8080)})).To(Succeed())
+
+		networkPolicies := &networkingv1.NetworkPolicyList{}
+		Expect(k8sClient.List(ctx, networkPolicies, client.InNamespace("cf-workloads"))).To(Succeed())
+		Expect(networkPolicies.Items).To(HaveLen(1))
+		Expect(networkPolicies.Items[0].Spec.PodSelector.MatchLabels).To(HaveKeyWithValue("cloudfoundry.org/app_guid", "app-b"))
+	})
+
+	It("creates existing policies only once", func() {
+		Expect(store.Create(ctx, []policies.Policy{newPolicy("app-a", "app-b", 8080)})).To(Succeed())
+		Expect(store.Create(ctx, []policies.Policy{newPolicy("app-a", "app-b", 8080)})).To(Succeed())
+
+		Expect(store.List(ctx, nil)).To(HaveLen(1))
+	})
+
+	Context("with several policies", func() {
+		BeforeEach(func() {
+			Expect(store.Create(ctx, []policies.Policy{
+				newPolicy("app-a", "app-b", 8080),
+				newPolicy("app-b", "app-c", 8080),
+				newPolicy("app-c", "app-d", 9000),
+			})).To(Succeed())
+		})
+
+		It("lists all of them", func() {
+			Expect(store.List(ctx, nil)).To(Equal([]policies.Policy{
+				newPolicy("app-a", "app-b", 8080),
+				newPolicy("app-b", "app-c", 8080),
+				newPolicy("app-c", "app-d", 9000),
+			}))
+		})
+
+		It("lists the policies of the given apps once", func() {
+			Expect(store.List(ctx, []string{"app-b", "app-c"})).To(Equal([]policies.Policy{
+				newPolicy("app-a", "app-b", 8080),
+				newPolicy("app-b", "app-c", 8080),
+				newPolicy("app-c", "app-d", 9000),
+			}))
+			Expect(store.List(ctx, []string{"app-a"})).To(Equal([]policies.Policy{
+				newPolicy("app-a", "app-b", 8080),
+			}))
+		})
+
+		It("deletes policies and ignores the ones that do not exist", func() {
+			Expect(store.Delete(ctx, []policies.Policy{
+				newPolicy("app-a", "app-b", 8080),
+				newPolicy("app-a", "app-b", 9999),
+			})).To(Succeed())
+
+			Expect(store.List(ctx, nil)).To(Equal([]policies.Policy{
+				newPolicy("app-b", "app-c", 8080),
+				newPolicy("app-c", "app-d", 9000),
+			}))
+		})
+	})
+})
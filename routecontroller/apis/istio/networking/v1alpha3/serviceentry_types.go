@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +kubebuilder:skip
+package v1alpha3
+
+import (
+	"bufio"
+	"bytes"
+
+	"github.com/gogo/protobuf/jsonpb"
+
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceEntrySpec defines the desired state of ServiceEntry
+type ServiceEntrySpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+	istiov1alpha3.ServiceEntry `json:",inline"`
+}
+
+// ServiceEntryStatus defines the observed state of ServiceEntry
+type ServiceEntryStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+// +kubebuilder:object:root=true
+
+// ServiceEntry is the Schema for the serviceentries API
+type ServiceEntry struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServiceEntrySpec   `json:"spec,omitempty"`
+	Status ServiceEntryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ServiceEntryList contains a list of ServiceEntry
+type ServiceEntryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServiceEntry `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServiceEntry{}, &ServiceEntryList{})
+}
+
+func (p *ServiceEntrySpec) MarshalJSON() ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := bufio.NewWriter(&buffer)
+	marshaler := jsonpb.Marshaler{}
+	err := marshaler.Marshal(writer, &p.ServiceEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), nil
+}
+
+func (p *ServiceEntrySpec) UnmarshalJSON(b []byte) error {
+	reader := bytes.NewReader(b)
+	unmarshaler := jsonpb.Unmarshaler{}
+	err := unmarshaler.Unmarshal(reader, &p.ServiceEntry)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +kubebuilder:skip
+package v1beta1
+
+import (
+	"bufio"
+	"bytes"
+
+	"github.com/gogo/protobuf/jsonpb"
+
+	istiov1beta1 "istio.io/api/security/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuthorizationPolicySpec defines the desired state of AuthorizationPolicy
+type AuthorizationPolicySpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+	istiov1beta1.AuthorizationPolicy `json:",inline"`
+}
+
+// AuthorizationPolicyStatus defines the observed state of AuthorizationPolicy
+type AuthorizationPolicyStatus struct {
+	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+// +kubebuilder:object:root=true
+
+// AuthorizationPolicy is the Schema for the authorizationpolicies API
+type AuthorizationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AuthorizationPolicySpec   `json:"spec,omitempty"`
+	Status AuthorizationPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AuthorizationPolicyList contains a list of AuthorizationPolicy
+type AuthorizationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AuthorizationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AuthorizationPolicy{}, &AuthorizationPolicyList{})
+}
+
+func (p *AuthorizationPolicySpec) MarshalJSON() ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := bufio.NewWriter(&buffer)
+	marshaler := jsonpb.Marshaler{}
+	err := marshaler.Marshal(writer, &p.AuthorizationPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), nil
+}
+
+func (p *AuthorizationPolicySpec) UnmarshalJSON(b []byte) error {
+	reader := bytes.NewReader(b)
+	unmarshaler := jsonpb.Unmarshaler{}
+	err := unmarshaler.Unmarshal(reader, &p.AuthorizationPolicy)
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CORS origins are a scheme, a host (optionally with a leading "*." wildcard) and an optional port
+var corsOriginPattern = regexp.MustCompile(`^https?://(\*\.)?[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]{1,5})?$`)
+
+// ValidateCorsPolicy returns an error when an origin of the Route's cors
+// policy can't be matched by Istio or its max age is negative. Browsers reject
+// credentialed requests allowed for any origin, so credentials can't be
+// allowed together with the "*" origin either.
+func (r Route) ValidateCorsPolicy() error {
+	policy := r.Spec.Cors
+	if policy == nil {
+		return nil
+	}
+
+	for _, origin := range policy.AllowOrigins {
+		if origin != "*" && !corsOriginPattern.MatchString(origin) {
+			return fmt.Errorf("invalid cors policy for route %s: origin %q must be \"*\" or of the form scheme://host[:port]", r.ObjectMeta.Name, origin)
+		}
+		if origin == "*" && policy.AllowCredentials != nil && *policy.AllowCredentials {
+			return fmt.Errorf("invalid cors policy for route %s: credentials must not be allowed for the origin \"*\"", r.ObjectMeta.Name)
+		}
+	}
+
+	if policy.MaxAge != nil && *policy.MaxAge < 0 {
+		return fmt.Errorf("invalid cors policy for route %s: max age must not be negative", r.ObjectMeta.Name)
+	}
+
+	return nil
+}
+
+// ValidatePolicies returns the first error of the Route's cors policy, rate
+// limit and source ranges. A Route with an invalid policy is left out of the
+// resources of its FQDN, so the other Routes of the FQDN are still updated.
+func (r Route) ValidatePolicies() error {
+	if err := r.ValidateCorsPolicy(); err != nil {
+		return err
+	}
+	if err := r.ValidateRateLimit(); err != nil {
+		return err
+	}
+	return r.ValidateSourceRanges()
+}
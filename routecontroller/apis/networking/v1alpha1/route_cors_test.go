@@ -0,0 +1,67 @@
+package v1alpha1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Route cors policies", func() {
+	var route v1alpha1.Route
+
+	BeforeEach(func() {
+		route = v1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "route-guid-0"},
+			Spec: v1alpha1.RouteSpec{
+				Host:   "test0",
+				Domain: v1alpha1.RouteDomain{Name: "domain0.example.com"},
+				Cors: &v1alpha1.RouteCorsPolicy{
+					AllowOrigins: []string{"*", "https://app.example.com", "http://*.example.com:8080"},
+				},
+			},
+		}
+	})
+
+	Describe("ValidateCorsPolicy", func() {
+		It("accepts wildcards, origins and wildcard subdomain origins", func() {
+			Expect(route.ValidateCorsPolicy()).To(Succeed())
+		})
+
+		It("rejects origins with a path", func() {
+			route.Spec.Cors.AllowOrigins = []string{"https://app.example.com/some/path"}
+
+			Expect(route.ValidateCorsPolicy()).To(MatchError(`invalid cors policy for route route-guid-0: origin "https://app.example.com/some/path" must be "*" or of the form scheme://host[:port]`))
+		})
+
+		It("rejects allowing credentials for any origin", func() {
+			allowCredentials := true
+			route.Spec.Cors.AllowCredentials = &allowCredentials
+
+			Expect(route.ValidateCorsPolicy()).To(MatchError(`invalid cors policy for route route-guid-0: credentials must not be allowed for the origin "*"`))
+
+			route.Spec.Cors.AllowOrigins = []string{"https://app.example.com", "http://*.example.com:8080"}
+			Expect(route.ValidateCorsPolicy()).To(Succeed())
+		})
+
+		It("rejects a negative max age", func() {
+			maxAge := -1
+			route.Spec.Cors.MaxAge = &maxAge
+
+			Expect(route.ValidateCorsPolicy()).To(MatchError("invalid cors policy for route route-guid-0: max age must not be negative"))
+		})
+	})
+
+	Describe("ValidatePolicies", func() {
+		It("returns the error of any invalid policy", func() {
+			Expect(route.ValidatePolicies()).To(Succeed())
+
+			route.Spec.DeniedSourceRanges = []string{"10.0.0.0/33"}
+			Expect(route.ValidatePolicies()).To(MatchError(`route guid route-guid-0 has denied source range "10.0.0.0/33", which is neither a CIDR nor an IP`))
+
+			route.Spec.RateLimit = &v1alpha1.RouteRateLimit{RequestsPerSecond: 0}
+			Expect(route.ValidatePolicies()).To(MatchError(ContainSubstring("rate limit of 0 requests per second")))
+		})
+	})
+})
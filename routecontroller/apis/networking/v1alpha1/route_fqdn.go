@@ -0,0 +1,89 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// FQDN is the canonical FQDN of the route, see CanonicalFQDN. Routes are
+// indexed and grouped by it, so hosts that only differ in case or encoding
+// share a VirtualService.
+func (r Route) FQDN() string {
+	return CanonicalFQDN(r.Spec.Host, r.Spec.Domain.Name)
+}
+
+// ValidateFQDN returns an error when the route's FQDN is not a valid
+// hostname: labels must be valid RFC 1123 labels of at most 63 characters
+// once converted to punycode, and the FQDN at most 253 characters. Istio only
+// supports a wildcard as the first label of a host, so the host of wildcard
+// routes must be exactly "*".
+func (r Route) ValidateFQDN() error {
+	if strings.Contains(r.Spec.Host, WildcardHost) && !r.IsWildcard() {
+		return fmt.Errorf("route guid %s has host %q, wildcard routes must have the host %q", r.ObjectMeta.Name, r.Spec.Host, WildcardHost)
+	}
+
+	fqdn := joinFQDN(r.Spec.Host, r.Spec.Domain.Name)
+	if _, err := idna.Lookup.ToASCII(strings.TrimPrefix(fqdn, WildcardHost+".")); err != nil {
+		return fmt.Errorf("route guid %s has FQDN %q, which is not a valid internationalized domain name: %s", r.ObjectMeta.Name, fqdn, err)
+	}
+
+	canonical := r.FQDN()
+	if errs := validation.IsDNS1123Subdomain(strings.TrimPrefix(canonical, WildcardHost+".")); len(errs) > 0 {
+		return fmt.Errorf("route guid %s has FQDN %q, which is not a valid hostname: %s", r.ObjectMeta.Name, canonical, strings.Join(errs, ", "))
+	}
+	for _, label := range strings.Split(canonical, ".") {
+		if label == WildcardHost {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(label); len(errs) > 0 {
+			return fmt.Errorf("route guid %s has FQDN %q, whose label %q is not valid: %s", r.ObjectMeta.Name, canonical, label, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// CanonicalFQDN joins a host and a domain into a lower case ASCII FQDN.
+// Internationalized names are converted to punycode and trailing dots are
+// removed. Names that cannot be converted are only lower cased, ValidateFQDN
+// reports them.
+func CanonicalFQDN(host, domain string) string {
+	fqdn := joinFQDN(host, domain)
+
+	wildcard := strings.HasPrefix(fqdn, WildcardHost+".")
+	name := strings.TrimPrefix(fqdn, WildcardHost+".")
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		ascii = strings.ToLower(name)
+	}
+
+	if wildcard {
+		return WildcardHost + "." + ascii
+	}
+	return ascii
+}
+
+func joinFQDN(host, domain string) string {
+	domain = strings.TrimRight(domain, ".")
+	if host == "" {
+		return domain
+	}
+	return fmt.Sprintf("%s.%s", host, domain)
+}
@@ -0,0 +1,73 @@
+package v1alpha1_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Route FQDNs", func() {
+	newRoute := func(host, domain string) v1alpha1.Route {
+		return v1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "route-guid-0"},
+			Spec: v1alpha1.RouteSpec{
+				Host:   host,
+				Domain: v1alpha1.RouteDomain{Name: domain},
+			},
+		}
+	}
+
+	Describe("FQDN", func() {
+		It("joins the host and the domain", func() {
+			Expect(newRoute("test0", "domain0.example.com").FQDN()).To(Equal("test0.domain0.example.com"))
+			Expect(newRoute("", "domain0.example.com").FQDN()).To(Equal("domain0.example.com"))
+		})
+
+		It("lower cases the FQDN and removes trailing dots", func() {
+			Expect(newRoute("App", "Domain0.Example.com.").FQDN()).To(Equal("app.domain0.example.com"))
+		})
+
+		It("converts internationalized names to punycode", func() {
+			Expect(newRoute("bücher", "Domain0.example.com").FQDN()).To(Equal("xn--bcher-kva.domain0.example.com"))
+			Expect(newRoute("xn--bcher-kva", "domain0.example.com").FQDN()).To(Equal("xn--bcher-kva.domain0.example.com"))
+		})
+
+		It("keeps the wildcard of wildcard routes", func() {
+			Expect(newRoute("*", "Bücher.example.com").FQDN()).To(Equal("*.xn--bcher-kva.example.com"))
+		})
+	})
+
+	Describe("ValidateFQDN", func() {
+		It("accepts valid and internationalized hostnames", func() {
+			Expect(newRoute("test0", "domain0.example.com").ValidateFQDN()).To(Succeed())
+			Expect(newRoute("Bücher", "domain0.example.com.").ValidateFQDN()).To(Succeed())
+			Expect(newRoute("*", "domain0.example.com").ValidateFQDN()).To(Succeed())
+		})
+
+		It("rejects labels longer than 63 characters", func() {
+			err := newRoute(strings.Repeat("a", 64), "domain0.example.com").ValidateFQDN()
+			Expect(err).To(MatchError(ContainSubstring("must be no more than 63 characters")))
+		})
+
+		It("rejects FQDNs longer than 253 characters", func() {
+			label := strings.Repeat("a", 63)
+			err := newRoute(strings.Join([]string{label, label, label, label}, "."), "example.com").ValidateFQDN()
+			Expect(err).To(MatchError(ContainSubstring("must be no more than 253 characters")))
+		})
+
+		It("rejects characters that are not allowed in hostnames", func() {
+			Expect(newRoute("test_0", "domain0.example.com").ValidateFQDN()).To(MatchError(ContainSubstring(`route guid route-guid-0 has FQDN "test_0.domain0.example.com"`)))
+			Expect(newRoute("test0", "domain0..example.com").ValidateFQDN()).NotTo(Succeed())
+		})
+
+		It("rejects wildcards anywhere but as the whole host", func() {
+			Expect(newRoute("*.test0", "domain0.example.com").ValidateFQDN()).To(MatchError(
+				`route guid route-guid-0 has host "*.test0", wildcard routes must have the host "*"`))
+			Expect(newRoute("test*", "domain0.example.com").ValidateFQDN()).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,91 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// DefaultRateLimitResponseCode is returned for requests over the rate limit of
+// a Route without a response code
+const DefaultRateLimitResponseCode = http.StatusTooManyRequests
+
+// RateLimitScopeClientPerGatewayReplica is the scope of rate limits counted by
+// a token bucket per client of the Route in each gateway replica
+const RateLimitScopeClientPerGatewayReplica = "ClientPerGatewayReplica"
+
+// RateLimitScopeRoutePerGatewayReplica is the scope of rate limits counted by
+// a token bucket per Route in each gateway replica, shared by every client
+const RateLimitScopeRoutePerGatewayReplica = "RoutePerGatewayReplica"
+
+// headerNamePattern matches the tokens RFC 7230 allows as header field names
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// EffectiveRateLimit is the rate limit the gateway enforces for the Route,
+// with its defaults applied. It is nil for routes without a rate limit and
+// for internal routes, which don't pass through the gateway.
+func (r Route) EffectiveRateLimit() *RouteRateLimitStatus {
+	rateLimit := r.Spec.RateLimit
+	if rateLimit == nil || r.Spec.Domain.Internal {
+		return nil
+	}
+
+	effective := &RouteRateLimitStatus{
+		RequestsPerSecond: rateLimit.RequestsPerSecond,
+		Burst:             rateLimit.RequestsPerSecond,
+		ResponseCode:      DefaultRateLimitResponseCode,
+		Scope:             RateLimitScopeClientPerGatewayReplica,
+		Header:            rateLimit.Header,
+	}
+	if rateLimit.PerClient != nil && !*rateLimit.PerClient {
+		effective.Scope = RateLimitScopeRoutePerGatewayReplica
+		effective.Header = ""
+	}
+	if rateLimit.Burst != nil {
+		effective.Burst = *rateLimit.Burst
+	}
+	if rateLimit.ResponseCode != nil {
+		effective.ResponseCode = *rateLimit.ResponseCode
+	}
+	return effective
+}
+
+// ValidateRateLimit returns an error when the Route's rate limit can't be
+// enforced. Envoy only returns known HTTP status codes, so response codes
+// without a standard reason phrase are rejected.
+func (r Route) ValidateRateLimit() error {
+	rateLimit := r.EffectiveRateLimit()
+	if rateLimit == nil {
+		return nil
+	}
+	if header := r.Spec.RateLimit.Header; header != "" {
+		if rateLimit.Scope != RateLimitScopeClientPerGatewayReplica {
+			return fmt.Errorf("route guid %s has rate limit header %s, which only tells clients apart for per client rate limits", r.ObjectMeta.Name, header)
+		}
+		if !headerNamePattern.MatchString(header) {
+			return fmt.Errorf("route guid %s has rate limit header %q, which is not a valid header name", r.ObjectMeta.Name, header)
+		}
+	}
+	if rateLimit.RequestsPerSecond < 1 || rateLimit.Burst < 1 {
+		return fmt.Errorf("route guid %s has rate limit of %d requests per second with a burst of %d, both must be at least 1", r.ObjectMeta.Name, rateLimit.RequestsPerSecond, rateLimit.Burst)
+	}
+	if rateLimit.ResponseCode < 400 || rateLimit.ResponseCode > 599 || http.StatusText(rateLimit.ResponseCode) == "" {
+		return fmt.Errorf("route guid %s has rate limit response code %d, which is not a known 4xx or 5xx status code", r.ObjectMeta.Name, rateLimit.ResponseCode)
+	}
+	return nil
+}
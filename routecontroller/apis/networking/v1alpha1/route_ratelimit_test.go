@@ -0,0 +1,130 @@
+package v1alpha1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Route rate limits", func() {
+	var route v1alpha1.Route
+
+	intPtr := func(i int) *int { return &i }
+
+	BeforeEach(func() {
+		route = v1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "route-guid-0"},
+			Spec: v1alpha1.RouteSpec{
+				Host:      "test0",
+				Domain:    v1alpha1.RouteDomain{Name: "domain0.example.com"},
+				RateLimit: &v1alpha1.RouteRateLimit{RequestsPerSecond: 10},
+			},
+		}
+	})
+
+	Describe("EffectiveRateLimit", func() {
+		It("defaults the burst to the requests per second and the response code to 429", func() {
+			Expect(route.EffectiveRateLimit()).To(Equal(&v1alpha1.RouteRateLimitStatus{
+				RequestsPerSecond: 10,
+				Burst:             10,
+				ResponseCode:      429,
+				Scope:             "ClientPerGatewayReplica",
+			}))
+		})
+
+		It("keeps the burst and response code that are set", func() {
+			route.Spec.RateLimit.Burst = intPtr(20)
+			route.Spec.RateLimit.ResponseCode = intPtr(503)
+
+			Expect(route.EffectiveRateLimit()).To(Equal(&v1alpha1.RouteRateLimitStatus{
+				RequestsPerSecond: 10,
+				Burst:             20,
+				ResponseCode:      503,
+				Scope:             "ClientPerGatewayReplica",
+			}))
+		})
+
+		It("shares one bucket between clients when the limit isn't per client", func() {
+			perClient := false
+			route.Spec.RateLimit.PerClient = &perClient
+			route.Spec.RateLimit.Header = "X-Api-Key"
+
+			Expect(route.EffectiveRateLimit()).To(Equal(&v1alpha1.RouteRateLimitStatus{
+				RequestsPerSecond: 10,
+				Burst:             10,
+				ResponseCode:      429,
+				Scope:             "RoutePerGatewayReplica",
+			}))
+		})
+
+		It("keeps the header telling clients apart", func() {
+			route.Spec.RateLimit.Header = "X-Api-Key"
+
+			Expect(route.EffectiveRateLimit().Header).To(Equal("X-Api-Key"))
+		})
+
+		It("is nil for routes without a rate limit", func() {
+			route.Spec.RateLimit = nil
+
+			Expect(route.EffectiveRateLimit()).To(BeNil())
+		})
+
+		It("is nil for internal routes", func() {
+			route.Spec.Domain.Internal = true
+
+			Expect(route.EffectiveRateLimit()).To(BeNil())
+		})
+	})
+
+	Describe("ValidateRateLimit", func() {
+		It("accepts known 4xx and 5xx response codes", func() {
+			route.Spec.RateLimit.ResponseCode = intPtr(503)
+
+			Expect(route.ValidateRateLimit()).To(Succeed())
+		})
+
+		It("rejects unknown response codes", func() {
+			route.Spec.RateLimit.ResponseCode = intPtr(499)
+
+			Expect(route.ValidateRateLimit()).To(MatchError(
+				"route guid route-guid-0 has rate limit response code 499, which is not a known 4xx or 5xx status code"))
+		})
+
+		It("rejects response codes that aren't errors", func() {
+			route.Spec.RateLimit.ResponseCode = intPtr(200)
+
+			Expect(route.ValidateRateLimit()).NotTo(Succeed())
+		})
+
+		It("rejects a burst of 0", func() {
+			route.Spec.RateLimit.Burst = intPtr(0)
+
+			Expect(route.ValidateRateLimit()).To(MatchError(
+				"route guid route-guid-0 has rate limit of 10 requests per second with a burst of 0, both must be at least 1"))
+		})
+
+		It("rejects a header for rate limits that aren't per client", func() {
+			perClient := false
+			route.Spec.RateLimit.PerClient = &perClient
+			route.Spec.RateLimit.Header = "X-Api-Key"
+
+			Expect(route.ValidateRateLimit()).To(MatchError(
+				"route guid route-guid-0 has rate limit header X-Api-Key, which only tells clients apart for per client rate limits"))
+		})
+
+		It("rejects invalid header names", func() {
+			route.Spec.RateLimit.Header = "X Api Key"
+
+			Expect(route.ValidateRateLimit()).To(MatchError(
+				`route guid route-guid-0 has rate limit header "X Api Key", which is not a valid header name`))
+		})
+
+		It("accepts routes without a rate limit", func() {
+			route.Spec.RateLimit = nil
+
+			Expect(route.ValidateRateLimit()).To(Succeed())
+		})
+	})
+})
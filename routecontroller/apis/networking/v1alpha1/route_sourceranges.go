@@ -0,0 +1,47 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+)
+
+// RestrictsSourceRanges is true for external routes with allowed or denied
+// source ranges. Internal routes don't pass through the gateway.
+func (r Route) RestrictsSourceRanges() bool {
+	return !r.Spec.Domain.Internal && (len(r.Spec.AllowedSourceRanges) > 0 || len(r.Spec.DeniedSourceRanges) > 0)
+}
+
+// ValidateSourceRanges returns an error when an allowed or denied source range
+// of the Route is neither a CIDR nor an IP
+func (r Route) ValidateSourceRanges() error {
+	for _, field := range []struct {
+		name   string
+		ranges []string
+	}{
+		{"allowed", r.Spec.AllowedSourceRanges},
+		{"denied", r.Spec.DeniedSourceRanges},
+	} {
+		for _, sourceRange := range field.ranges {
+			if _, _, err := net.ParseCIDR(sourceRange); err == nil || net.ParseIP(sourceRange) != nil {
+				continue
+			}
+			return fmt.Errorf("route guid %s has %s source range %q, which is neither a CIDR nor an IP", r.ObjectMeta.Name, field.name, sourceRange)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package v1alpha1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Route source ranges", func() {
+	var route v1alpha1.Route
+
+	BeforeEach(func() {
+		route = v1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "route-guid-0"},
+			Spec: v1alpha1.RouteSpec{
+				Host:                "test0",
+				Domain:              v1alpha1.RouteDomain{Name: "domain0.example.com"},
+				AllowedSourceRanges: []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"},
+				DeniedSourceRanges:  []string{"10.1.0.0/16"},
+			},
+		}
+	})
+
+	Describe("RestrictsSourceRanges", func() {
+		It("is true for external routes with source ranges", func() {
+			Expect(route.RestrictsSourceRanges()).To(BeTrue())
+		})
+
+		It("is true for routes with denied source ranges only", func() {
+			route.Spec.AllowedSourceRanges = nil
+
+			Expect(route.RestrictsSourceRanges()).To(BeTrue())
+		})
+
+		It("is false for routes without source ranges", func() {
+			route.Spec.AllowedSourceRanges = nil
+			route.Spec.DeniedSourceRanges = nil
+
+			Expect(route.RestrictsSourceRanges()).To(BeFalse())
+		})
+
+		It("is false for internal routes", func() {
+			route.Spec.Domain.Internal = true
+
+			Expect(route.RestrictsSourceRanges()).To(BeFalse())
+		})
+	})
+
+	Describe("ValidateSourceRanges", func() {
+		It("accepts CIDRs and IPs", func() {
+			Expect(route.ValidateSourceRanges()).To(Succeed())
+		})
+
+		It("rejects allowed source ranges that are neither", func() {
+			route.Spec.AllowedSourceRanges = []string{"10.0.0.0/33"}
+
+			Expect(route.ValidateSourceRanges()).To(MatchError(
+				`route guid route-guid-0 has allowed source range "10.0.0.0/33", which is neither a CIDR nor an IP`))
+		})
+
+		It("rejects denied source ranges that are neither", func() {
+			route.Spec.DeniedSourceRanges = []string{"example.com"}
+
+			Expect(route.ValidateSourceRanges()).To(MatchError(
+				`route guid route-guid-0 has denied source range "example.com", which is neither a CIDR nor an IP`))
+		})
+	})
+})
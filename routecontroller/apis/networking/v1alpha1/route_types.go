@@ -16,8 +16,6 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"fmt"
-
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -34,6 +32,21 @@ type RouteSpec struct {
 	Url          string             `json:"url"`
 	Domain       RouteDomain        `json:"domain"`
 	Destinations []RouteDestination `json:"destinations"`
+	Cors         *RouteCorsPolicy   `json:"cors,omitempty"`
+	// ActiveDestinationSet limits traffic to the destinations with a matching
+	// set, so blue/green deploys can switch every destination at once
+	ActiveDestinationSet string          `json:"activeDestinationSet,omitempty"`
+	RateLimit            *RouteRateLimit `json:"rateLimit,omitempty"`
+	// AllowedSourceRanges limits the clients the ingress gateway passes the
+	// Route's requests on from to these CIDRs or IPs, DeniedSourceRanges
+	// rejects the requests of clients in them. The gateway sees the address
+	// of the connection, so its load balancer must keep the client's address,
+	// e.g. with the Local external traffic policy. Otherwise allowed ranges
+	// deny every client and denied ranges none, which the
+	// SourceAddressNotPreserved condition reports. Internal routes are not
+	// restricted.
+	AllowedSourceRanges []string `json:"allowedSourceRanges,omitempty"`
+	DeniedSourceRanges  []string `json:"deniedSourceRanges,omitempty"`
 }
 
 type RouteDomain struct {
@@ -47,6 +60,11 @@ type RouteDestination struct {
 	Port     *int                `json:"port"`
 	App      DestinationApp      `json:"app"`
 	Selector DestinationSelector `json:"selector"`
+	// Set groups destinations for blue/green deploys, e.g. "blue" or "green"
+	Set string `json:"set,omitempty"`
+	// Namespace the destination's app runs in, defaults to the Route's namespace.
+	// Other namespaces must allow it with a RouteReferenceGrant.
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type DestinationApp struct {
@@ -62,18 +80,132 @@ type AppProcess struct {
 	Type string `json:"type"`
 }
 
+// RouteCorsPolicy describes the Cross-Origin Resource Sharing policy for a Route.
+// Origins are either "*", an exact origin such as "https://app.example.com",
+// or an origin with a wildcard subdomain such as "https://*.example.com".
+type RouteCorsPolicy struct {
+	AllowOrigins     []string `json:"allowOrigins,omitempty"`
+	AllowMethods     []string `json:"allowMethods,omitempty"`
+	AllowHeaders     []string `json:"allowHeaders,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	AllowCredentials *bool    `json:"allowCredentials,omitempty"`
+	// MaxAge is the number of seconds the results of a preflight request can be cached
+	MaxAge *int `json:"maxAge,omitempty"`
+}
+
+// RouteRateLimit limits the requests the ingress gateway passes on to a
+// Route. Each gateway replica counts requests on its own, so with N replicas a
+// client gets up to N times the limit. Internal routes don't pass through the
+// gateway and are not limited.
+type RouteRateLimit struct {
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerSecond int `json:"requestsPerSecond"`
+	// Burst is the number of requests allowed at once, defaults to RequestsPerSecond
+	// +kubebuilder:validation:Minimum=1
+	Burst *int `json:"burst,omitempty"`
+	// ResponseCode is returned for requests over the limit, defaults to 429
+	// +kubebuilder:validation:Minimum=400
+	// +kubebuilder:validation:Maximum=599
+	ResponseCode *int `json:"responseCode,omitempty"`
+	// PerClient gives each client of the Route its own token bucket, defaults
+	// to true. Without it every client shares one bucket, so one client can
+	// use up the limit of the others.
+	PerClient *bool `json:"perClient,omitempty"`
+	// Header is the request header telling clients apart, such as an API key,
+	// instead of their address. Requests without it share one bucket.
+	Header string `json:"header,omitempty"`
+}
+
 // RouteStatus defines the observed state of Route
 type RouteStatus struct {
-	Conditions []Condition `json:"conditions"`
+	Conditions   []Condition              `json:"conditions"`
+	Destinations []RouteDestinationStatus `json:"destinations,omitempty"`
+	// The destination set that is receiving traffic and the one that received
+	// it before the last switch, which is what a rollback switches back to
+	ActiveDestinationSet         string `json:"activeDestinationSet,omitempty"`
+	PreviousActiveDestinationSet string `json:"previousActiveDestinationSet,omitempty"`
+	// RateLimit is the limit the gateway enforces, with its defaults applied
+	RateLimit *RouteRateLimitStatus `json:"rateLimit,omitempty"`
 }
 
+// RouteRateLimitStatus is a RouteRateLimit with its defaults applied
+type RouteRateLimitStatus struct {
+	RequestsPerSecond int `json:"requestsPerSecond"`
+	Burst             int `json:"burst"`
+	ResponseCode      int `json:"responseCode"`
+	// Scope is what the requests counted by a token bucket have in common,
+	// ClientPerGatewayReplica or RoutePerGatewayReplica
+	Scope  string `json:"scope"`
+	Header string `json:"header,omitempty"`
+}
+
+// RouteDestinationStatus is the share of the route's traffic a destination
+// receives, after its relative weight has been normalized to a percentage
+type RouteDestinationStatus struct {
+	Guid   string `json:"guid"`
+	Weight int    `json:"weight"`
+}
+
+// ConditionConflicted is true when the Route's FQDN is owned by Routes in another namespace
+const ConditionConflicted = "Conflicted"
+
+// ConditionReferenceNotGranted is true when some of the Route's destinations are in
+// namespaces that have not granted the Route's namespace access to them
+const ConditionReferenceNotGranted = "ReferenceNotGranted"
+
+// ConditionServiceFieldManagerConflict is true when fields of a Service generated
+// for the Route are managed by another field manager, so they could not be applied
+const ConditionServiceFieldManagerConflict = "ServiceFieldManagerConflict"
+
+// ConditionVirtualServiceFieldManagerConflict is true when fields of the VirtualService,
+// or the ServiceEntry of internal routes or EnvoyFilter and AuthorizationPolicy of rate
+// limited and source restricted routes, for the Route's FQDN are managed by another
+// field manager, so they could not be applied
+const ConditionVirtualServiceFieldManagerConflict = "VirtualServiceFieldManagerConflict"
+
+// ConditionURLMismatch is true when the Route's url does not match its canonical
+// url, derived from its host, domain and path. The canonical url is used instead.
+const ConditionURLMismatch = "URLMismatch"
+
+// ConditionInvalidFQDN is true when the Route's host and domain do not make a
+// valid hostname. The Route is left out of the resources of its FQDN, the
+// other Routes of the FQDN still get theirs.
+const ConditionInvalidFQDN = "InvalidFQDN"
+
+// ConditionInvalidPolicy is true when the Route's cors policy, rate limit or
+// source ranges are invalid. The Route is left out of the resources of its
+// FQDN, the other Routes of the FQDN still get theirs.
+const ConditionInvalidPolicy = "InvalidPolicy"
+
+// ConditionInactiveDestinationSet is true when none of the Route's granted
+// destinations are in its active destination set. The Route is left out of the
+// resources of its FQDN and gets no traffic.
+const ConditionInactiveDestinationSet = "InactiveDestinationSet"
+
+// ConditionInvalidWeights is true when the weights of the Route's active
+// destinations are set on some but not all of them, negative or add up to 0.
+// The Route is left out of the resources of its FQDN and gets no traffic.
+const ConditionInvalidWeights = "InvalidWeights"
+
+// ConditionSourceAddressNotPreserved is true when the Route restricts its
+// source ranges but a Service exposing the ingress gateway doesn't keep the
+// client's address, so the ranges match the addresses of nodes instead
+const ConditionSourceAddressNotPreserved = "SourceAddressNotPreserved"
+
+// ConditionDuplicateWildcard is true when another wildcard route of the Route's
+// domain is older, only one wildcard route per domain gets traffic
+const ConditionDuplicateWildcard = "DuplicateWildcard"
+
 type Condition struct {
 	Type   string `json:"type"`
 	Status bool   `json:"status"`
+	// Message explains why the condition is true
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // Route is the Schema for the routes API
 // +kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
@@ -99,9 +231,11 @@ func init() {
 	SchemeBuilder.Register(&Route{}, &RouteList{})
 }
 
-func (r Route) FQDN() string {
-	if r.Spec.Host == "" {
-		return r.Spec.Domain.Name
-	}
-	return fmt.Sprintf("%s.%s", r.Spec.Host, r.Spec.Domain.Name)
+// WildcardHost is the host of routes that catch every host of their domain
+// without a more specific route
+const WildcardHost = "*"
+
+// IsWildcard is true for routes whose FQDN is "*." followed by their domain
+func (r Route) IsWildcard() bool {
+	return r.Spec.Host == WildcardHost
 }
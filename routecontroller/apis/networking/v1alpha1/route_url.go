@@ -0,0 +1,110 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CanonicalURL is the url of the Route derived from its host, domain and
+// path, rather than the url set by the client. It is the canonical FQDN
+// followed by the path normalized by NormalizePath.
+func (r Route) CanonicalURL() string {
+	return r.FQDN() + r.CanonicalPath()
+}
+
+// CanonicalPath is the Route's path normalized by NormalizePath
+func (r Route) CanonicalPath() string {
+	return NormalizePath(r.Spec.Path)
+}
+
+// HasCanonicalURL is true when the url set by the client is the canonical
+// url, once both are normalized
+func (r Route) HasCanonicalURL() bool {
+	return normalizeURL(r.Spec.Url) == r.CanonicalURL()
+}
+
+// NormalizePath makes paths that match the same requests equal. It adds a
+// leading slash, removes trailing ones, decodes percent-encoded unreserved
+// characters, upper cases the remaining percent-encodings and encodes
+// characters that are not allowed in a path. The root path is empty.
+func NormalizePath(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	var normalized strings.Builder
+	if !strings.HasPrefix(path, "/") {
+		normalized.WriteByte('/')
+	}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '%' && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]) {
+			decoded := unhex(path[i+1])<<4 | unhex(path[i+2])
+			if isUnreserved(decoded) {
+				normalized.WriteByte(decoded)
+			} else {
+				normalized.WriteString(strings.ToUpper(path[i : i+3]))
+			}
+			i += 2
+			continue
+		}
+		if isUnreserved(c) || isSubDelim(c) || c == ':' || c == '@' || c == '/' {
+			normalized.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&normalized, "%%%02X", c)
+	}
+
+	return strings.TrimRight(normalized.String(), "/")
+}
+
+// normalizeURL normalizes a url of the form host/path the same way the
+// canonical url is
+func normalizeURL(url string) string {
+	host, path := url, ""
+	if i := strings.Index(url, "/"); i >= 0 {
+		host, path = url[:i], url[i:]
+	}
+	return CanonicalFQDN("", host) + NormalizePath(path)
+}
+
+// RFC 3986 section 2.3
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// RFC 3986 section 2.2
+func isSubDelim(c byte) bool {
+	return strings.IndexByte("!$&'()*+,;=", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,102 @@
+package v1alpha1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+)
+
+var _ = Describe("Route urls", func() {
+	var route v1alpha1.Route
+
+	BeforeEach(func() {
+		route = v1alpha1.Route{
+			Spec: v1alpha1.RouteSpec{
+				Host:   "test0",
+				Path:   "/path0",
+				Url:    "test0.domain0.example.com/path0",
+				Domain: v1alpha1.RouteDomain{Name: "domain0.example.com"},
+			},
+		}
+	})
+
+	Describe("CanonicalURL", func() {
+		It("is the FQDN followed by the path", func() {
+			Expect(route.CanonicalURL()).To(Equal("test0.domain0.example.com/path0"))
+		})
+
+		It("lower cases the host and normalizes the path", func() {
+			route.Spec.Host = "Test0"
+			route.Spec.Domain.Name = "Domain0.Example.com"
+			route.Spec.Path = "/path0/"
+
+			Expect(route.CanonicalURL()).To(Equal("test0.domain0.example.com/path0"))
+		})
+
+		It("is the domain for routes without a host", func() {
+			route.Spec.Host = ""
+			route.Spec.Path = ""
+
+			Expect(route.CanonicalURL()).To(Equal("domain0.example.com"))
+		})
+
+		It("ignores the url set by the client", func() {
+			route.Spec.Url = "stale.domain0.example.com/path1"
+
+			Expect(route.CanonicalURL()).To(Equal("test0.domain0.example.com/path0"))
+		})
+	})
+
+	Describe("HasCanonicalURL", func() {
+		It("is true when the url matches the canonical url", func() {
+			Expect(route.HasCanonicalURL()).To(BeTrue())
+		})
+
+		It("is true when the url only differs in its normalization", func() {
+			route.Spec.Url = "TEST0.domain0.example.com/path%30/"
+
+			Expect(route.HasCanonicalURL()).To(BeTrue())
+		})
+
+		It("is false when the url has another host or path", func() {
+			route.Spec.Url = "stale.domain0.example.com/path0"
+			Expect(route.HasCanonicalURL()).To(BeFalse())
+
+			route.Spec.Url = "test0.domain0.example.com/path1"
+			Expect(route.HasCanonicalURL()).To(BeFalse())
+		})
+
+		It("is false when the url is missing the path", func() {
+			route.Spec.Url = "test0.domain0.example.com"
+
+			Expect(route.HasCanonicalURL()).To(BeFalse())
+		})
+	})
+
+	Describe("NormalizePath", func() {
+		It("leaves normalized paths alone", func() {
+			Expect(v1alpha1.NormalizePath("/path0/deeper")).To(Equal("/path0/deeper"))
+		})
+
+		It("makes the root path empty", func() {
+			Expect(v1alpha1.NormalizePath("/")).To(Equal(""))
+		})
+
+		It("removes trailing slashes and adds a leading one", func() {
+			Expect(v1alpha1.NormalizePath("/path0//")).To(Equal("/path0"))
+			Expect(v1alpha1.NormalizePath("path0")).To(Equal("/path0"))
+		})
+
+		It("decodes unreserved characters and upper cases other percent-encodings", func() {
+			Expect(v1alpha1.NormalizePath("/%70ath%2D0%7E")).To(Equal("/path-0~"))
+			Expect(v1alpha1.NormalizePath("/path%2f0%c3%a9")).To(Equal("/path%2F0%C3%A9"))
+		})
+
+		It("encodes characters that are not allowed in a path", func() {
+			Expect(v1alpha1.NormalizePath("/path 0/ü")).To(Equal("/path%200/%C3%BC"))
+			Expect(v1alpha1.NormalizePath("/100%")).To(Equal("/100%25"))
+			Expect(v1alpha1.NormalizePath("/a:b@c;d=e")).To(Equal("/a:b@c;d=e"))
+		})
+	})
+})
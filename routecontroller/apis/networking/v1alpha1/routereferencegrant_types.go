@@ -0,0 +1,54 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RouteReferenceGrantSpec defines the desired state of RouteReferenceGrant
+type RouteReferenceGrantSpec struct {
+	// Namespaces whose Routes may have destinations in the grant's namespace
+	From []RouteReferenceGrantFrom `json:"from"`
+}
+
+type RouteReferenceGrantFrom struct {
+	Namespace string `json:"namespace"`
+}
+
+// +kubebuilder:object:root=true
+
+// RouteReferenceGrant allows Routes in other namespaces to send traffic to
+// apps in its namespace, e.g. for routes shared between spaces
+type RouteReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RouteReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RouteReferenceGrantList contains a list of RouteReferenceGrant
+type RouteReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RouteReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RouteReferenceGrant{}, &RouteReferenceGrantList{})
+}
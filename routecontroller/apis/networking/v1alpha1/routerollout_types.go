@@ -0,0 +1,99 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	RolloutPhaseProgressing = "Progressing"
+	RolloutPhasePaused      = "Paused"
+	RolloutPhaseCompleted   = "Completed"
+	RolloutPhaseRolledBack  = "RolledBack"
+	RolloutPhaseFailed      = "Failed"
+)
+
+const (
+	AnalysisFailurePolicyPause    = "Pause"
+	AnalysisFailurePolicyRollback = "Rollback"
+)
+
+// RouteRolloutSpec defines the desired state of RouteRollout
+type RouteRolloutSpec struct {
+	// Name of the Route, in the same namespace, whose destination weights are shifted
+	RouteName string `json:"routeName"`
+	// Guid of the destination traffic is shifted away from
+	StableDestination string `json:"stableDestination"`
+	// Guid of the destination traffic is shifted to
+	CanaryDestination string `json:"canaryDestination"`
+	// Percentage of traffic the canary destination receives at each step, e.g. [10, 25, 50, 100]
+	Steps []int `json:"steps"`
+	// How long each step lasts before moving on to the next one
+	StepInterval metav1.Duration `json:"stepInterval"`
+	// Paused holds the rollout at its current step. A failed analysis with the
+	// Pause failure policy sets it, the rollout resumes once it is unset.
+	Paused   bool             `json:"paused,omitempty"`
+	Analysis *RolloutAnalysis `json:"analysis,omitempty"`
+}
+
+// RolloutAnalysis is an HTTP endpoint that is called before moving on to the
+// next step. Any response other than a 2xx fails the analysis.
+type RolloutAnalysis struct {
+	URL string `json:"url"`
+	// Either "Pause" or "Rollback", defaults to "Rollback"
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+}
+
+// RouteRolloutStatus defines the observed state of RouteRollout
+type RouteRolloutStatus struct {
+	Phase string `json:"phase,omitempty"`
+	// Number of steps that have been applied to the Route
+	CurrentStep int `json:"currentStep"`
+	// Percentage of traffic the canary destination currently receives
+	CanaryWeight int          `json:"canaryWeight"`
+	LastStepTime *metav1.Time `json:"lastStepTime,omitempty"`
+	Message      string       `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// RouteRollout is the Schema for the routerollouts API
+// +kubebuilder:printcolumn:name="Route",type=string,JSONPath=`.spec.routeName`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Canary Weight",type=integer,JSONPath=`.status.canaryWeight`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type RouteRollout struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RouteRolloutSpec   `json:"spec,omitempty"`
+	Status RouteRolloutStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RouteRolloutList contains a list of RouteRollout
+type RouteRolloutList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RouteRollout `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RouteRollout{}, &RouteRolloutList{})
+}
@@ -0,0 +1,180 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// URLAnnotation keeps the v1alpha1 url of a Route when it differs from its
+// canonical url, so converting back restores it
+const URLAnnotation = "networking.cloudfoundry.org/v1alpha1-url"
+
+// ConvertTo converts this Route to the v1alpha1 hub version
+func (src *Route) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha1.Route)
+	if !ok {
+		return fmt.Errorf("cannot convert a Route to %T", dstRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec.Host = src.Spec.Host
+	dst.Spec.Path = src.Spec.Path
+	dst.Spec.Domain = v1alpha1.RouteDomain(src.Spec.Domain)
+	dst.Spec.Url = dst.CanonicalURL()
+	if url, ok := dst.ObjectMeta.Annotations[URLAnnotation]; ok {
+		dst.Spec.Url = url
+		delete(dst.ObjectMeta.Annotations, URLAnnotation)
+		if len(dst.ObjectMeta.Annotations) == 0 {
+			dst.ObjectMeta.Annotations = nil
+		}
+	}
+	dst.Spec.ActiveDestinationSet = src.Spec.ActiveDestinationSet
+	if src.Spec.Cors != nil {
+		dst.Spec.Cors = (*v1alpha1.RouteCorsPolicy)(src.Spec.Cors.DeepCopy())
+	}
+	if src.Spec.RateLimit != nil {
+		dst.Spec.RateLimit = (*v1alpha1.RouteRateLimit)(src.Spec.RateLimit.DeepCopy())
+	}
+	spec := src.Spec.DeepCopy()
+	dst.Spec.AllowedSourceRanges = spec.AllowedSourceRanges
+	dst.Spec.DeniedSourceRanges = spec.DeniedSourceRanges
+
+	if src.Spec.Destinations != nil {
+		dst.Spec.Destinations = []v1alpha1.RouteDestination{}
+		for _, destination := range src.Spec.Destinations {
+			destination := destination.DeepCopy()
+			converted := v1alpha1.RouteDestination{
+				Guid:      destination.Guid,
+				Weight:    destination.Weight,
+				App:       v1alpha1.DestinationApp{Guid: destination.App.Guid, Process: v1alpha1.AppProcess(destination.App.Process)},
+				Selector:  v1alpha1.DestinationSelector(destination.Selector),
+				Set:       destination.Set,
+				Namespace: destination.Namespace,
+			}
+			// v1alpha1 has no port as a nil port rather than a zero one
+			if destination.Port != 0 {
+				port := destination.Port
+				converted.Port = &port
+			}
+			dst.Spec.Destinations = append(dst.Spec.Destinations, converted)
+		}
+	}
+
+	if src.Status.Conditions != nil {
+		dst.Status.Conditions = []v1alpha1.Condition{}
+		for _, condition := range src.Status.Conditions {
+			dst.Status.Conditions = append(dst.Status.Conditions, v1alpha1.Condition{
+				Type:    condition.Type,
+				Status:  condition.Status == ConditionTrue,
+				Message: condition.Message,
+			})
+		}
+	}
+	if src.Status.Destinations != nil {
+		dst.Status.Destinations = []v1alpha1.RouteDestinationStatus{}
+		for _, destination := range src.Status.Destinations {
+			dst.Status.Destinations = append(dst.Status.Destinations, v1alpha1.RouteDestinationStatus(destination))
+		}
+	}
+	dst.Status.ActiveDestinationSet = src.Status.ActiveDestinationSet
+	dst.Status.PreviousActiveDestinationSet = src.Status.PreviousActiveDestinationSet
+	if src.Status.RateLimit != nil {
+		dst.Status.RateLimit = (*v1alpha1.RouteRateLimitStatus)(src.Status.RateLimit.DeepCopy())
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the v1alpha1 hub version to this Route
+func (dst *Route) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha1.Route)
+	if !ok {
+		return fmt.Errorf("cannot convert %T to a Route", srcRaw)
+	}
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	if src.Spec.Url != src.CanonicalURL() {
+		if dst.ObjectMeta.Annotations == nil {
+			dst.ObjectMeta.Annotations = map[string]string{}
+		}
+		dst.ObjectMeta.Annotations[URLAnnotation] = src.Spec.Url
+	}
+
+	dst.Spec.Host = src.Spec.Host
+	dst.Spec.Path = src.Spec.Path
+	dst.Spec.Domain = RouteDomain(src.Spec.Domain)
+	dst.Spec.ActiveDestinationSet = src.Spec.ActiveDestinationSet
+	if src.Spec.Cors != nil {
+		dst.Spec.Cors = (*RouteCorsPolicy)(src.Spec.Cors.DeepCopy())
+	}
+	if src.Spec.RateLimit != nil {
+		dst.Spec.RateLimit = (*RouteRateLimit)(src.Spec.RateLimit.DeepCopy())
+	}
+	spec := src.Spec.DeepCopy()
+	dst.Spec.AllowedSourceRanges = spec.AllowedSourceRanges
+	dst.Spec.DeniedSourceRanges = spec.DeniedSourceRanges
+
+	if src.Spec.Destinations != nil {
+		dst.Spec.Destinations = []RouteDestination{}
+		for _, destination := range src.Spec.Destinations {
+			destination := destination.DeepCopy()
+			converted := RouteDestination{
+				Guid:      destination.Guid,
+				Weight:    destination.Weight,
+				App:       DestinationApp{Guid: destination.App.Guid, Process: AppProcess(destination.App.Process)},
+				Selector:  DestinationSelector(destination.Selector),
+				Set:       destination.Set,
+				Namespace: destination.Namespace,
+			}
+			if destination.Port != nil {
+				converted.Port = *destination.Port
+			}
+			dst.Spec.Destinations = append(dst.Spec.Destinations, converted)
+		}
+	}
+
+	if src.Status.Conditions != nil {
+		dst.Status.Conditions = []Condition{}
+		for _, condition := range src.Status.Conditions {
+			status := ConditionFalse
+			if condition.Status {
+				status = ConditionTrue
+			}
+			dst.Status.Conditions = append(dst.Status.Conditions, Condition{
+				Type:    condition.Type,
+				Status:  status,
+				Message: condition.Message,
+			})
+		}
+	}
+	if src.Status.Destinations != nil {
+		dst.Status.Destinations = []RouteDestinationStatus{}
+		for _, destination := range src.Status.Destinations {
+			dst.Status.Destinations = append(dst.Status.Destinations, RouteDestinationStatus(destination))
+		}
+	}
+	dst.Status.ActiveDestinationSet = src.Status.ActiveDestinationSet
+	dst.Status.PreviousActiveDestinationSet = src.Status.PreviousActiveDestinationSet
+	if src.Status.RateLimit != nil {
+		dst.Status.RateLimit = (*RouteRateLimitStatus)(src.Status.RateLimit.DeepCopy())
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package v1beta1_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1beta1"
+	fuzz "github.com/google/gofuzz"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const fuzzIterations = 1000
+
+var _ = Describe("Route conversion", func() {
+	var fuzzer *fuzz.Fuzzer
+
+	BeforeEach(func() {
+		fuzzer = fuzz.New().NilChance(0.2).Funcs(
+			// the webhook sets the kind and version of converted objects
+			func(typeMeta *metav1.TypeMeta, c fuzz.Continue) {},
+			// the fuzzer's timestamps do not survive a round trip through JSON
+			// either, and metadata is copied as is
+			func(meta *metav1.ObjectMeta, c fuzz.Continue) {
+				c.Fuzz(&meta.Name)
+				c.Fuzz(&meta.Namespace)
+				c.Fuzz(&meta.Labels)
+				c.Fuzz(&meta.Annotations)
+			},
+			// a port of 0 is invalid in both versions and has no v1alpha1 equivalent
+			func(destination *v1alpha1.RouteDestination, c fuzz.Continue) {
+				c.FuzzNoCustom(destination)
+				if destination.Port != nil {
+					*destination.Port = 1 + c.Intn(65535)
+				}
+			},
+			func(destination *v1beta1.RouteDestination, c fuzz.Continue) {
+				c.FuzzNoCustom(destination)
+				destination.Port = 1 + c.Intn(65535)
+			},
+			func(status *v1beta1.ConditionStatus, c fuzz.Continue) {
+				*status = v1beta1.ConditionFalse
+				if c.RandBool() {
+					*status = v1beta1.ConditionTrue
+				}
+			},
+		)
+	})
+
+	It("round trips v1alpha1 Routes through v1beta1", func() {
+		for i := 0; i < fuzzIterations; i++ {
+			original := &v1alpha1.Route{}
+			fuzzer.Fuzz(original)
+
+			converted := &v1beta1.Route{}
+			Expect(converted.ConvertFrom(original.DeepCopy())).To(Succeed())
+			roundTripped := &v1alpha1.Route{}
+			Expect(converted.ConvertTo(roundTripped)).To(Succeed())
+
+			Expect(apiequality.Semantic.DeepEqual(original, roundTripped)).To(BeTrue(), "%#v\n%#v", original, roundTripped)
+		}
+	})
+
+	It("round trips v1beta1 Routes through v1alpha1", func() {
+		for i := 0; i < fuzzIterations; i++ {
+			original := &v1beta1.Route{}
+			fuzzer.Fuzz(original)
+			delete(original.ObjectMeta.Annotations, v1beta1.URLAnnotation)
+
+			converted := &v1alpha1.Route{}
+			Expect(original.DeepCopy().ConvertTo(converted)).To(Succeed())
+			roundTripped := &v1beta1.Route{}
+			Expect(roundTripped.ConvertFrom(converted)).To(Succeed())
+
+			Expect(apiequality.Semantic.DeepEqual(original, roundTripped)).To(BeTrue(), "%#v\n%#v", original, roundTripped)
+		}
+	})
+
+	Describe("the v1alpha1 url", func() {
+		var route *v1alpha1.Route
+
+		BeforeEach(func() {
+			route = &v1alpha1.Route{
+				Spec: v1alpha1.RouteSpec{
+					Host:   "test0",
+					Path:   "/path0",
+					Url:    "test0.domain0.example.com/path0",
+					Domain: v1alpha1.RouteDomain{Name: "domain0.example.com"},
+				},
+			}
+		})
+
+		It("is dropped when it matches the host, domain and path", func() {
+			converted := &v1beta1.Route{}
+			Expect(converted.ConvertFrom(route)).To(Succeed())
+			Expect(converted.ObjectMeta.Annotations).NotTo(HaveKey(v1beta1.URLAnnotation))
+
+			roundTripped := &v1alpha1.Route{}
+			Expect(converted.ConvertTo(roundTripped)).To(Succeed())
+			Expect(roundTripped.Spec.Url).To(Equal("test0.domain0.example.com/path0"))
+		})
+
+		It("is kept in an annotation when it does not", func() {
+			route.Spec.Url = "legacy.domain0.example.com/path0"
+
+			converted := &v1beta1.Route{}
+			Expect(converted.ConvertFrom(route)).To(Succeed())
+			Expect(converted.ObjectMeta.Annotations).To(HaveKeyWithValue(v1beta1.URLAnnotation, "legacy.domain0.example.com/path0"))
+
+			roundTripped := &v1alpha1.Route{}
+			Expect(converted.ConvertTo(roundTripped)).To(Succeed())
+			Expect(roundTripped.Spec.Url).To(Equal("legacy.domain0.example.com/path0"))
+			Expect(roundTripped.ObjectMeta.Annotations).To(BeNil())
+		})
+	})
+
+	It("converts condition statuses", func() {
+		route := &v1alpha1.Route{Status: v1alpha1.RouteStatus{Conditions: []v1alpha1.Condition{
+			{Type: v1alpha1.ConditionConflicted, Status: true, Message: "claimed elsewhere"},
+			{Type: v1alpha1.ConditionReferenceNotGranted, Status: false},
+		}}}
+
+		converted := &v1beta1.Route{}
+		Expect(converted.ConvertFrom(route)).To(Succeed())
+		Expect(converted.Status.Conditions).To(Equal([]v1beta1.Condition{
+			{Type: v1alpha1.ConditionConflicted, Status: v1beta1.ConditionTrue, Message: "claimed elsewhere"},
+			{Type: v1alpha1.ConditionReferenceNotGranted, Status: v1beta1.ConditionFalse},
+		}))
+	})
+})
@@ -0,0 +1,195 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RouteSpec defines the desired state of Route. Unlike v1alpha1 it has no
+// url, which is always the route's FQDN followed by its path.
+type RouteSpec struct {
+	Host         string             `json:"host"`
+	Path         string             `json:"path,omitempty"`
+	Domain       RouteDomain        `json:"domain"`
+	Destinations []RouteDestination `json:"destinations"`
+	Cors         *RouteCorsPolicy   `json:"cors,omitempty"`
+	// ActiveDestinationSet limits traffic to the destinations with a matching
+	// set, so blue/green deploys can switch every destination at once
+	ActiveDestinationSet string          `json:"activeDestinationSet,omitempty"`
+	RateLimit            *RouteRateLimit `json:"rateLimit,omitempty"`
+	// AllowedSourceRanges limits the clients the ingress gateway passes the
+	// Route's requests on from to these CIDRs or IPs, DeniedSourceRanges
+	// rejects the requests of clients in them. The gateway sees the address
+	// of the connection, so its load balancer must keep the client's address,
+	// e.g. with the Local external traffic policy. Otherwise allowed ranges
+	// deny every client and denied ranges none, which the
+	// SourceAddressNotPreserved condition reports. Internal routes are not
+	// restricted.
+	AllowedSourceRanges []string `json:"allowedSourceRanges,omitempty"`
+	DeniedSourceRanges  []string `json:"deniedSourceRanges,omitempty"`
+}
+
+type RouteDomain struct {
+	Name     string `json:"name"`
+	Internal bool   `json:"internal"`
+}
+
+type RouteDestination struct {
+	Guid   string `json:"guid"`
+	Weight *int   `json:"weight,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port     int                 `json:"port"`
+	App      DestinationApp      `json:"app"`
+	Selector DestinationSelector `json:"selector"`
+	// Set groups destinations for blue/green deploys, e.g. "blue" or "green"
+	Set string `json:"set,omitempty"`
+	// Namespace the destination's app runs in, defaults to the Route's namespace.
+	// Other namespaces must allow it with a RouteReferenceGrant.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type DestinationApp struct {
+	Guid    string     `json:"guid"`
+	Process AppProcess `json:"process"`
+}
+
+type DestinationSelector struct {
+	MatchLabels map[string]string `json:"matchLabels"`
+}
+
+type AppProcess struct {
+	Type string `json:"type"`
+}
+
+// RouteCorsPolicy describes the Cross-Origin Resource Sharing policy for a Route.
+// Origins are either "*", an exact origin such as "https://app.example.com",
+// or an origin with a wildcard subdomain such as "https://*.example.com".
+type RouteCorsPolicy struct {
+	AllowOrigins     []string `json:"allowOrigins,omitempty"`
+	AllowMethods     []string `json:"allowMethods,omitempty"`
+	AllowHeaders     []string `json:"allowHeaders,omitempty"`
+	ExposeHeaders    []string `json:"exposeHeaders,omitempty"`
+	AllowCredentials *bool    `json:"allowCredentials,omitempty"`
+	// MaxAge is the number of seconds the results of a preflight request can be cached
+	MaxAge *int `json:"maxAge,omitempty"`
+}
+
+// RouteRateLimit limits the requests the ingress gateway passes on to a
+// Route. Each gateway replica counts requests on its own, so with N replicas a
+// client gets up to N times the limit. Internal routes don't pass through the
+// gateway and are not limited.
+type RouteRateLimit struct {
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerSecond int `json:"requestsPerSecond"`
+	// Burst is the number of requests allowed at once, defaults to RequestsPerSecond
+	// +kubebuilder:validation:Minimum=1
+	Burst *int `json:"burst,omitempty"`
+	// ResponseCode is returned for requests over the limit, defaults to 429
+	// +kubebuilder:validation:Minimum=400
+	// +kubebuilder:validation:Maximum=599
+	ResponseCode *int `json:"responseCode,omitempty"`
+	// PerClient gives each client of the Route its own token bucket, defaults
+	// to true. Without it every client shares one bucket, so one client can
+	// use up the limit of the others.
+	PerClient *bool `json:"perClient,omitempty"`
+	// Header is the request header telling clients apart, such as an API key,
+	// instead of their address. Requests without it share one bucket.
+	Header string `json:"header,omitempty"`
+}
+
+// RouteStatus defines the observed state of Route
+type RouteStatus struct {
+	Conditions   []Condition              `json:"conditions,omitempty"`
+	Destinations []RouteDestinationStatus `json:"destinations,omitempty"`
+	// The destination set that is receiving traffic and the one that received
+	// it before the last switch, which is what a rollback switches back to
+	ActiveDestinationSet         string `json:"activeDestinationSet,omitempty"`
+	PreviousActiveDestinationSet string `json:"previousActiveDestinationSet,omitempty"`
+	// RateLimit is the limit the gateway enforces, with its defaults applied
+	RateLimit *RouteRateLimitStatus `json:"rateLimit,omitempty"`
+}
+
+// RouteRateLimitStatus is a RouteRateLimit with its defaults applied
+type RouteRateLimitStatus struct {
+	RequestsPerSecond int `json:"requestsPerSecond"`
+	Burst             int `json:"burst"`
+	ResponseCode      int `json:"responseCode"`
+	// Scope is what the requests counted by a token bucket have in common,
+	// ClientPerGatewayReplica or RoutePerGatewayReplica
+	Scope  string `json:"scope"`
+	Header string `json:"header,omitempty"`
+}
+
+// RouteDestinationStatus is the share of the route's traffic a destination
+// receives, after its relative weight has been normalized to a percentage
+type RouteDestinationStatus struct {
+	Guid   string `json:"guid"`
+	Weight int    `json:"weight"`
+}
+
+// ConditionStatus is "True" or "False", like the status of Kubernetes conditions
+// +kubebuilder:validation:Enum=True;False
+type ConditionStatus string
+
+const (
+	ConditionTrue  ConditionStatus = "True"
+	ConditionFalse ConditionStatus = "False"
+)
+
+// Condition types are the same as in v1alpha1
+type Condition struct {
+	Type   string          `json:"type"`
+	Status ConditionStatus `json:"status"`
+	// Message explains why the condition is true
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Route is the Schema for the routes API
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="Domain",type=string,JSONPath=`.spec.domain.name`
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.path`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type Route struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RouteSpec   `json:"spec,omitempty"`
+	Status RouteStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RouteList contains a list of Route
+type RouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Route `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Route{}, &RouteList{})
+}
+
+// FQDN is canonicalized the same way as the FQDN of v1alpha1 Routes
+func (r Route) FQDN() string {
+	return v1alpha1.CanonicalFQDN(r.Spec.Host, r.Spec.Domain.Name)
+}
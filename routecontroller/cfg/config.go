@@ -3,45 +3,503 @@ package cfg
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
 )
 
+// ConfigVersion is the only version of the configuration file this release reads
+const ConfigVersion = "v1"
+
+// ProviderIstio is the only supported ingress provider
+const ProviderIstio = "istio"
+
 type Config struct {
 	ResyncInterval time.Duration
 	Istio          struct {
 		// The Istio Gateway the route controller applies to
-		Gateway string
+		Gateway         string
+		GatewayWorkload struct {
+			// Namespace of the gateway's pods, where the EnvoyFilters enforcing
+			// rate limits are created
+			Namespace string
+			// Labels selecting the gateway's pods
+			Selector map[string]string
+		}
 	}
 	LeaderElectionNamespace string
+	NoDestinations          struct {
+		// Host of a Service that serves the response for routes without destinations
+		Backend string
+		// Status code the gateway aborts routes without destinations with when
+		// there is no Backend
+		StatusCode int
+	}
+	// Provider of the ingress resources that Routes are translated into
+	Provider string
+	Headers  struct {
+		// Whether requests get the CF-App-Id, CF-App-Process-Type, CF-Space-Id
+		// and CF-Organization-Id headers
+		CFIdentity bool
+	}
+	Concurrency struct {
+		// Number of Routes reconciled in parallel
+		MaxConcurrentReconciles int
+		// Bounds of the per-Route exponential backoff after failed reconciles
+		BackoffBaseDelay time.Duration
+		BackoffMaxDelay  time.Duration
+		// Overall rate at which Routes are taken off the work queue
+		QPS   float64
+		Burst int
+	}
+	KubernetesClient struct {
+		// Rate of requests to the Kubernetes API
+		QPS   float32
+		Burst int
+	}
+	Propagation struct {
+		// Route labels and annotations with one of these key prefixes, or one
+		// of these keys, are copied to the resources generated for the Route
+		Prefixes []string
+		Keys     []string
+	}
+	RouteRollout struct {
+		// CIDRs of loopback, link-local, private and other internal addresses
+		// that analysis hooks may still be called on. Hooks are called from
+		// inside the cluster, so those addresses are refused by default.
+		AnalysisAllowedNetworks []string
+	}
+	Logging Logging
 }
 
+type Logging struct {
+	// One of "debug", "info", "warn", "error" or a positive verbosity
+	Level string
+	// Either "json" or "console"
+	Encoder string
+	// One of "info", "error" or "panic"
+	StacktraceLevel string
+}
+
+// fileConfig is the schema of the configuration file. Every setting is
+// optional and is overridden by its env variable when that is set.
+type fileConfig struct {
+	Version        string `json:"version"`
+	Provider       string `json:"provider,omitempty"`
+	ResyncInterval string `json:"resyncInterval,omitempty"`
+	Istio          struct {
+		Gateway         string `json:"gateway,omitempty"`
+		GatewayWorkload struct {
+			Namespace string            `json:"namespace,omitempty"`
+			Selector  map[string]string `json:"selector,omitempty"`
+		} `json:"gatewayWorkload,omitempty"`
+	} `json:"istio,omitempty"`
+	LeaderElection struct {
+		Namespace string `json:"namespace,omitempty"`
+	} `json:"leaderElection,omitempty"`
+	NoDestinations struct {
+		Backend    string `json:"backend,omitempty"`
+		StatusCode int    `json:"statusCode,omitempty"`
+	} `json:"noDestinations,omitempty"`
+	Headers struct {
+		CFIdentity *bool `json:"cfIdentity,omitempty"`
+	} `json:"headers,omitempty"`
+	Concurrency struct {
+		MaxConcurrentReconciles int     `json:"maxConcurrentReconciles,omitempty"`
+		BackoffBaseDelay        string  `json:"backoffBaseDelay,omitempty"`
+		BackoffMaxDelay         string  `json:"backoffMaxDelay,omitempty"`
+		QPS                     float64 `json:"qps,omitempty"`
+		Burst                   int     `json:"burst,omitempty"`
+	} `json:"concurrency,omitempty"`
+	KubernetesClient struct {
+		QPS   float32 `json:"qps,omitempty"`
+		Burst int     `json:"burst,omitempty"`
+	} `json:"kubernetesClient,omitempty"`
+	Propagation struct {
+		Prefixes []string `json:"prefixes,omitempty"`
+		Keys     []string `json:"keys,omitempty"`
+	} `json:"propagation,omitempty"`
+	RouteRollout struct {
+		AnalysisAllowedNetworks []string `json:"analysisAllowedNetworks,omitempty"`
+	} `json:"routeRollout,omitempty"`
+	Logging struct {
+		Level           string `json:"level,omitempty"`
+		Encoder         string `json:"encoder,omitempty"`
+		StacktraceLevel string `json:"stacktraceLevel,omitempty"`
+	} `json:"logging,omitempty"`
+}
+
+// Load reads the configuration from env variables only
 func Load() (*Config, error) {
+	return LoadFile("")
+}
+
+// LoadFile reads the configuration file at path, when path is set, and then
+// applies env overrides and validates the result
+func LoadFile(path string) (*Config, error) {
+	c := defaultConfig()
+
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func defaultConfig() *Config {
 	c := &Config{}
-	var exists bool
-	c.Istio.Gateway, exists = os.LookupEnv("ISTIO_GATEWAY_NAME")
+	c.ResyncInterval = 30 * time.Second
+	c.NoDestinations.StatusCode = http.StatusServiceUnavailable
+	c.Provider = ProviderIstio
+	c.Istio.GatewayWorkload.Namespace = "istio-system"
+	c.Istio.GatewayWorkload.Selector = map[string]string{"istio": "ingressgateway"}
+	c.Headers.CFIdentity = true
+	// the same as client-go's default controller rate limiter
+	c.Concurrency.MaxConcurrentReconciles = 1
+	c.Concurrency.BackoffBaseDelay = 5 * time.Millisecond
+	c.Concurrency.BackoffMaxDelay = 1000 * time.Second
+	c.Concurrency.QPS = 10
+	c.Concurrency.Burst = 100
+	// the same as controller-runtime's defaults for its rest config
+	c.KubernetesClient.QPS = 20
+	c.KubernetesClient.Burst = 30
+	c.Logging.Level = "info"
+	c.Logging.Encoder = "json"
+	c.Logging.StacktraceLevel = "error"
+	return c
+}
 
-	if !exists {
-		return nil, errors.New("ISTIO_GATEWAY_NAME not configured")
+func (c *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %s", err)
 	}
 
-	c.LeaderElectionNamespace, exists = os.LookupEnv("LEADER_ELECTION_NAMESPACE")
+	fc := fileConfig{}
+	if err := yaml.UnmarshalStrict(data, &fc); err != nil {
+		return fmt.Errorf("could not parse config file %s: %s", path, err)
+	}
 
-	if !exists {
-		return nil, errors.New("LEADER_ELECTION_NAMESPACE not configured")
+	if fc.Version != ConfigVersion {
+		return fmt.Errorf("config file %s has version %q, only %q is supported", path, fc.Version, ConfigVersion)
 	}
 
+	if fc.ResyncInterval != "" {
+		c.ResyncInterval, err = parseResyncInterval(fc.ResyncInterval)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: resyncInterval: %q is not a duration", path, fc.ResyncInterval)
+		}
+	}
+
+	setString(&c.Provider, fc.Provider)
+	setString(&c.Istio.Gateway, fc.Istio.Gateway)
+	setString(&c.Istio.GatewayWorkload.Namespace, fc.Istio.GatewayWorkload.Namespace)
+	setString(&c.LeaderElectionNamespace, fc.LeaderElection.Namespace)
+	setString(&c.NoDestinations.Backend, fc.NoDestinations.Backend)
+	setString(&c.Logging.Level, fc.Logging.Level)
+	setString(&c.Logging.Encoder, fc.Logging.Encoder)
+	setString(&c.Logging.StacktraceLevel, fc.Logging.StacktraceLevel)
+
+	if len(fc.Istio.GatewayWorkload.Selector) > 0 {
+		c.Istio.GatewayWorkload.Selector = fc.Istio.GatewayWorkload.Selector
+	}
+	if fc.NoDestinations.StatusCode != 0 {
+		c.NoDestinations.StatusCode = fc.NoDestinations.StatusCode
+	}
+	if fc.Headers.CFIdentity != nil {
+		c.Headers.CFIdentity = *fc.Headers.CFIdentity
+	}
+	if fc.Concurrency.MaxConcurrentReconciles != 0 {
+		c.Concurrency.MaxConcurrentReconciles = fc.Concurrency.MaxConcurrentReconciles
+	}
+	if fc.Concurrency.BackoffBaseDelay != "" {
+		c.Concurrency.BackoffBaseDelay, err = time.ParseDuration(fc.Concurrency.BackoffBaseDelay)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: concurrency.backoffBaseDelay: %q is not a duration", path, fc.Concurrency.BackoffBaseDelay)
+		}
+	}
+	if fc.Concurrency.BackoffMaxDelay != "" {
+		c.Concurrency.BackoffMaxDelay, err = time.ParseDuration(fc.Concurrency.BackoffMaxDelay)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: concurrency.backoffMaxDelay: %q is not a duration", path, fc.Concurrency.BackoffMaxDelay)
+		}
+	}
+	if fc.Concurrency.QPS != 0 {
+		c.Concurrency.QPS = fc.Concurrency.QPS
+	}
+	if fc.Concurrency.Burst != 0 {
+		c.Concurrency.Burst = fc.Concurrency.Burst
+	}
+	if fc.KubernetesClient.QPS != 0 {
+		c.KubernetesClient.QPS = fc.KubernetesClient.QPS
+	}
+	if fc.KubernetesClient.Burst != 0 {
+		c.KubernetesClient.Burst = fc.KubernetesClient.Burst
+	}
+	if len(fc.Propagation.Prefixes) > 0 {
+		c.Propagation.Prefixes = fc.Propagation.Prefixes
+	}
+	if len(fc.Propagation.Keys) > 0 {
+		c.Propagation.Keys = fc.Propagation.Keys
+	}
+	if len(fc.RouteRollout.AnalysisAllowedNetworks) > 0 {
+		c.RouteRollout.AnalysisAllowedNetworks = fc.RouteRollout.AnalysisAllowedNetworks
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	lookupEnv(&c.Istio.Gateway, "ISTIO_GATEWAY_NAME")
+	lookupEnv(&c.Istio.GatewayWorkload.Namespace, "ISTIO_GATEWAY_WORKLOAD_NAMESPACE")
+	lookupEnv(&c.LeaderElectionNamespace, "LEADER_ELECTION_NAMESPACE")
+	lookupEnv(&c.NoDestinations.Backend, "NO_DESTINATIONS_BACKEND")
+	lookupEnv(&c.Logging.Level, "LOG_LEVEL")
+	lookupEnv(&c.Logging.Encoder, "LOG_ENCODER")
+	lookupEnv(&c.Logging.StacktraceLevel, "LOG_STACKTRACE_LEVEL")
+	lookupEnvList(&c.Propagation.Prefixes, "PROPAGATION_PREFIXES")
+	lookupEnvList(&c.Propagation.Keys, "PROPAGATION_KEYS")
+	lookupEnvList(&c.RouteRollout.AnalysisAllowedNetworks, "ROUTE_ROLLOUT_ANALYSIS_ALLOWED_NETWORKS")
+
 	var err error
-	resync_interval, exists := os.LookupEnv("RESYNC_INTERVAL")
+	resyncInterval, exists := os.LookupEnv("RESYNC_INTERVAL")
 
 	if exists {
-		c.ResyncInterval, err = time.ParseDuration(fmt.Sprintf("%ss", resync_interval))
+		c.ResyncInterval, err = parseResyncInterval(resyncInterval)
 		if err != nil {
-			return nil, errors.New("could not parse the RESYNC_INTERVAL duration")
+			return errors.New("could not parse the RESYNC_INTERVAL duration")
 		}
-	} else {
-		c.ResyncInterval = 30 * time.Second
 	}
 
-	return c, nil
+	if selector, exists := os.LookupEnv("ISTIO_GATEWAY_WORKLOAD_SELECTOR"); exists {
+		c.Istio.GatewayWorkload.Selector, err = labels.ConvertSelectorToLabelsMap(selector)
+		if err != nil {
+			return errors.New("ISTIO_GATEWAY_WORKLOAD_SELECTOR must be comma separated key=value labels")
+		}
+	}
+
+	if err := lookupEnvInt(&c.Concurrency.MaxConcurrentReconciles, "MAX_CONCURRENT_RECONCILES"); err != nil {
+		return err
+	}
+	if err := lookupEnvDuration(&c.Concurrency.BackoffBaseDelay, "RECONCILE_BACKOFF_BASE_DELAY"); err != nil {
+		return err
+	}
+	if err := lookupEnvDuration(&c.Concurrency.BackoffMaxDelay, "RECONCILE_BACKOFF_MAX_DELAY"); err != nil {
+		return err
+	}
+	if err := lookupEnvFloat(&c.Concurrency.QPS, "RECONCILE_QPS"); err != nil {
+		return err
+	}
+	if err := lookupEnvInt(&c.Concurrency.Burst, "RECONCILE_BURST"); err != nil {
+		return err
+	}
+	var kubernetesClientQPS float64
+	if err := lookupEnvFloat(&kubernetesClientQPS, "KUBERNETES_CLIENT_QPS"); err != nil {
+		return err
+	}
+	if kubernetesClientQPS != 0 {
+		c.KubernetesClient.QPS = float32(kubernetesClientQPS)
+	}
+	if err := lookupEnvInt(&c.KubernetesClient.Burst, "KUBERNETES_CLIENT_BURST"); err != nil {
+		return err
+	}
+
+	statusCode, exists := os.LookupEnv("NO_DESTINATIONS_STATUS_CODE")
+
+	if exists {
+		c.NoDestinations.StatusCode, err = strconv.Atoi(statusCode)
+		if err != nil || !isErrorStatusCode(c.NoDestinations.StatusCode) {
+			return errors.New("NO_DESTINATIONS_STATUS_CODE must be an HTTP error status code")
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Istio.Gateway == "" {
+		return errors.New("ISTIO_GATEWAY_NAME not configured")
+	}
+
+	if c.LeaderElectionNamespace == "" {
+		return errors.New("LEADER_ELECTION_NAMESPACE not configured")
+	}
+
+	errs := field.ErrorList{}
+	if c.Provider != ProviderIstio {
+		errs = append(errs, field.NotSupported(field.NewPath("provider"), c.Provider, []string{ProviderIstio}))
+	}
+	for _, msg := range validation.IsDNS1123Label(c.Istio.GatewayWorkload.Namespace) {
+		errs = append(errs, field.Invalid(field.NewPath("istio", "gatewayWorkload", "namespace"), c.Istio.GatewayWorkload.Namespace, msg))
+	}
+	// an empty selector would select every workload
+	if len(c.Istio.GatewayWorkload.Selector) == 0 {
+		errs = append(errs, field.Required(field.NewPath("istio", "gatewayWorkload", "selector"), "must select the gateway's pods"))
+	}
+	for key, value := range c.Istio.GatewayWorkload.Selector {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(field.NewPath("istio", "gatewayWorkload", "selector").Key(key), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, field.Invalid(field.NewPath("istio", "gatewayWorkload", "selector").Key(key), value, msg))
+		}
+	}
+	if c.ResyncInterval <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("resyncInterval"), c.ResyncInterval.String(), "must be greater than 0"))
+	}
+	if !isErrorStatusCode(c.NoDestinations.StatusCode) {
+		errs = append(errs, field.Invalid(field.NewPath("noDestinations", "statusCode"), c.NoDestinations.StatusCode, "must be an HTTP error status code"))
+	}
+	if c.Concurrency.MaxConcurrentReconciles < 1 {
+		errs = append(errs, field.Invalid(field.NewPath("concurrency", "maxConcurrentReconciles"), c.Concurrency.MaxConcurrentReconciles, "must be at least 1"))
+	}
+	if c.Concurrency.BackoffBaseDelay <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("concurrency", "backoffBaseDelay"), c.Concurrency.BackoffBaseDelay.String(), "must be greater than 0"))
+	}
+	if c.Concurrency.BackoffMaxDelay < c.Concurrency.BackoffBaseDelay {
+		errs = append(errs, field.Invalid(field.NewPath("concurrency", "backoffMaxDelay"), c.Concurrency.BackoffMaxDelay.String(), "must not be less than backoffBaseDelay"))
+	}
+	if c.Concurrency.QPS <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("concurrency", "qps"), c.Concurrency.QPS, "must be greater than 0"))
+	}
+	if c.Concurrency.Burst < 1 {
+		errs = append(errs, field.Invalid(field.NewPath("concurrency", "burst"), c.Concurrency.Burst, "must be at least 1"))
+	}
+	if c.KubernetesClient.QPS <= 0 {
+		errs = append(errs, field.Invalid(field.NewPath("kubernetesClient", "qps"), c.KubernetesClient.QPS, "must be greater than 0"))
+	}
+	if c.KubernetesClient.Burst < 1 {
+		errs = append(errs, field.Invalid(field.NewPath("kubernetesClient", "burst"), c.KubernetesClient.Burst, "must be at least 1"))
+	}
+	for i, prefix := range c.Propagation.Prefixes {
+		if prefix == "" {
+			errs = append(errs, field.Invalid(field.NewPath("propagation", "prefixes").Index(i), prefix, "must not be empty"))
+		}
+	}
+	for i, key := range c.Propagation.Keys {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(field.NewPath("propagation", "keys").Index(i), key, msg))
+		}
+	}
+	for i, network := range c.RouteRollout.AnalysisAllowedNetworks {
+		if _, _, err := net.ParseCIDR(network); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("routeRollout", "analysisAllowedNetworks").Index(i), network, "must be a CIDR"))
+		}
+	}
+	if _, err := ParseLogLevel(c.Logging.Level); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("logging", "level"), c.Logging.Level, err.Error()))
+	}
+	if c.Logging.Encoder != "json" && c.Logging.Encoder != "console" {
+		errs = append(errs, field.NotSupported(field.NewPath("logging", "encoder"), c.Logging.Encoder, []string{"json", "console"}))
+	}
+	if c.Logging.StacktraceLevel != "info" && c.Logging.StacktraceLevel != "error" && c.Logging.StacktraceLevel != "panic" {
+		errs = append(errs, field.NotSupported(field.NewPath("logging", "stacktraceLevel"), c.Logging.StacktraceLevel, []string{"info", "error", "panic"}))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %s", errs.ToAggregate())
+	}
+	return nil
+}
+
+// Resync intervals used to be plain numbers of seconds, which are still
+// accepted alongside durations such as "90s" or "15m"
+func parseResyncInterval(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func isErrorStatusCode(statusCode int) bool {
+	return statusCode >= 400 && statusCode <= 599
+}
+
+func setString(target *string, value string) {
+	if value != "" {
+		*target = value
+	}
+}
+
+func lookupEnv(target *string, name string) {
+	if value, exists := os.LookupEnv(name); exists {
+		*target = value
+	}
+}
+
+// Lists are comma separated, an empty value clears the list
+func lookupEnvList(target *[]string, name string) {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return
+	}
+
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*target = list
+}
+
+func lookupEnvInt(target *int, name string) error {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer", name)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupEnvFloat(target *float64, name string) error {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number", name)
+	}
+	*target = parsed
+	return nil
+}
+
+func lookupEnvDuration(target *time.Duration, name string) error {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("could not parse the %s duration", name)
+	}
+	*target = parsed
+	return nil
 }
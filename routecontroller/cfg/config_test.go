@@ -1,7 +1,10 @@
 package cfg_test
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"time"
 
 	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
@@ -65,5 +68,411 @@ var _ = Describe("Config", func() {
 				Expect(config.ResyncInterval).To(Equal(30 * time.Second))
 			})
 		})
+
+		Context("when the RESYNC_INTERVAL env var is a duration", func() {
+			BeforeEach(func() {
+				err := os.Setenv("RESYNC_INTERVAL", "15m")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("parses the duration", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.ResyncInterval).To(Equal(15 * time.Minute))
+			})
+		})
+
+		Context("when the RESYNC_INTERVAL env var is not a duration", func() {
+			BeforeEach(func() {
+				err := os.Setenv("RESYNC_INTERVAL", "often")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, err := cfg.Load()
+				Expect(err).To(MatchError("could not parse the RESYNC_INTERVAL duration"))
+			})
+		})
+
+		Context("when the NO_DESTINATIONS env vars are set", func() {
+			BeforeEach(func() {
+				err := os.Setenv("NO_DESTINATIONS_BACKEND", "no-app-mapped.cf-system.svc.cluster.local")
+				Expect(err).NotTo(HaveOccurred())
+				err = os.Setenv("NO_DESTINATIONS_STATUS_CODE", "404")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				err := os.Unsetenv("NO_DESTINATIONS_BACKEND")
+				Expect(err).NotTo(HaveOccurred())
+				err = os.Unsetenv("NO_DESTINATIONS_STATUS_CODE")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("loads the no destinations config", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.NoDestinations.Backend).To(Equal("no-app-mapped.cf-system.svc.cluster.local"))
+				Expect(config.NoDestinations.StatusCode).To(Equal(404))
+			})
+
+			Context("when the status code is not an error status code", func() {
+				BeforeEach(func() {
+					err := os.Setenv("NO_DESTINATIONS_STATUS_CODE", "200")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError("NO_DESTINATIONS_STATUS_CODE must be an HTTP error status code"))
+				})
+			})
+		})
+
+		Context("when the concurrency env vars are set", func() {
+			envs := map[string]string{
+				"MAX_CONCURRENT_RECONCILES":    "8",
+				"RECONCILE_BACKOFF_BASE_DELAY": "50ms",
+				"RECONCILE_BACKOFF_MAX_DELAY":  "1m",
+				"RECONCILE_QPS":                "100",
+				"RECONCILE_BURST":              "1000",
+				"KUBERNETES_CLIENT_QPS":        "25.5",
+				"KUBERNETES_CLIENT_BURST":      "50",
+			}
+
+			BeforeEach(func() {
+				for name, value := range envs {
+					Expect(os.Setenv(name, value)).To(Succeed())
+				}
+			})
+
+			AfterEach(func() {
+				for name := range envs {
+					Expect(os.Unsetenv(name)).To(Succeed())
+				}
+			})
+
+			It("loads the concurrency config", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Concurrency.MaxConcurrentReconciles).To(Equal(8))
+				Expect(config.Concurrency.BackoffBaseDelay).To(Equal(50 * time.Millisecond))
+				Expect(config.Concurrency.BackoffMaxDelay).To(Equal(time.Minute))
+				Expect(config.Concurrency.QPS).To(Equal(100.0))
+				Expect(config.Concurrency.Burst).To(Equal(1000))
+				Expect(config.KubernetesClient.QPS).To(Equal(float32(25.5)))
+				Expect(config.KubernetesClient.Burst).To(Equal(50))
+			})
+
+			Context("when MAX_CONCURRENT_RECONCILES is not an integer", func() {
+				BeforeEach(func() {
+					Expect(os.Setenv("MAX_CONCURRENT_RECONCILES", "many")).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError("MAX_CONCURRENT_RECONCILES must be an integer"))
+				})
+			})
+
+			Context("when the maximum backoff is less than the base backoff", func() {
+				BeforeEach(func() {
+					Expect(os.Setenv("RECONCILE_BACKOFF_MAX_DELAY", "10ms")).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError(`invalid config: concurrency.backoffMaxDelay: Invalid value: "10ms": must not be less than backoffBaseDelay`))
+				})
+			})
+
+			Context("when KUBERNETES_CLIENT_BURST is 0", func() {
+				BeforeEach(func() {
+					Expect(os.Setenv("KUBERNETES_CLIENT_BURST", "0")).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError("invalid config: kubernetesClient.burst: Invalid value: 0: must be at least 1"))
+				})
+			})
+		})
+
+		Context("when the concurrency env vars are not set", func() {
+			It("defaults to client-go's controller rate limiter and controller-runtime's client rate limits", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Concurrency.MaxConcurrentReconciles).To(Equal(1))
+				Expect(config.Concurrency.BackoffBaseDelay).To(Equal(5 * time.Millisecond))
+				Expect(config.Concurrency.BackoffMaxDelay).To(Equal(1000 * time.Second))
+				Expect(config.Concurrency.QPS).To(Equal(10.0))
+				Expect(config.Concurrency.Burst).To(Equal(100))
+				Expect(config.KubernetesClient.QPS).To(Equal(float32(20)))
+				Expect(config.KubernetesClient.Burst).To(Equal(30))
+			})
+		})
+
+		Context("when the PROPAGATION env vars are set", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("PROPAGATION_PREFIXES", "prometheus.io/, example.com/")).To(Succeed())
+				Expect(os.Setenv("PROPAGATION_KEYS", "team")).To(Succeed())
+			})
+
+			AfterEach(func() {
+				Expect(os.Unsetenv("PROPAGATION_PREFIXES")).To(Succeed())
+				Expect(os.Unsetenv("PROPAGATION_KEYS")).To(Succeed())
+			})
+
+			It("loads the comma separated lists", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Propagation.Prefixes).To(Equal([]string{"prometheus.io/", "example.com/"}))
+				Expect(config.Propagation.Keys).To(Equal([]string{"team"}))
+			})
+
+			Context("when a key is not a valid label or annotation key", func() {
+				BeforeEach(func() {
+					Expect(os.Setenv("PROPAGATION_KEYS", "not a key")).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError(ContainSubstring("propagation.keys[0]: Invalid value")))
+				})
+			})
+		})
+
+		Context("when ROUTE_ROLLOUT_ANALYSIS_ALLOWED_NETWORKS is set", func() {
+			AfterEach(func() {
+				Expect(os.Unsetenv("ROUTE_ROLLOUT_ANALYSIS_ALLOWED_NETWORKS")).To(Succeed())
+			})
+
+			It("loads the comma separated CIDRs", func() {
+				Expect(os.Setenv("ROUTE_ROLLOUT_ANALYSIS_ALLOWED_NETWORKS", "10.20.0.0/16, fd00::/64")).To(Succeed())
+
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.RouteRollout.AnalysisAllowedNetworks).To(Equal([]string{"10.20.0.0/16", "fd00::/64"}))
+			})
+
+			It("returns an error for networks that are not CIDRs", func() {
+				Expect(os.Setenv("ROUTE_ROLLOUT_ANALYSIS_ALLOWED_NETWORKS", "10.20.0.1")).To(Succeed())
+
+				_, err := cfg.Load()
+				Expect(err).To(MatchError(ContainSubstring("routeRollout.analysisAllowedNetworks[0]: Invalid value")))
+			})
+		})
+
+		Context("when the ISTIO_GATEWAY_WORKLOAD env vars are set", func() {
+			BeforeEach(func() {
+				Expect(os.Setenv("ISTIO_GATEWAY_WORKLOAD_NAMESPACE", "cf-ingress")).To(Succeed())
+				Expect(os.Setenv("ISTIO_GATEWAY_WORKLOAD_SELECTOR", "app=gateway, istio=ingressgateway")).To(Succeed())
+			})
+
+			AfterEach(func() {
+				Expect(os.Unsetenv("ISTIO_GATEWAY_WORKLOAD_NAMESPACE")).To(Succeed())
+				Expect(os.Unsetenv("ISTIO_GATEWAY_WORKLOAD_SELECTOR")).To(Succeed())
+			})
+
+			It("loads the gateway workload", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Istio.GatewayWorkload.Namespace).To(Equal("cf-ingress"))
+				Expect(config.Istio.GatewayWorkload.Selector).To(Equal(map[string]string{
+					"app":   "gateway",
+					"istio": "ingressgateway",
+				}))
+			})
+
+			Context("when the selector is not a list of labels", func() {
+				BeforeEach(func() {
+					Expect(os.Setenv("ISTIO_GATEWAY_WORKLOAD_SELECTOR", "istio")).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError("ISTIO_GATEWAY_WORKLOAD_SELECTOR must be comma separated key=value labels"))
+				})
+			})
+
+			Context("when the selector is empty", func() {
+				BeforeEach(func() {
+					Expect(os.Setenv("ISTIO_GATEWAY_WORKLOAD_SELECTOR", "")).To(Succeed())
+				})
+
+				It("returns an error", func() {
+					_, err := cfg.Load()
+					Expect(err).To(MatchError(ContainSubstring("istio.gatewayWorkload.selector: Required value")))
+				})
+			})
+		})
+
+		Context("when the ISTIO_GATEWAY_WORKLOAD env vars are not set", func() {
+			It("defaults to the istio-system ingress gateway", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.Istio.GatewayWorkload.Namespace).To(Equal("istio-system"))
+				Expect(config.Istio.GatewayWorkload.Selector).To(Equal(map[string]string{"istio": "ingressgateway"}))
+			})
+		})
+
+		Context("when the NO_DESTINATIONS env vars are not set", func() {
+			It("defaults to aborting with a 503", func() {
+				config, err := cfg.Load()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(config.NoDestinations.Backend).To(BeEmpty())
+				Expect(config.NoDestinations.StatusCode).To(Equal(503))
+			})
+		})
+	})
+
+	Describe("LoadFile", func() {
+		var (
+			configDir  string
+			configFile string
+		)
+
+		writeConfig := func(contents string) {
+			err := ioutil.WriteFile(configFile, []byte(contents), 0644)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			for _, env := range []string{"ISTIO_GATEWAY_NAME", "LEADER_ELECTION_NAMESPACE", "RESYNC_INTERVAL", "LOG_LEVEL"} {
+				Expect(os.Unsetenv(env)).To(Succeed())
+			}
+
+			var err error
+			configDir, err = ioutil.TempDir("", "routecontroller-config")
+			Expect(err).NotTo(HaveOccurred())
+			configFile = filepath.Join(configDir, "config.yaml")
+
+			writeConfig(`
+version: v1
+istio:
+  gateway: cf-system/istio-ingressgateway
+  gatewayWorkload:
+    namespace: cf-ingress
+    selector:
+      app: gateway
+leaderElection:
+  namespace: cf-system
+resyncInterval: 15m
+noDestinations:
+  statusCode: 404
+headers:
+  cfIdentity: false
+concurrency:
+  maxConcurrentReconciles: 4
+  backoffBaseDelay: 10ms
+  backoffMaxDelay: 5m
+  qps: 50
+  burst: 500
+kubernetesClient:
+  qps: 40
+  burst: 80
+propagation:
+  prefixes: [prometheus.io/]
+  keys: [team]
+routeRollout:
+  analysisAllowedNetworks: [10.20.0.0/16]
+logging:
+  level: debug
+  encoder: console
+`)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(configDir)).To(Succeed())
+			Expect(os.Unsetenv("LOG_LEVEL")).To(Succeed())
+		})
+
+		It("loads the config file", func() {
+			config, err := cfg.LoadFile(configFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(config.Istio.Gateway).To(Equal("cf-system/istio-ingressgateway"))
+			Expect(config.Istio.GatewayWorkload.Namespace).To(Equal("cf-ingress"))
+			Expect(config.Istio.GatewayWorkload.Selector).To(Equal(map[string]string{"app": "gateway"}))
+			Expect(config.LeaderElectionNamespace).To(Equal("cf-system"))
+			Expect(config.ResyncInterval).To(Equal(15 * time.Minute))
+			Expect(config.NoDestinations.StatusCode).To(Equal(404))
+			Expect(config.Headers.CFIdentity).To(BeFalse())
+			Expect(config.Concurrency.MaxConcurrentReconciles).To(Equal(4))
+			Expect(config.Concurrency.BackoffBaseDelay).To(Equal(10 * time.Millisecond))
+			Expect(config.Concurrency.BackoffMaxDelay).To(Equal(5 * time.Minute))
+			Expect(config.Concurrency.QPS).To(Equal(50.0))
+			Expect(config.Concurrency.Burst).To(Equal(500))
+			Expect(config.KubernetesClient.QPS).To(Equal(float32(40)))
+			Expect(config.KubernetesClient.Burst).To(Equal(80))
+			Expect(config.Propagation.Prefixes).To(Equal([]string{"prometheus.io/"}))
+			Expect(config.Propagation.Keys).To(Equal([]string{"team"}))
+			Expect(config.RouteRollout.AnalysisAllowedNetworks).To(Equal([]string{"10.20.0.0/16"}))
+			Expect(config.Logging.Level).To(Equal("debug"))
+			Expect(config.Logging.Encoder).To(Equal("console"))
+			Expect(config.Logging.StacktraceLevel).To(Equal("error"))
+			Expect(config.Provider).To(Equal("istio"))
+		})
+
+		It("lets env vars override the file", func() {
+			Expect(os.Setenv("LOG_LEVEL", "error")).To(Succeed())
+
+			config, err := cfg.LoadFile(configFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Logging.Level).To(Equal("error"))
+		})
+
+		Context("when the file has an unknown field", func() {
+			BeforeEach(func() {
+				writeConfig("version: v1\nistio:\n  gateways: [cf-system/istio-ingressgateway]\n")
+			})
+
+			It("returns an error", func() {
+				_, err := cfg.LoadFile(configFile)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`unknown field "gateways"`))
+			})
+		})
+
+		Context("when the file has an unsupported version", func() {
+			BeforeEach(func() {
+				writeConfig("version: v2\n")
+			})
+
+			It("returns an error", func() {
+				_, err := cfg.LoadFile(configFile)
+				Expect(err).To(MatchError(fmt.Sprintf(`config file %s has version "v2", only "v1" is supported`, configFile)))
+			})
+		})
+
+		Context("when the file has invalid settings", func() {
+			BeforeEach(func() {
+				writeConfig(`
+version: v1
+provider: contour
+istio:
+  gateway: cf-system/istio-ingressgateway
+leaderElection:
+  namespace: cf-system
+concurrency:
+  maxConcurrentReconciles: -1
+`)
+			})
+
+			It("returns an error naming every invalid setting", func() {
+				_, err := cfg.LoadFile(configFile)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`provider: Unsupported value: "contour"`))
+				Expect(err.Error()).To(ContainSubstring("concurrency.maxConcurrentReconciles: Invalid value: -1: must be at least 1"))
+			})
+		})
+
+		Context("when the file does not exist", func() {
+			It("returns an error", func() {
+				_, err := cfg.LoadFile(filepath.Join(configDir, "missing.yaml"))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("could not read config file"))
+			})
+		})
 	})
 })
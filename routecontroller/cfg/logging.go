@@ -0,0 +1,72 @@
+package cfg
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// SetLogLevel changes the level of a running logger
+type SetLogLevel func(level string) error
+
+// ConfigureLogging applies the logging config to the zap options for every
+// zap flag that was not passed on the command line, since flags take
+// precedence. The returned function changes the level while the manager runs,
+// unless the level was passed as a flag.
+func ConfigureLogging(opts *zap.Options, fs *flag.FlagSet, logging Logging) (SetLogLevel, error) {
+	passed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+
+	if !passed["zap-encoder"] {
+		if err := fs.Set("zap-encoder", logging.Encoder); err != nil {
+			return nil, err
+		}
+	}
+
+	if !passed["zap-stacktrace-level"] {
+		if err := fs.Set("zap-stacktrace-level", logging.StacktraceLevel); err != nil {
+			return nil, err
+		}
+	}
+
+	if passed["zap-log-level"] {
+		return func(string) error { return nil }, nil
+	}
+
+	parsedLevel, err := ParseLogLevel(logging.Level)
+	if err != nil {
+		return nil, err
+	}
+	level := uberzap.NewAtomicLevelAt(parsedLevel)
+	opts.Level = level
+
+	return func(newLevel string) error {
+		parsedLevel, err := ParseLogLevel(newLevel)
+		if err != nil {
+			return err
+		}
+		level.SetLevel(parsedLevel)
+		return nil
+	}, nil
+}
+
+// ParseLogLevel accepts zap level names, and positive numbers for the
+// increasing verbosity of logr's V-levels
+func ParseLogLevel(level string) (zapcore.Level, error) {
+	var parsed zapcore.Level
+	if err := parsed.UnmarshalText([]byte(level)); err == nil {
+		return parsed, nil
+	}
+
+	verbosity, err := strconv.Atoi(level)
+	if err != nil || verbosity <= 0 {
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+	return zapcore.Level(-verbosity), nil
+}
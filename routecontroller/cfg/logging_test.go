@@ -0,0 +1,78 @@
+package cfg_test
+
+import (
+	"flag"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+var _ = Describe("ConfigureLogging", func() {
+	var (
+		fs      *flag.FlagSet
+		opts    zap.Options
+		logging cfg.Logging
+	)
+
+	BeforeEach(func() {
+		opts = zap.Options{}
+		fs = flag.NewFlagSet("routecontroller", flag.ContinueOnError)
+		opts.BindFlags(fs)
+
+		logging = cfg.Logging{Level: "debug", Encoder: "console", StacktraceLevel: "panic"}
+	})
+
+	It("sets the zap options from the config", func() {
+		_, err := cfg.ConfigureLogging(&opts, fs, logging)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fs.Lookup("zap-encoder").Value.String()).To(Equal("console"))
+		Expect(fs.Lookup("zap-stacktrace-level").Value.String()).To(Equal("panic"))
+		Expect(opts.Level.Enabled(zapcore.DebugLevel)).To(BeTrue())
+	})
+
+	It("returns a function that changes the level", func() {
+		setLogLevel, err := cfg.ConfigureLogging(&opts, fs, logging)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(setLogLevel("error")).To(Succeed())
+		Expect(opts.Level.Enabled(zapcore.InfoLevel)).To(BeFalse())
+		Expect(opts.Level.Enabled(zapcore.ErrorLevel)).To(BeTrue())
+	})
+
+	Context("when the flags are passed", func() {
+		BeforeEach(func() {
+			Expect(fs.Parse([]string{"-zap-log-level=error", "-zap-encoder=json"})).To(Succeed())
+		})
+
+		It("lets the flags take precedence", func() {
+			setLogLevel, err := cfg.ConfigureLogging(&opts, fs, logging)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fs.Lookup("zap-encoder").Value.String()).To(Equal("json"))
+			Expect(opts.Level.Enabled(zapcore.InfoLevel)).To(BeFalse())
+
+			Expect(setLogLevel("debug")).To(Succeed())
+			Expect(opts.Level.Enabled(zapcore.InfoLevel)).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("ParseLogLevel", func() {
+	It("parses level names", func() {
+		Expect(cfg.ParseLogLevel("warn")).To(Equal(zapcore.WarnLevel))
+	})
+
+	It("parses verbosities", func() {
+		Expect(cfg.ParseLogLevel("3")).To(Equal(zapcore.Level(-3)))
+	})
+
+	It("rejects anything else", func() {
+		_, err := cfg.ParseLogLevel("loud")
+		Expect(err).To(MatchError(`invalid log level "loud"`))
+
+		_, err = cfg.ParseLogLevel("0")
+		Expect(err).To(MatchError(`invalid log level "0"`))
+	})
+})
@@ -0,0 +1,30 @@
+package cfg
+
+import (
+	"sync"
+)
+
+// Store holds the current configuration, which is replaced whenever the
+// configuration file is reloaded
+type Store struct {
+	mu     sync.RWMutex
+	config Config
+}
+
+func NewStore(config *Config) *Store {
+	return &Store{config: *config}
+}
+
+// Get returns a copy, so callers keep a consistent view for a whole reconcile
+func (s *Store) Get() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	config := s.config
+	return &config
+}
+
+func (s *Store) Set(config *Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = *config
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// Watcher reloads the configuration file when it changes. Settings that are
+// only read when the manager starts keep their value until it restarts.
+type Watcher struct {
+	Path  string
+	Store *Store
+	Log   logr.Logger
+	// OnReload is called with every configuration that has been stored
+	OnReload func(*Config)
+
+	lastLoaded *Config
+}
+
+// Start watches the file's directory rather than the file, because ConfigMap
+// volumes are updated by swapping a symlink
+func (w *Watcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(w.Path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.reload()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.Log.Error(err, "config file watch failed", "path", w.Path)
+		}
+	}
+}
+
+// NeedLeaderElection is false since every replica reloads its own configuration
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+func (w *Watcher) reload() {
+	config, err := LoadFile(w.Path)
+	if err != nil {
+		w.Log.Error(err, "config file has not been reloaded", "path", w.Path)
+		return
+	}
+
+	if w.lastLoaded != nil && reflect.DeepEqual(config, w.lastLoaded) {
+		return
+	}
+	w.lastLoaded = config
+
+	running := w.Store.Get()
+	reloaded := *config
+	for _, setting := range reloaded.keepStartupSettings(running) {
+		w.Log.Info("config change requires a restart", "path", w.Path, "setting", setting)
+	}
+
+	if reflect.DeepEqual(&reloaded, running) {
+		return
+	}
+
+	w.Store.Set(&reloaded)
+	w.Log.Info("config file has been reloaded", "path", w.Path)
+	if w.OnReload != nil {
+		w.OnReload(&reloaded)
+	}
+}
+
+// keepStartupSettings resets the settings that are only read when the manager
+// starts and returns the ones that were changed
+func (c *Config) keepStartupSettings(running *Config) []string {
+	changed := []string{}
+	if c.LeaderElectionNamespace != running.LeaderElectionNamespace {
+		changed = append(changed, "leaderElection.namespace")
+		c.LeaderElectionNamespace = running.LeaderElectionNamespace
+	}
+	if c.Provider != running.Provider {
+		changed = append(changed, "provider")
+		c.Provider = running.Provider
+	}
+	if c.KubernetesClient != running.KubernetesClient {
+		changed = append(changed, "kubernetesClient")
+		c.KubernetesClient = running.KubernetesClient
+	}
+	if c.Concurrency != running.Concurrency {
+		changed = append(changed, "concurrency")
+		c.Concurrency = running.Concurrency
+	}
+	if c.Logging.Encoder != running.Logging.Encoder {
+		changed = append(changed, "logging.encoder")
+		c.Logging.Encoder = running.Logging.Encoder
+	}
+	if c.Logging.StacktraceLevel != running.Logging.StacktraceLevel {
+		changed = append(changed, "logging.stacktraceLevel")
+		c.Logging.StacktraceLevel = running.Logging.StacktraceLevel
+	}
+	return changed
+}
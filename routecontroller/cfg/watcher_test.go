@@ -0,0 +1,92 @@
+package cfg_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("Watcher", func() {
+	var (
+		configDir  string
+		configFile string
+		store      *cfg.Store
+		reloaded   chan *cfg.Config
+		cancel     context.CancelFunc
+	)
+
+	writeConfig := func(resyncInterval, namespace string) {
+		contents := "version: v1\nistio:\n  gateway: cf-system/istio-ingressgateway\n" +
+			"leaderElection:\n  namespace: " + namespace + "\nresyncInterval: " + resyncInterval + "\n"
+		err := ioutil.WriteFile(configFile, []byte(contents), 0644)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		for _, env := range []string{"ISTIO_GATEWAY_NAME", "LEADER_ELECTION_NAMESPACE", "RESYNC_INTERVAL"} {
+			Expect(os.Unsetenv(env)).To(Succeed())
+		}
+
+		var err error
+		configDir, err = ioutil.TempDir("", "routecontroller-config")
+		Expect(err).NotTo(HaveOccurred())
+		configFile = filepath.Join(configDir, "config.yaml")
+		writeConfig("30s", "cf-system")
+
+		config, err := cfg.LoadFile(configFile)
+		Expect(err).NotTo(HaveOccurred())
+		store = cfg.NewStore(config)
+		reloaded = make(chan *cfg.Config, 10)
+
+		watcher := &cfg.Watcher{
+			Path:  configFile,
+			Store: store,
+			Log:   log.Log,
+			OnReload: func(config *cfg.Config) {
+				reloaded <- config
+			},
+		}
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			errs <- watcher.Start(ctx)
+		}()
+		Consistently(errs, 100*time.Millisecond).ShouldNot(Receive())
+	})
+
+	AfterEach(func() {
+		cancel()
+		Expect(os.RemoveAll(configDir)).To(Succeed())
+	})
+
+	It("stores the reloaded config", func() {
+		writeConfig("5m", "cf-system")
+
+		Eventually(reloaded).Should(Receive())
+		Expect(store.Get().ResyncInterval).To(Equal(5 * time.Minute))
+	})
+
+	It("keeps settings that need a restart", func() {
+		writeConfig("5m", "other-namespace")
+
+		Eventually(reloaded).Should(Receive())
+		Expect(store.Get().ResyncInterval).To(Equal(5 * time.Minute))
+		Expect(store.Get().LeaderElectionNamespace).To(Equal("cf-system"))
+	})
+
+	It("keeps the running config when the file is invalid", func() {
+		writeConfig("-5m", "cf-system")
+
+		Consistently(reloaded, 200*time.Millisecond).ShouldNot(Receive())
+		Expect(store.Get().ResyncInterval).To(Equal(30 * time.Second))
+	})
+})
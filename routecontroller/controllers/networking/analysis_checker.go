@@ -0,0 +1,170 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+)
+
+// Only this much of a hook's response is read, the status code decides the analysis
+const (
+	maxAnalysisResponseHeaderBytes = 16 << 10
+	maxAnalysisResponseBodyBytes   = 64 << 10
+)
+
+// Analysis hooks are called from inside the cluster, so without these checks a
+// RouteRollout could have the route controller call the Kubernetes API, cloud
+// metadata endpoints or other internal services. Loopback, link-local, private
+// and other special purpose addresses are refused unless they are allowed by
+// config.
+var internalNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// HTTPAnalysisChecker passes the analysis when the hook responds with a 2xx.
+// Hooks must be http or https urls on addresses outside of the cluster's
+// internal networks, which is checked for every connection, including those of
+// redirects, after the hook's host has been resolved.
+type HTTPAnalysisChecker struct {
+	// Config is read on every connection, so reloaded allowed networks apply
+	// to the next one
+	Config *cfg.Store
+	client *http.Client
+}
+
+func NewHTTPAnalysisChecker(config *cfg.Store, timeout time.Duration) *HTTPAnalysisChecker {
+	c := &HTTPAnalysisChecker{Config: config}
+	dialer := &net.Dialer{Timeout: timeout, Control: c.checkAddress}
+	c.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// a proxy would be dialed instead of the hook, so none is used
+			Proxy:                  nil,
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    timeout,
+			MaxResponseHeaderBytes: maxAnalysisResponseHeaderBytes,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return validateAnalysisURL(req.URL.String())
+		},
+	}
+	return c
+}
+
+func (c *HTTPAnalysisChecker) Check(ctx context.Context, url string) error {
+	if err := validateAnalysisURL(url); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drained up to the limit, so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxAnalysisResponseBodyBytes))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("analysis hook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkAddress is called with the resolved address of every connection
+func (c *HTTPAnalysisChecker) checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("analysis hook address %s is not an IP", host)
+	}
+
+	for _, allowed := range parseCIDRs(c.Config.Get().RouteRollout.AnalysisAllowedNetworks...) {
+		if allowed.Contains(ip) {
+			return nil
+		}
+	}
+	for _, internal := range internalNetworks {
+		if internal.Contains(ip) {
+			return fmt.Errorf("analysis hook address %s is in the internal network %s, which is not allowed by routeRollout.analysisAllowedNetworks", ip, internal)
+		}
+	}
+	return nil
+}
+
+func validateAnalysisURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("url %q is invalid: %s", rawURL, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("url %q must be an http or https url", rawURL)
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("url %q has no host", rawURL)
+	}
+	return nil
+}
+
+// invalid CIDRs are skipped, the config has already validated its networks
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,71 @@
+package networking_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/controllers/networking"
+)
+
+var _ = Describe("HTTPAnalysisChecker", func() {
+	var (
+		store   *cfg.Store
+		checker *networking.HTTPAnalysisChecker
+		hook    *httptest.Server
+		status  int
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		hook = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/redirect" {
+				http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+				return
+			}
+			w.WriteHeader(status)
+		}))
+
+		// the test server listens on loopback, which has to be allowed
+		config := &cfg.Config{}
+		config.RouteRollout.AnalysisAllowedNetworks = []string{"127.0.0.0/8"}
+		store = cfg.NewStore(config)
+		checker = networking.NewHTTPAnalysisChecker(store, 5*time.Second)
+	})
+
+	AfterEach(func() {
+		hook.Close()
+	})
+
+	It("passes when the hook responds with a 2xx", func() {
+		Expect(checker.Check(context.Background(), hook.URL)).To(Succeed())
+	})
+
+	It("fails when the hook responds with anything else", func() {
+		status = http.StatusInternalServerError
+
+		Expect(checker.Check(context.Background(), hook.URL)).To(MatchError("analysis hook responded with status 500"))
+	})
+
+	It("refuses hooks on internal addresses once they are no longer allowed", func() {
+		store.Set(&cfg.Config{})
+
+		err := checker.Check(context.Background(), hook.URL)
+		Expect(err).To(MatchError(ContainSubstring("is in the internal network 127.0.0.0/8")))
+	})
+
+	It("refuses redirects to internal addresses", func() {
+		err := checker.Check(context.Background(), hook.URL+"/redirect")
+		Expect(err).To(MatchError(ContainSubstring("analysis hook address 169.254.169.254 is in the internal network 169.254.0.0/16")))
+	})
+
+	It("refuses urls that are not http or https", func() {
+		err := checker.Check(context.Background(), "file:///var/run/secrets/kubernetes.io/serviceaccount/token")
+		Expect(err).To(MatchError(`url "file:///var/run/secrets/kubernetes.io/serviceaccount/token" must be an http or https url`))
+	})
+})
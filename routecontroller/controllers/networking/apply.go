@@ -0,0 +1,164 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/security/v1beta1"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/resourcebuilders"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// fieldManager is the field manager that owns the fields the route controller applies
+const fieldManager = "routecontroller"
+
+// applyAll sets the desired state hash of the resources and applies them. A
+// resource with fields managed by another field manager is not applied, its
+// conflict is returned instead so the others are still applied.
+func applyAll(ctx context.Context, c client.Client, log logr.Logger, desired []client.Object) ([]string, error) {
+	conflicts := []string{}
+	for _, object := range desired {
+		gvk, err := apiutil.GVKForObject(object, c.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		kind := strings.ToLower(gvk.Kind)
+		spec, err := specOf(object)
+		if err != nil {
+			return nil, err
+		}
+		if err := resourcebuilders.SetDesiredStateHash(object, spec); err != nil {
+			return nil, err
+		}
+
+		result, err := apply(ctx, c, object)
+		if apierrors.IsConflict(err) {
+			log.Info(gvk.Kind+" has fields managed by another field manager", kind, objectKey(object), "action", "apply", "result", "conflict", "conflict", err.Error())
+			conflicts = append(conflicts, err.Error())
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		log.Info(gvk.Kind+" has been reconciled", kind, objectKey(object), "action", "apply", "result", result)
+	}
+	return conflicts, nil
+}
+
+// specOf returns the spec of a resource the route controller builds, which its
+// desired state hash covers along with its metadata
+func specOf(object client.Object) (interface{}, error) {
+	switch object := object.(type) {
+	case *corev1.Service:
+		return &object.Spec, nil
+	case *istionetworkingv1alpha3.VirtualService:
+		return &object.Spec, nil
+	case *istionetworkingv1alpha3.ServiceEntry:
+		return &object.Spec, nil
+	case *istionetworkingv1alpha3.EnvoyFilter:
+		return &object.Spec, nil
+	case *istiosecurityv1beta1.AuthorizationPolicy:
+		return &object.Spec, nil
+	}
+	return nil, fmt.Errorf("no desired state hash for %T", object)
+}
+
+// apply writes the desired state with server-side apply, so the route
+// controller only owns the fields it sets and leaves fields added by other
+// tools alone. Fields owned by another field manager are not taken over, the
+// apiserver returns a conflict instead. Resources the route controller wrote
+// with updates before it used server-side apply are migrated first. The write
+// is skipped when the resource still has the hash of the desired state and its
+// fields have not been edited since, so resyncs don't write every resource but
+// still undo drift.
+func apply(ctx context.Context, c client.Client, desired client.Object) (string, error) {
+	// apply patches are sent as the whole object, which needs its kind
+	gvk, err := apiutil.GVKForObject(desired, c.Scheme())
+	if err != nil {
+		return "", err
+	}
+	desired.GetObjectKind().SetGroupVersionKind(gvk)
+
+	// a new object, since Get decodes into the fields it is given rather than
+	// replacing them
+	newObject, err := c.Scheme().New(gvk)
+	if err != nil {
+		return "", err
+	}
+	actual := newObject.(client.Object)
+	err = c.Get(ctx, client.ObjectKeyFromObject(desired), actual)
+	if client.IgnoreNotFound(err) != nil {
+		return "", err
+	}
+	if err == nil {
+		unchanged, err := resourcebuilders.HasDesiredState(actual, desired)
+		if err != nil {
+			return "", err
+		}
+		if unchanged {
+			return "unchanged", nil
+		}
+		if err := migrateUpdateManagedFields(ctx, c, actual); err != nil {
+			return "", err
+		}
+	}
+
+	if err := c.Patch(ctx, desired, client.Apply, client.FieldOwner(fieldManager)); err != nil {
+		return "", err
+	}
+	return "applied", nil
+}
+
+// Before server-side apply, the route controller wrote resources with updates,
+// so their fields are owned by its Update entry in managedFields. Apply would
+// conflict with that entry as if it were another field manager, so on the first
+// apply the entry is turned into the route controller's Apply entry.
+func migrateUpdateManagedFields(ctx context.Context, c client.Client, actual client.Object) error {
+	managedFields := actual.GetManagedFields()
+	migrated := make([]metav1.ManagedFieldsEntry, 0, len(managedFields))
+	found := false
+	for _, entry := range managedFields {
+		if entry.Manager != fieldManager {
+			migrated = append(migrated, entry)
+			continue
+		}
+		if entry.Operation == metav1.ManagedFieldsOperationApply {
+			return nil
+		}
+		if entry.Operation == metav1.ManagedFieldsOperationUpdate && !found {
+			entry.Operation = metav1.ManagedFieldsOperationApply
+			found = true
+		}
+		migrated = append(migrated, entry)
+	}
+	if !found {
+		return nil
+	}
+
+	// the resource version guards against migrating entries that changed meanwhile
+	patch := client.MergeFromWithOptions(actual.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+	actual.SetManagedFields(migrated)
+	return c.Patch(ctx, actual, patch, client.FieldOwner(fieldManager))
+}
@@ -0,0 +1,98 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"sort"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+)
+
+// Routes can set this annotation to "true" to claim their FQDN when Routes in
+// other namespaces share it
+const fqdnClaimAnnotation = "networking.cloudfoundry.org/fqdn-claim"
+
+// Only one namespace may own an FQDN, since its VirtualService lives there.
+// Routes in any other namespace are conflicted and get no VirtualService.
+func partitionRoutesByFQDNOwner(routes []networkingv1alpha1.Route) (owned, conflicted []networkingv1alpha1.Route) {
+	owner := fqdnOwnerNamespace(routes)
+	for _, route := range routes {
+		if route.ObjectMeta.Namespace == owner {
+			owned = append(owned, route)
+		} else {
+			conflicted = append(conflicted, route)
+		}
+	}
+	return owned, conflicted
+}
+
+// An explicit claim wins over age: the namespace of the oldest claiming route
+// owns the FQDN, or of the oldest route when no route claims it.
+func fqdnOwnerNamespace(routes []networkingv1alpha1.Route) string {
+	candidates := []networkingv1alpha1.Route{}
+	for _, route := range routes {
+		if route.ObjectMeta.Annotations[fqdnClaimAnnotation] == "true" {
+			candidates = append(candidates, route)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = append(candidates, routes...)
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return olderRoute(candidates[i], candidates[j])
+	})
+	return candidates[0].ObjectMeta.Namespace
+}
+
+// ties are broken by namespace and name so every replica agrees on the owner
+func olderRoute(a, b networkingv1alpha1.Route) bool {
+	aCreated, bCreated := a.ObjectMeta.CreationTimestamp, b.ObjectMeta.CreationTimestamp
+	if !aCreated.Equal(&bCreated) {
+		return aCreated.Before(&bCreated)
+	}
+	if a.ObjectMeta.Namespace != b.ObjectMeta.Namespace {
+		return a.ObjectMeta.Namespace < b.ObjectMeta.Namespace
+	}
+	return a.ObjectMeta.Name < b.ObjectMeta.Name
+}
+
+// Only one wildcard route per domain may catch its unmatched hosts, so the
+// oldest one is kept and the others are duplicates. Wildcard routes all share
+// the FQDN of their domain, so routes is expected to be owned routes of one
+// FQDN. Routes that are not wildcard routes are always kept.
+func partitionDuplicateWildcardRoutes(routes []networkingv1alpha1.Route) (kept, duplicates []networkingv1alpha1.Route) {
+	var oldest *networkingv1alpha1.Route
+	for i := range routes {
+		if routes[i].IsWildcard() && (oldest == nil || olderRoute(routes[i], *oldest)) {
+			oldest = &routes[i]
+		}
+	}
+
+	for _, route := range routes {
+		if route.IsWildcard() && (route.ObjectMeta.Namespace != oldest.ObjectMeta.Namespace || route.ObjectMeta.Name != oldest.ObjectMeta.Name) {
+			duplicates = append(duplicates, route)
+		} else {
+			kept = append(kept, route)
+		}
+	}
+	return kept, duplicates
+}
@@ -0,0 +1,149 @@
+package networking
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("FQDN ownership", func() {
+	var (
+		now    time.Time
+		routes []networkingv1alpha1.Route
+	)
+
+	constructRoute := func(namespace, name string, age time.Duration) networkingv1alpha1.Route {
+		return networkingv1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Spec: networkingv1alpha1.RouteSpec{
+				Host:   "test0",
+				Domain: networkingv1alpha1.RouteDomain{Name: "domain0.example.com"},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		now = time.Now().Truncate(time.Second)
+		routes = []networkingv1alpha1.Route{
+			constructRoute("namespace-a", "route-guid-0", time.Minute),
+			constructRoute("namespace-b", "route-guid-1", time.Hour),
+			constructRoute("namespace-a", "route-guid-2", time.Second),
+		}
+	})
+
+	It("gives the FQDN to the namespace of the oldest route", func() {
+		owned, conflicted := partitionRoutesByFQDNOwner(routes)
+		Expect(owned).To(ConsistOf(routes[1]))
+		Expect(conflicted).To(ConsistOf(routes[0], routes[2]))
+	})
+
+	Context("when a route claims the FQDN", func() {
+		BeforeEach(func() {
+			routes[2].ObjectMeta.Annotations = map[string]string{fqdnClaimAnnotation: "true"}
+		})
+
+		It("gives the FQDN to the namespace of the claiming route", func() {
+			owned, conflicted := partitionRoutesByFQDNOwner(routes)
+			Expect(owned).To(ConsistOf(routes[0], routes[2]))
+			Expect(conflicted).To(ConsistOf(routes[1]))
+		})
+	})
+
+	Context("when routes in several namespaces claim the FQDN", func() {
+		BeforeEach(func() {
+			routes[0].ObjectMeta.Annotations = map[string]string{fqdnClaimAnnotation: "true"}
+			routes[1].ObjectMeta.Annotations = map[string]string{fqdnClaimAnnotation: "true"}
+		})
+
+		It("gives the FQDN to the namespace of the oldest claiming route", func() {
+			owned, _ := partitionRoutesByFQDNOwner(routes)
+			Expect(owned).To(ConsistOf(routes[1]))
+		})
+	})
+
+	Context("when routes were created at the same time", func() {
+		BeforeEach(func() {
+			routes = []networkingv1alpha1.Route{
+				constructRoute("namespace-b", "route-guid-0", time.Minute),
+				constructRoute("namespace-a", "route-guid-1", time.Minute),
+			}
+		})
+
+		It("breaks the tie by namespace", func() {
+			Expect(fqdnOwnerNamespace(routes)).To(Equal("namespace-a"))
+		})
+	})
+
+	Context("when all routes are in the same namespace", func() {
+		BeforeEach(func() {
+			routes = []networkingv1alpha1.Route{
+				constructRoute("namespace-a", "route-guid-0", time.Minute),
+				constructRoute("namespace-a", "route-guid-1", time.Hour),
+			}
+		})
+
+		It("does not conflict any route", func() {
+			owned, conflicted := partitionRoutesByFQDNOwner(routes)
+			Expect(owned).To(HaveLen(2))
+			Expect(conflicted).To(BeEmpty())
+		})
+	})
+
+	Describe("duplicate wildcard routes", func() {
+		BeforeEach(func() {
+			for i := range routes {
+				routes[i].ObjectMeta.Namespace = "namespace-a"
+				routes[i].Spec.Host = networkingv1alpha1.WildcardHost
+			}
+			routes = append(routes, constructRoute("namespace-a", "route-guid-3", 2*time.Hour))
+		})
+
+		It("keeps the oldest wildcard route and every other route", func() {
+			kept, duplicates := partitionDuplicateWildcardRoutes(routes)
+			Expect(kept).To(ConsistOf(routes[1], routes[3]))
+			Expect(duplicates).To(ConsistOf(routes[0], routes[2]))
+		})
+
+		It("keeps every route when there are no wildcard routes", func() {
+			kept, duplicates := partitionDuplicateWildcardRoutes(routes[3:])
+			Expect(kept).To(ConsistOf(routes[3]))
+			Expect(duplicates).To(BeEmpty())
+		})
+	})
+
+	Describe("enqueueing the routes of an FQDN", func() {
+		var reconciler *RouteReconciler
+
+		BeforeEach(func() {
+			otherFQDN := constructRoute("namespace-b", "route-guid-3", time.Hour)
+			otherFQDN.Spec.Host = "test1"
+
+			scheme := runtime.NewScheme()
+			Expect(networkingv1alpha1.AddToScheme(scheme)).To(Succeed())
+			reconciler = &RouteReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&routes[0], &routes[1], &routes[2], &otherFQDN).Build(),
+				Log:    log.Log,
+			}
+		})
+
+		It("enqueues the other routes of the changed route's FQDN, so their ownership is reconciled", func() {
+			Expect(reconciler.routeRequestsForFQDN(&routes[0])).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "namespace-b", Name: "route-guid-1"}},
+				reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "namespace-a", Name: "route-guid-2"}},
+			))
+		})
+	})
+})
@@ -0,0 +1,57 @@
+package networking_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestNetworking(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Networking Controllers Suite")
+}
+
+// fakeApplyClient stands in for server-side apply, which the fake client does
+// not support, by creating the object or merge patching it. Like the apiserver,
+// it fails with a conflict when the object has managedFields entries other
+// than the route controller's Apply entry. Setting conflict makes every apply
+// fail with it.
+type fakeApplyClient struct {
+	client.Client
+	conflict error
+}
+
+func (c *fakeApplyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	if c.conflict != nil {
+		return c.conflict
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range existing.GetManagedFields() {
+		if entry.Manager != "routecontroller" || entry.Operation != metav1.ManagedFieldsOperationApply {
+			return apierrors.NewApplyConflict(nil, fmt.Sprintf("Apply failed with 1 conflict: conflict with %q using %s", entry.Manager, entry.APIVersion))
+		}
+	}
+	return c.Client.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data))
+}
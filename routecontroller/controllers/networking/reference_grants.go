@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/resourcebuilders"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+)
+
+// referenceGrants maps a destination namespace to the route namespaces it has
+// granted access to
+type referenceGrants map[string]map[string]bool
+
+func listReferenceGrants(ctx context.Context, c client.Reader) (referenceGrants, error) {
+	grantList := &networkingv1alpha1.RouteReferenceGrantList{}
+	if err := c.List(ctx, grantList); err != nil {
+		return nil, err
+	}
+
+	grants := referenceGrants{}
+	for _, grant := range grantList.Items {
+		if grants[grant.Namespace] == nil {
+			grants[grant.Namespace] = map[string]bool{}
+		}
+		for _, from := range grant.Spec.From {
+			grants[grant.Namespace][from.Namespace] = true
+		}
+	}
+	return grants, nil
+}
+
+// A route may always send traffic to its own namespace
+func (g referenceGrants) allows(fromNamespace, toNamespace string) bool {
+	return fromNamespace == toNamespace || g[toNamespace][fromNamespace]
+}
+
+// Destinations that have not been granted are left out, as if the route did
+// not have them, so they get neither a Service nor traffic
+func (g referenceGrants) filterDestinations(route networkingv1alpha1.Route) networkingv1alpha1.Route {
+	filtered := *route.DeepCopy()
+	filtered.Spec.Destinations = []networkingv1alpha1.RouteDestination{}
+	for _, destination := range route.Spec.Destinations {
+		if g.allows(route.ObjectMeta.Namespace, resourcebuilders.DestinationNamespace(route, destination)) {
+			filtered.Spec.Destinations = append(filtered.Spec.Destinations, destination)
+		}
+	}
+	return filtered
+}
+
+func (g referenceGrants) filterRoutes(routes []networkingv1alpha1.Route) []networkingv1alpha1.Route {
+	filtered := []networkingv1alpha1.Route{}
+	for _, route := range routes {
+		filtered = append(filtered, g.filterDestinations(route))
+	}
+	return filtered
+}
+
+// Granting or revoking access affects the routes of every namespace the
+// grant names, since any of them may have destinations in the grant's namespace
+func routesForReferenceGrant(c client.Reader, log logr.Logger, obj client.Object) []networkingv1alpha1.Route {
+	grant, ok := obj.(*networkingv1alpha1.RouteReferenceGrant)
+	if !ok {
+		return nil
+	}
+
+	routes := []networkingv1alpha1.Route{}
+	for _, from := range grant.Spec.From {
+		routeList := &networkingv1alpha1.RouteList{}
+		if err := c.List(context.Background(), routeList, client.InNamespace(from.Namespace)); err != nil {
+			log.Error(err, "failed to list routes for RouteReferenceGrant", "namespace", from.Namespace)
+			continue
+		}
+		routes = append(routes, routeList.Items...)
+	}
+	return routes
+}
+
+func (r *RouteReconciler) routeRequestsForReferenceGrant(obj client.Object) []reconcile.Request {
+	requests := []reconcile.Request{}
+	for _, route := range routesForReferenceGrant(r.Client, r.Log, obj) {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: route.Namespace, Name: route.Name},
+		})
+	}
+	return requests
+}
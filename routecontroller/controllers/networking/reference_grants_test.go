@@ -0,0 +1,71 @@
+package networking
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Reference grants", func() {
+	var (
+		grants referenceGrants
+		route  networkingv1alpha1.Route
+	)
+
+	destinationGuids := func(route networkingv1alpha1.Route) []string {
+		guids := []string{}
+		for _, destination := range route.Spec.Destinations {
+			guids = append(guids, destination.Guid)
+		}
+		return guids
+	}
+
+	BeforeEach(func() {
+		grants = referenceGrants{
+			"namespace-b": {"namespace-a": true},
+		}
+
+		route = networkingv1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "route-guid-0",
+				Namespace: "namespace-a",
+			},
+			Spec: networkingv1alpha1.RouteSpec{
+				Destinations: []networkingv1alpha1.RouteDestination{
+					{Guid: "same-namespace-guid"},
+					{Guid: "explicit-same-namespace-guid", Namespace: "namespace-a"},
+					{Guid: "granted-guid", Namespace: "namespace-b"},
+					{Guid: "not-granted-guid", Namespace: "namespace-c"},
+				},
+			},
+		}
+	})
+
+	It("keeps destinations in the route's namespace and in namespaces that granted it access", func() {
+		filtered := grants.filterDestinations(route)
+		Expect(destinationGuids(filtered)).To(Equal([]string{
+			"same-namespace-guid",
+			"explicit-same-namespace-guid",
+			"granted-guid",
+		}))
+	})
+
+	It("does not modify the original route", func() {
+		grants.filterDestinations(route)
+		Expect(route.Spec.Destinations).To(HaveLen(4))
+	})
+
+	It("only grants access to the namespaces the grant names", func() {
+		route.ObjectMeta.Namespace = "namespace-c"
+		route.Spec.Destinations[1].Namespace = "namespace-c"
+
+		filtered := grants.filterDestinations(route)
+		Expect(destinationGuids(filtered)).To(Equal([]string{
+			"same-namespace-guid",
+			"explicit-same-namespace-guid",
+			"not-granted-guid",
+		}))
+	})
+})
@@ -18,18 +18,26 @@ package networking
 import (
 	"context"
 	"fmt"
-	"time"
+	"strings"
 
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
 	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/resourcebuilders"
 	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
 	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -38,10 +46,10 @@ import (
 // RouteReconciler reconciles a Route object
 type RouteReconciler struct {
 	client.Client
-	Log            logr.Logger
-	Scheme         *runtime.Scheme
-	IstioGateway   string
-	ResyncInterval time.Duration
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Config is read on every reconcile, so reloaded settings apply to the next one
+	Config *cfg.Store
 }
 
 const fqdnFieldKey string = "spec.fqdn"
@@ -50,9 +58,11 @@ const finalizerName string = "routes.networking.cloudfoundry.org"
 
 // +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=routes,verbs=get;list;watch
 // +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=routes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=routereferencegrants,verbs=get;list;watch
 
 func (r *RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	log := r.Log.WithValues("route", req.NamespacedName)
+	// every log line of a reconcile shares its id, so a single route's history can be followed
+	log := r.Log.WithValues("route_guid", req.Name, "namespace", req.Namespace, "reconcile_id", uuid.NewUUID())
 
 	routes := &networkingv1alpha1.RouteList{}
 	route := &networkingv1alpha1.Route{}
@@ -63,12 +73,20 @@ func (r *RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	log = log.WithValues("fqdn", route.FQDN())
 
-	err := r.List(ctx, routes, client.InNamespace(req.Namespace), client.MatchingFields{fqdnFieldKey: route.FQDN()})
+	// Routes for an FQDN are listed across all namespaces so conflicts between namespaces are detected
+	err := r.List(ctx, routes, client.MatchingFields{fqdnFieldKey: route.FQDN()})
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	grants, err := listReferenceGrants(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	grantedRoute := grants.filterDestinations(*route)
+
 	if route.ObjectMeta.DeletionTimestamp.IsZero() {
 		if !hasFinalizer(route, finalizerName) {
 			controllerutil.AddFinalizer(route, finalizerName)
@@ -78,7 +96,7 @@ func (r *RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		}
 	} else {
 		if hasFinalizer(route, finalizerName) {
-			err = r.finalizeRouteForDeletion(req, route, routes, log, ctx)
+			err = r.finalizeRouteForDeletion(route, log, ctx)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
@@ -87,110 +105,160 @@ func (r *RouteReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, nil
 	}
 
-	err = r.reconcileServices(req, route, log, ctx)
+	serviceConflicts, err := r.reconcileServices(&grantedRoute, log, ctx)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	err = r.reconcileVirtualServices(req, routes, log, ctx)
+	// the VirtualService itself is built by the VirtualServiceReconciler
+	ownedRoutes, _ := partitionRoutesByFQDNOwner(liveRoutes(routes.Items))
+	conflicted := len(ownedRoutes) == 0 || ownedRoutes[0].ObjectMeta.Namespace != req.Namespace
+	if conflicted {
+		log.Info("Route is conflicted, its FQDN belongs to another namespace")
+	}
+	duplicateOf := ""
+	if route.IsWildcard() && !conflicted {
+		kept, _ := partitionDuplicateWildcardRoutes(ownedRoutes)
+		for _, keptRoute := range kept {
+			if keptRoute.IsWildcard() && keptRoute.ObjectMeta.Name != route.ObjectMeta.Name {
+				duplicateOf = keptRoute.ObjectMeta.Name
+				log.Info("Route is a duplicate wildcard route, an older one gets its domain's traffic", "wildcard_route", duplicateOf)
+			}
+		}
+	}
+
+	unpreservingServices, err := r.gatewayServicesNotPreservingSourceAddress(route, ctx)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: r.ResyncInterval}, nil
+	err = r.reconcileStatus(route, &grantedRoute, conflicted, duplicateOf, serviceConflicts, unpreservingServices, log, ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: r.Config.Get().ResyncInterval}, nil
 }
 
-func (r *RouteReconciler) reconcileServices(req ctrl.Request, route *networkingv1alpha1.Route, log logr.Logger, ctx context.Context) error {
-	sb := resourcebuilders.ServiceBuilder{}
+// Services with fields managed by another field manager are skipped and
+// returned as conflicts, so the rest of the route's Services are still applied
+func (r *RouteReconciler) reconcileServices(route *networkingv1alpha1.Route, log logr.Logger, ctx context.Context) ([]string, error) {
+	config := r.Config.Get()
+	sb := resourcebuilders.ServiceBuilder{
+		Propagation: metadataPropagation(config),
+	}
 	desiredServices := sb.Build(route)
 
-	actualServicesForRoute := &corev1.ServiceList{}
-	// get services owned by that route
-	err := r.List(ctx, actualServicesForRoute, client.InNamespace(req.Namespace), client.MatchingFields{serviceOwnerKey: string(route.ObjectMeta.UID)})
+	actualServicesForRoute, err := r.listServicesForRoute(route, ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, desiredService := range desiredServices {
-		service := &corev1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      desiredService.ObjectMeta.Name,
-				Namespace: desiredService.ObjectMeta.Namespace,
-			},
-		}
-		mutateFn := sb.BuildMutateFunction(service, &desiredService)
-		result, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, mutateFn)
-		if err != nil {
-			return err
-		}
-		log.Info(fmt.Sprintf("Service %s/%s has been %s", service.Namespace, service.Name, result))
+	services := []client.Object{}
+	for i := range desiredServices {
+		services = append(services, desiredServices[i].DeepCopy())
+	}
+	conflicts, err := applyAll(ctx, r.Client, log, services)
+	if err != nil {
+		return nil, err
 	}
 
-	servicesToDelete := findServicesForDeletion(actualServicesForRoute.Items, desiredServices)
+	servicesToDelete := findServicesForDeletion(actualServicesForRoute, desiredServices)
 	err = r.deleteServiceList(servicesToDelete, log, ctx)
 
-	return err
+	return conflicts, err
 }
 
-func (r *RouteReconciler) reconcileVirtualServices(req ctrl.Request, routes *networkingv1alpha1.RouteList, log logr.Logger, ctx context.Context) error {
-	vsb := resourcebuilders.VirtualServiceBuilder{IstioGateways: []string{r.IstioGateway}}
-	desiredVirtualServices, err := vsb.Build(routes)
-	if err != nil {
-		return err
-	}
-
-	for _, desiredVirtualService := range desiredVirtualServices {
-		virtualService := &istionetworkingv1alpha3.VirtualService{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      desiredVirtualService.ObjectMeta.Name,
-				Namespace: desiredVirtualService.ObjectMeta.Namespace,
-			},
-		}
-		mutateFn := vsb.BuildMutateFunction(virtualService, &desiredVirtualService)
-		result, err := controllerutil.CreateOrUpdate(ctx, r.Client, virtualService, mutateFn)
+// duplicateOf names the wildcard route of the domain that is kept when the route
+// is a duplicate wildcard route
+// unpreservingServices are the gateway's Services that don't keep the
+// addresses of the clients of a Route restricting its source ranges
+func (r *RouteReconciler) reconcileStatus(route, grantedRoute *networkingv1alpha1.Route, conflicted bool, duplicateOf string, serviceConflicts, unpreservingServices []string, log logr.Logger, ctx context.Context) error {
+	// destinations that have not been granted get no traffic, nor do those of
+	// Routes left out of the resources of their FQDN
+	weights := map[string]int{}
+	activeSetErr := resourcebuilders.ValidateActiveDestinationSet(*grantedRoute)
+	weightsErr := resourcebuilders.ValidateWeights(*grantedRoute)
+	if activeSetErr == nil && weightsErr == nil {
+		grantedWeights, err := resourcebuilders.DestinationWeights(*grantedRoute)
 		if err != nil {
 			return err
 		}
-		log.Info(fmt.Sprintf("VirtualService %s/%s has been %s", virtualService.Namespace, virtualService.Name, result))
+		for i, destination := range grantedRoute.Spec.Destinations {
+			weights[destination.Guid] = grantedWeights[i]
+		}
+	}
+
+	observedStatus := route.Status.DeepCopy()
+
+	route.Status.Destinations = []networkingv1alpha1.RouteDestinationStatus{}
+	for _, destination := range route.Spec.Destinations {
+		route.Status.Destinations = append(route.Status.Destinations, networkingv1alpha1.RouteDestinationStatus{
+			Guid:   destination.Guid,
+			Weight: weights[destination.Guid],
+		})
+	}
+
+	setCondition(&route.Status, networkingv1alpha1.ConditionConflicted, conflicted)
+	setCondition(&route.Status, networkingv1alpha1.ConditionReferenceNotGranted,
+		len(grantedRoute.Spec.Destinations) < len(route.Spec.Destinations))
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionServiceFieldManagerConflict,
+		len(serviceConflicts) > 0, strings.Join(serviceConflicts, "; "))
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionDuplicateWildcard,
+		duplicateOf != "", duplicateWildcardMessage(duplicateOf))
+	fqdnErr := route.ValidateFQDN()
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionInvalidFQDN, fqdnErr != nil, errorMessage(fqdnErr))
+	policyErr := route.ValidatePolicies()
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionInvalidPolicy, policyErr != nil, errorMessage(policyErr))
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionInactiveDestinationSet,
+		activeSetErr != nil, errorMessage(activeSetErr))
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionInvalidWeights,
+		weightsErr != nil, errorMessage(weightsErr))
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionURLMismatch,
+		!route.HasCanonicalURL(), urlMismatchMessage(route))
+	setConditionMessage(&route.Status, networkingv1alpha1.ConditionSourceAddressNotPreserved,
+		len(unpreservingServices) > 0, sourceAddressNotPreservedMessage(unpreservingServices))
+
+	// remember the set that was switched away from so it can be switched back to
+	if route.Status.ActiveDestinationSet != route.Spec.ActiveDestinationSet {
+		if route.Status.ActiveDestinationSet != "" {
+			route.Status.PreviousActiveDestinationSet = route.Status.ActiveDestinationSet
+		}
+		route.Status.ActiveDestinationSet = route.Spec.ActiveDestinationSet
+	}
+
+	// the rate limit is only shown once it can be enforced
+	route.Status.RateLimit = nil
+	if route.ValidateRateLimit() == nil {
+		route.Status.RateLimit = route.EffectiveRateLimit()
+	}
+
+	if equality.Semantic.DeepEqual(&route.Status, observedStatus) {
+		return nil
 	}
 
+	if err := r.Status().Update(ctx, route); err != nil {
+		return err
+	}
+	log.Info("Route status has been reconciled", "action", "update_status", "result", "updated")
+
 	return nil
 }
 
-func (r *RouteReconciler) finalizeRouteForDeletion(req ctrl.Request, route *networkingv1alpha1.Route, routes *networkingv1alpha1.RouteList, log logr.Logger, ctx context.Context) error {
-	actualServicesForRoute := &corev1.ServiceList{}
-	err := r.List(ctx, actualServicesForRoute, client.InNamespace(req.Namespace), client.MatchingFields{serviceOwnerKey: string(route.ObjectMeta.UID)})
+// The VirtualServiceReconciler leaves deleted Routes out of the VirtualService
+// and the other resources of the FQDN, and deletes those that are no longer
+// needed, so only the Route's Services need to be cleaned up here
+func (r *RouteReconciler) finalizeRouteForDeletion(route *networkingv1alpha1.Route, log logr.Logger, ctx context.Context) error {
+	actualServicesForRoute, err := r.listServicesForRoute(route, ctx)
 	if err != nil {
 		return err
 	}
 
-	err = r.deleteServiceList(actualServicesForRoute.Items, log, ctx)
+	err = r.deleteServiceList(actualServicesForRoute, log, ctx)
 	if err != nil {
 		return err
 	}
 
-	routes.Items = removeRouteFromRouteList(route, routes)
-	if len(routes.Items) == 0 {
-		vs := &istionetworkingv1alpha3.VirtualService{}
-		vsName := resourcebuilders.VirtualServiceName(route.FQDN())
-		namespacedVSName := types.NamespacedName{Namespace: req.Namespace, Name: vsName}
-		if err := r.Get(ctx, namespacedVSName, vs); err != nil {
-			if apierrors.IsNotFound(err) {
-				log.Info("VirtualService no longer exists")
-			}
-		} else {
-			err := r.Delete(ctx, vs)
-			if err != nil {
-				return err
-			}
-			log.Info(fmt.Sprintf("VirtualService %s/%s has been deleted", vs.Namespace, vs.Name))
-		}
-	} else {
-		if err := r.reconcileVirtualServices(req, routes, log, ctx); err != nil {
-			return err
-		}
-	}
-
 	controllerutil.RemoveFinalizer(route, finalizerName)
 	if err := r.Update(context.Background(), route); err != nil {
 		return err
@@ -199,6 +267,81 @@ func (r *RouteReconciler) finalizeRouteForDeletion(req ctrl.Request, route *netw
 	return nil
 }
 
+// The gateway matches source ranges against the address of the connection. A
+// Service exposing it outside the cluster with the Cluster external traffic
+// policy forwards connections through other nodes, which replace the client's
+// address with their own. The Services are the LoadBalancer and NodePort
+// Services in the gateway's namespace whose selector agrees with the gateway's.
+// Changes to them show on the Routes with their next resync.
+func (r *RouteReconciler) gatewayServicesNotPreservingSourceAddress(route *networkingv1alpha1.Route, ctx context.Context) ([]string, error) {
+	if !route.RestrictsSourceRanges() {
+		return nil, nil
+	}
+
+	gateway := r.Config.Get().Istio.GatewayWorkload
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services, client.InNamespace(gateway.Namespace)); err != nil {
+		return nil, err
+	}
+
+	unpreserving := []string{}
+	for i := range services.Items {
+		service := &services.Items[i]
+		if service.Spec.Type != corev1.ServiceTypeLoadBalancer && service.Spec.Type != corev1.ServiceTypeNodePort {
+			continue
+		}
+		if !selectorsAgree(service.Spec.Selector, gateway.Selector) {
+			continue
+		}
+		if service.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyTypeLocal {
+			unpreserving = append(unpreserving, objectKey(service))
+		}
+	}
+	return unpreserving, nil
+}
+
+// selectorsAgree is true when the selectors share a label and don't require
+// different values for any label, so they select the same pods
+func selectorsAgree(a, b map[string]string) bool {
+	shared := false
+	for key, value := range a {
+		if other, ok := b[key]; ok {
+			if other != value {
+				return false
+			}
+			shared = true
+		}
+	}
+	return shared
+}
+
+// Services in the route's namespace are owned by it, the ones in other
+// namespaces are found by their route labels
+func (r *RouteReconciler) listServicesForRoute(route *networkingv1alpha1.Route, ctx context.Context) ([]corev1.Service, error) {
+	ownedServices := &corev1.ServiceList{}
+	err := r.List(ctx, ownedServices, client.InNamespace(route.ObjectMeta.Namespace), client.MatchingFields{serviceOwnerKey: string(route.ObjectMeta.UID)})
+	if err != nil {
+		return nil, err
+	}
+
+	labeledServices := &corev1.ServiceList{}
+	err = r.List(ctx, labeledServices, client.MatchingLabels{
+		"cloudfoundry.org/route_guid":        route.ObjectMeta.Name,
+		resourcebuilders.RouteNamespaceLabel: route.ObjectMeta.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	services := ownedServices.Items
+	for _, service := range labeledServices.Items {
+		if service.ObjectMeta.Namespace != route.ObjectMeta.Namespace {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
 func findServicesForDeletion(actualServices, desiredServices []corev1.Service) []corev1.Service {
 	servicesToDelete := []corev1.Service{}
 	for _, existingService := range actualServices {
@@ -224,7 +367,7 @@ func (r *RouteReconciler) deleteServiceList(services []corev1.Service, log logr.
 		if err != nil {
 			return err
 		}
-		log.Info(fmt.Sprintf("Service %s/%s has been deleted", service.Namespace, service.Name))
+		log.Info("Service has been deleted", "service", objectKey(&service), "action", "delete", "result", "deleted")
 	}
 	return nil
 }
@@ -250,10 +393,105 @@ func (r *RouteReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controllerOptions(r.Config.Get())).
 		For(&networkingv1alpha1.Route{}).
+		Watches(&source.Kind{Type: &networkingv1alpha1.Route{}}, handler.EnqueueRequestsFromMapFunc(r.routeRequestsForFQDN)).
+		Watches(&source.Kind{Type: &networkingv1alpha1.RouteReferenceGrant{}}, handler.EnqueueRequestsFromMapFunc(r.routeRequestsForReferenceGrant)).
 		Complete(r)
 }
 
+// Whether a Route is Conflicted or a duplicate wildcard route depends on the
+// other Routes of its FQDN, so every change to a Route, including its deletion,
+// enqueues the others. Updates map both the old and the new Route, so a changed
+// host enqueues the Routes of both FQDNs.
+func (r *RouteReconciler) routeRequestsForFQDN(obj client.Object) []reconcile.Request {
+	changed, ok := obj.(*networkingv1alpha1.Route)
+	if !ok {
+		return nil
+	}
+
+	routes := &networkingv1alpha1.RouteList{}
+	if err := r.List(context.Background(), routes, client.MatchingFields{fqdnFieldKey: changed.FQDN()}); err != nil {
+		r.Log.Error(err, "failed to list routes for FQDN", "fqdn", changed.FQDN())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, route := range routes.Items {
+		if route.FQDN() != changed.FQDN() || (route.ObjectMeta.Namespace == changed.ObjectMeta.Namespace && route.ObjectMeta.Name == changed.ObjectMeta.Name) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: route.ObjectMeta.Namespace, Name: route.ObjectMeta.Name},
+		})
+	}
+	return requests
+}
+
+func metadataPropagation(config *cfg.Config) resourcebuilders.MetadataPropagation {
+	return resourcebuilders.MetadataPropagation{
+		Prefixes: config.Propagation.Prefixes,
+		Keys:     config.Propagation.Keys,
+	}
+}
+
+func authorizationPolicyBuilder(config *cfg.Config) *resourcebuilders.AuthorizationPolicyBuilder {
+	return &resourcebuilders.AuthorizationPolicyBuilder{
+		GatewayNamespace: config.Istio.GatewayWorkload.Namespace,
+		GatewaySelector:  config.Istio.GatewayWorkload.Selector,
+	}
+}
+
+func controllerOptions(config *cfg.Config) controller.Options {
+	return controller.Options{
+		MaxConcurrentReconciles: config.Concurrency.MaxConcurrentReconciles,
+		// failed items back off one by one, while the bucket limits the whole queue
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(config.Concurrency.BackoffBaseDelay, config.Concurrency.BackoffMaxDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(config.Concurrency.QPS), config.Concurrency.Burst)},
+		),
+	}
+}
+
+func setCondition(status *networkingv1alpha1.RouteStatus, conditionType string, conditionStatus bool) {
+	setConditionMessage(status, conditionType, conditionStatus, "")
+}
+
+func setConditionMessage(status *networkingv1alpha1.RouteStatus, conditionType string, conditionStatus bool, message string) {
+	for i, condition := range status.Conditions {
+		if condition.Type == conditionType {
+			status.Conditions[i].Status = conditionStatus
+			status.Conditions[i].Message = message
+			return
+		}
+	}
+	status.Conditions = append(status.Conditions, networkingv1alpha1.Condition{Type: conditionType, Status: conditionStatus, Message: message})
+}
+
+func objectKey(o metav1.Object) string {
+	return types.NamespacedName{Namespace: o.GetNamespace(), Name: o.GetName()}.String()
+}
+
+// Routes that are being deleted no longer take part in their FQDN's VirtualService
+func liveRoutes(routes []networkingv1alpha1.Route) []networkingv1alpha1.Route {
+	live := []networkingv1alpha1.Route{}
+	for _, route := range routes {
+		if route.ObjectMeta.DeletionTimestamp.IsZero() {
+			live = append(live, route)
+		}
+	}
+	return live
+}
+
+func hasCondition(status networkingv1alpha1.RouteStatus, conditionType string, conditionStatus bool, message string) bool {
+	for _, condition := range status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == conditionStatus && condition.Message == message
+		}
+	}
+	return false
+}
+
 func hasFinalizer(o metav1.Object, finalizerName string) bool {
 	for _, f := range o.GetFinalizers() {
 		if f == finalizerName {
@@ -263,12 +501,30 @@ func hasFinalizer(o metav1.Object, finalizerName string) bool {
 	return false
 }
 
-func removeRouteFromRouteList(routeToRemove *networkingv1alpha1.Route, routes *networkingv1alpha1.RouteList) []networkingv1alpha1.Route {
-	for idx, route := range routes.Items {
-		if route.ObjectMeta.Name == routeToRemove.ObjectMeta.Name {
-			return append(routes.Items[:idx], routes.Items[idx+1:]...)
-		}
+func urlMismatchMessage(route *networkingv1alpha1.Route) string {
+	if route.HasCanonicalURL() {
+		return ""
+	}
+	return fmt.Sprintf("url %q does not match the canonical url %q", route.Spec.Url, route.CanonicalURL())
+}
+
+func duplicateWildcardMessage(duplicateOf string) string {
+	if duplicateOf == "" {
+		return ""
 	}
+	return fmt.Sprintf("route %s is the wildcard route of the domain", duplicateOf)
+}
 
-	return routes.Items
+func sourceAddressNotPreservedMessage(services []string) string {
+	if len(services) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("gateway services %s do not have the Local external traffic policy, so the gateway sees node addresses instead of client addresses and the source ranges are not enforced as intended", strings.Join(services, ", "))
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
 }
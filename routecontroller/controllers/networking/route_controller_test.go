@@ -0,0 +1,201 @@
+package networking_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/controllers/networking"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("RouteReconciler", func() {
+	var (
+		ctx        context.Context
+		k8sClient  client.Client
+		reconciler *networking.RouteReconciler
+		objects    []client.Object
+	)
+
+	// The fake client does not apply field selectors, so every Route in these
+	// tests shares the FQDN
+	newRoute := func(name string, allowedSourceRanges ...string) *networkingv1alpha1.Route {
+		return &networkingv1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       name,
+				Namespace:  "workload-namespace",
+				Finalizers: []string{"routes.networking.cloudfoundry.org"},
+			},
+			Spec: networkingv1alpha1.RouteSpec{
+				Host:                "test0",
+				Domain:              networkingv1alpha1.RouteDomain{Name: "domain0.example.com"},
+				AllowedSourceRanges: allowedSourceRanges,
+			},
+		}
+	}
+
+	gatewayService := func(externalTrafficPolicy corev1.ServiceExternalTrafficPolicyType) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "istio-ingressgateway", Namespace: "istio-system"},
+			Spec: corev1.ServiceSpec{
+				Type:                  corev1.ServiceTypeLoadBalancer,
+				Selector:              map[string]string{"app": "istio-ingressgateway", "istio": "ingressgateway"},
+				ExternalTrafficPolicy: externalTrafficPolicy,
+			},
+		}
+	}
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(networkingv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient = &fakeApplyClient{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()}
+		config := &cfg.Config{ResyncInterval: 30 * time.Second}
+		config.Istio.GatewayWorkload.Namespace = "istio-system"
+		config.Istio.GatewayWorkload.Selector = map[string]string{"istio": "ingressgateway"}
+
+		reconciler = &networking.RouteReconciler{
+			Client: k8sClient,
+			Log:    log.Log,
+			Scheme: scheme,
+			Config: cfg.NewStore(config),
+		}
+	})
+
+	reconcile := func(name string) {
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "workload-namespace", Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getRoute := func(name string) *networkingv1alpha1.Route {
+		route := &networkingv1alpha1.Route{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: "workload-namespace", Name: name}, route)).To(Succeed())
+		return route
+	}
+
+	routeCondition := func(name, conditionType string) networkingv1alpha1.Condition {
+		for _, condition := range getRoute(name).Status.Conditions {
+			if condition.Type == conditionType {
+				return condition
+			}
+		}
+		Fail("route has no " + conditionType + " condition")
+		return networkingv1alpha1.Condition{}
+	}
+
+	sourceAddressCondition := func(name string) networkingv1alpha1.Condition {
+		return routeCondition(name, networkingv1alpha1.ConditionSourceAddressNotPreserved)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objects = []client.Object{
+			newRoute("route-guid-0", "10.0.0.0/8"),
+			newRoute("route-guid-1"),
+		}
+	})
+
+	Context("when the gateway's load balancer does not keep the client's address", func() {
+		BeforeEach(func() {
+			objects = append(objects, gatewayService(corev1.ServiceExternalTrafficPolicyTypeCluster))
+		})
+
+		It("reports it on the Routes restricting their source ranges", func() {
+			reconcile("route-guid-0")
+			reconcile("route-guid-1")
+
+			Expect(sourceAddressCondition("route-guid-0")).To(Equal(networkingv1alpha1.Condition{
+				Type:    networkingv1alpha1.ConditionSourceAddressNotPreserved,
+				Status:  true,
+				Message: "gateway services istio-system/istio-ingressgateway do not have the Local external traffic policy, so the gateway sees node addresses instead of client addresses and the source ranges are not enforced as intended",
+			}))
+			Expect(sourceAddressCondition("route-guid-1").Status).To(BeFalse())
+		})
+	})
+
+	Context("when the gateway's load balancer keeps the client's address", func() {
+		BeforeEach(func() {
+			objects = append(objects, gatewayService(corev1.ServiceExternalTrafficPolicyTypeLocal))
+		})
+
+		It("does not report it", func() {
+			reconcile("route-guid-0")
+
+			Expect(sourceAddressCondition("route-guid-0").Status).To(BeFalse())
+		})
+	})
+
+	Context("when the load balancer exposes other pods of the gateway's namespace", func() {
+		BeforeEach(func() {
+			service := gatewayService(corev1.ServiceExternalTrafficPolicyTypeCluster)
+			service.Spec.Selector = map[string]string{"istio": "egressgateway"}
+			objects = append(objects, service)
+		})
+
+		It("does not report it", func() {
+			reconcile("route-guid-0")
+
+			Expect(sourceAddressCondition("route-guid-0").Status).To(BeFalse())
+		})
+	})
+
+	Context("when none of the Route's granted destinations are in its active destination set", func() {
+		BeforeEach(func() {
+			route := objects[1].(*networkingv1alpha1.Route)
+			route.Spec.ActiveDestinationSet = "green"
+			route.Spec.Destinations = []networkingv1alpha1.RouteDestination{
+				{Guid: "destination-guid-0", Set: "blue", Port: intPtr(8080), App: networkingv1alpha1.DestinationApp{Guid: "app-guid", Process: networkingv1alpha1.AppProcess{Type: "web"}}},
+			}
+		})
+
+		It("reports it on the Route and gives the destinations no traffic", func() {
+			reconcile("route-guid-0")
+			reconcile("route-guid-1")
+
+			Expect(routeCondition("route-guid-1", networkingv1alpha1.ConditionInactiveDestinationSet)).To(Equal(networkingv1alpha1.Condition{
+				Type:    networkingv1alpha1.ConditionInactiveDestinationSet,
+				Status:  true,
+				Message: "invalid destinations for route route-guid-1: no destinations in active destination set green",
+			}))
+			Expect(getRoute("route-guid-1").Status.Destinations).To(Equal([]networkingv1alpha1.RouteDestinationStatus{
+				{Guid: "destination-guid-0", Weight: 0},
+			}))
+			Expect(routeCondition("route-guid-0", networkingv1alpha1.ConditionInactiveDestinationSet).Status).To(BeFalse())
+		})
+	})
+
+	Context("when the Route's destinations have invalid weights", func() {
+		BeforeEach(func() {
+			route := objects[1].(*networkingv1alpha1.Route)
+			route.Spec.Destinations = []networkingv1alpha1.RouteDestination{
+				{Guid: "destination-guid-0", Weight: intPtr(-1), Port: intPtr(8080), App: networkingv1alpha1.DestinationApp{Guid: "app-guid", Process: networkingv1alpha1.AppProcess{Type: "web"}}},
+			}
+		})
+
+		It("reports it on the Route and gives the destinations no traffic", func() {
+			reconcile("route-guid-1")
+
+			Expect(routeCondition("route-guid-1", networkingv1alpha1.ConditionInvalidWeights)).To(Equal(networkingv1alpha1.Condition{
+				Type:    networkingv1alpha1.ConditionInvalidWeights,
+				Status:  true,
+				Message: "invalid destinations for route route-guid-1: weights must not be negative",
+			}))
+			Expect(getRoute("route-guid-1").Status.Destinations).To(Equal([]networkingv1alpha1.RouteDestinationStatus{
+				{Guid: "destination-guid-0", Weight: 0},
+			}))
+		})
+	})
+})
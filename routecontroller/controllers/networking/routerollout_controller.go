@@ -0,0 +1,247 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// AnalysisChecker decides whether a rollout may move on to its next step
+type AnalysisChecker interface {
+	Check(ctx context.Context, url string) error
+}
+
+// RouteRolloutReconciler shifts traffic between two destinations of a Route by
+// patching their weights, one step at a time
+type RouteRolloutReconciler struct {
+	client.Client
+	Log             logr.Logger
+	Scheme          *runtime.Scheme
+	AnalysisChecker AnalysisChecker
+}
+
+// +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=routerollouts,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=routerollouts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=networking.cloudfoundry.org,resources=routes,verbs=get;list;watch;patch
+
+func (r *RouteRolloutReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("route_rollout", req.Name, "namespace", req.Namespace, "reconcile_id", uuid.NewUUID())
+
+	rollout := &networkingv1alpha1.RouteRollout{}
+	if err := r.Get(ctx, req.NamespacedName, rollout); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("RouteRollout no longer exists")
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	observedStatus := rollout.Status.DeepCopy()
+
+	phase := rollout.Status.Phase
+	if phase == networkingv1alpha1.RolloutPhaseCompleted ||
+		phase == networkingv1alpha1.RolloutPhaseRolledBack ||
+		phase == networkingv1alpha1.RolloutPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	if rollout.Spec.Paused {
+		// keep the reason the rollout was paused for, such as a failed analysis
+		msg := "rollout is paused"
+		if phase == networkingv1alpha1.RolloutPhasePaused && rollout.Status.Message != "" {
+			msg = rollout.Status.Message
+		}
+		return ctrl.Result{}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhasePaused, msg, log)
+	}
+
+	if err := validateRollout(rollout); err != nil {
+		return ctrl.Result{}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhaseFailed, err.Error(), log)
+	}
+
+	interval := rollout.Spec.StepInterval.Duration
+	if rollout.Status.LastStepTime != nil {
+		elapsed := time.Since(rollout.Status.LastStepTime.Time)
+		if elapsed < interval {
+			return ctrl.Result{RequeueAfter: interval - elapsed}, nil
+		}
+	}
+
+	route := &networkingv1alpha1.Route{}
+	routeName := types.NamespacedName{Namespace: rollout.Namespace, Name: rollout.Spec.RouteName}
+	if err := r.Get(ctx, routeName, route); err != nil {
+		if apierrors.IsNotFound(err) {
+			msg := fmt.Sprintf("route %s does not exist", rollout.Spec.RouteName)
+			return ctrl.Result{RequeueAfter: interval}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhasePaused, msg, log)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := validateRouteForRollout(route, rollout); err != nil {
+		return ctrl.Result{}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhaseFailed, err.Error(), log)
+	}
+
+	// The canary is only analysed once it has received traffic
+	if rollout.Status.CurrentStep > 0 && rollout.Spec.Analysis != nil {
+		if err := r.AnalysisChecker.Check(ctx, rollout.Spec.Analysis.URL); err != nil {
+			msg := fmt.Sprintf("analysis failed: %s", err)
+			// the rollout stays paused until the user resumes it by setting
+			// paused back to false, even if a later analysis would pass
+			if rollout.Spec.Analysis.FailurePolicy == networkingv1alpha1.AnalysisFailurePolicyPause {
+				patch := client.MergeFromWithOptions(rollout.DeepCopy(), client.MergeFromWithOptimisticLock{})
+				rollout.Spec.Paused = true
+				if err := r.Patch(ctx, rollout, patch); err != nil {
+					return ctrl.Result{}, err
+				}
+				now := metav1.Now()
+				rollout.Status.LastStepTime = &now
+				return ctrl.Result{}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhasePaused, msg, log)
+			}
+
+			if err := r.setCanaryWeight(ctx, route, rollout, 0); err != nil {
+				return ctrl.Result{}, err
+			}
+			rollout.Status.CanaryWeight = 0
+			return ctrl.Result{}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhaseRolledBack, msg, log)
+		}
+	}
+
+	if rollout.Status.CurrentStep >= len(rollout.Spec.Steps) {
+		return ctrl.Result{}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhaseCompleted, "", log)
+	}
+
+	weight := rollout.Spec.Steps[rollout.Status.CurrentStep]
+	if err := r.setCanaryWeight(ctx, route, rollout, weight); err != nil {
+		return ctrl.Result{}, err
+	}
+	log.Info("Route canary destination weight has been set", "route_guid", route.Name, "weight", weight, "action", "set_weight", "result", "updated")
+
+	now := metav1.Now()
+	rollout.Status.CurrentStep++
+	rollout.Status.CanaryWeight = weight
+	rollout.Status.LastStepTime = &now
+	return ctrl.Result{RequeueAfter: interval}, r.updateStatus(ctx, rollout, observedStatus, networkingv1alpha1.RolloutPhaseProgressing, "", log)
+}
+
+// The Route's weights are relative, so the canary and stable weights always
+// add up to 100 and the VirtualService builder takes care of the rest
+func (r *RouteRolloutReconciler) setCanaryWeight(ctx context.Context, route *networkingv1alpha1.Route, rollout *networkingv1alpha1.RouteRollout, canaryWeight int) error {
+	patch := client.MergeFromWithOptions(route.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	for i, destination := range route.Spec.Destinations {
+		if destination.Guid == rollout.Spec.CanaryDestination {
+			route.Spec.Destinations[i].Weight = intPtr(canaryWeight)
+		} else {
+			route.Spec.Destinations[i].Weight = intPtr(100 - canaryWeight)
+		}
+	}
+
+	return r.Patch(ctx, route, patch)
+}
+
+func (r *RouteRolloutReconciler) updateStatus(ctx context.Context, rollout *networkingv1alpha1.RouteRollout, observedStatus *networkingv1alpha1.RouteRolloutStatus, phase, message string, log logr.Logger) error {
+	rollout.Status.Phase = phase
+	rollout.Status.Message = message
+	if equality.Semantic.DeepEqual(&rollout.Status, observedStatus) {
+		return nil
+	}
+
+	if err := r.Status().Update(ctx, rollout); err != nil {
+		return err
+	}
+	log.Info("RouteRollout status has been reconciled", "action", "update_status", "result", phase)
+
+	return nil
+}
+
+// A rollout owns all of the Route's weights, so the Route may only have the
+// stable and canary destinations
+func validateRouteForRollout(route *networkingv1alpha1.Route, rollout *networkingv1alpha1.RouteRollout) error {
+	foundStable, foundCanary := false, false
+	for _, destination := range route.Spec.Destinations {
+		switch destination.Guid {
+		case rollout.Spec.StableDestination:
+			foundStable = true
+		case rollout.Spec.CanaryDestination:
+			foundCanary = true
+		default:
+			return fmt.Errorf("route %s has destination %s which is not part of the rollout", route.Name, destination.Guid)
+		}
+	}
+
+	if !foundStable || !foundCanary {
+		return fmt.Errorf("route %s must have both the stable and canary destinations", route.Name)
+	}
+
+	return nil
+}
+
+func validateRollout(rollout *networkingv1alpha1.RouteRollout) error {
+	if len(rollout.Spec.Steps) == 0 {
+		return errors.New("rollout must have at least one step")
+	}
+
+	previous := 0
+	for _, step := range rollout.Spec.Steps {
+		if step <= previous || step > 100 {
+			return errors.New("rollout steps must increase and be between 1 and 100")
+		}
+		previous = step
+	}
+
+	if rollout.Spec.StableDestination == rollout.Spec.CanaryDestination {
+		return errors.New("rollout stable and canary destinations must be different")
+	}
+
+	if rollout.Spec.Analysis != nil {
+		if err := validateAnalysisURL(rollout.Spec.Analysis.URL); err != nil {
+			return fmt.Errorf("rollout analysis %s", err)
+		}
+
+		policy := rollout.Spec.Analysis.FailurePolicy
+		if policy != "" &&
+			policy != networkingv1alpha1.AnalysisFailurePolicyPause &&
+			policy != networkingv1alpha1.AnalysisFailurePolicyRollback {
+			return fmt.Errorf("rollout analysis failure policy must be %s or %s",
+				networkingv1alpha1.AnalysisFailurePolicyPause,
+				networkingv1alpha1.AnalysisFailurePolicyRollback)
+		}
+	}
+
+	return nil
+}
+
+func (r *RouteRolloutReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&networkingv1alpha1.RouteRollout{}).
+		Complete(r)
+}
+
+func intPtr(x int) *int {
+	return &x
+}
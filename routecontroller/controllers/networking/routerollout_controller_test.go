@@ -0,0 +1,294 @@
+package networking_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/controllers/networking"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type fakeAnalysisChecker struct {
+	err   error
+	calls int
+}
+
+func (c *fakeAnalysisChecker) Check(ctx context.Context, url string) error {
+	c.calls++
+	return c.err
+}
+
+var _ = Describe("RouteRolloutReconciler", func() {
+	var (
+		ctx        context.Context
+		k8sClient  client.Client
+		checker    *fakeAnalysisChecker
+		reconciler *networking.RouteRolloutReconciler
+		rollout    *networkingv1alpha1.RouteRollout
+		route      *networkingv1alpha1.Route
+		request    ctrl.Request
+	)
+
+	reconcileAndFetch := func() (ctrl.Result, error) {
+		result, err := reconciler.Reconcile(ctx, request)
+		Expect(k8sClient.Get(ctx, request.NamespacedName, rollout)).To(Succeed())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "workload-namespace", Name: "route-guid-0"}, route)).To(Succeed())
+		return result, err
+	}
+
+	destinationWeights := func() map[string]int {
+		weights := map[string]int{}
+		for _, destination := range route.Spec.Destinations {
+			weights[destination.Guid] = *destination.Weight
+		}
+		return weights
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		checker = &fakeAnalysisChecker{}
+
+		route = &networkingv1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "route-guid-0",
+				Namespace: "workload-namespace",
+			},
+			Spec: networkingv1alpha1.RouteSpec{
+				Host:   "test0",
+				Domain: networkingv1alpha1.RouteDomain{Name: "domain0.example.com"},
+				Destinations: []networkingv1alpha1.RouteDestination{
+					{Guid: "stable-guid"},
+					{Guid: "canary-guid"},
+				},
+			},
+		}
+
+		rollout = &networkingv1alpha1.RouteRollout{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "rollout-0",
+				Namespace: "workload-namespace",
+			},
+			Spec: networkingv1alpha1.RouteRolloutSpec{
+				RouteName:         "route-guid-0",
+				StableDestination: "stable-guid",
+				CanaryDestination: "canary-guid",
+				Steps:             []int{10, 50, 100},
+				StepInterval:      metav1.Duration{Duration: 5 * time.Minute},
+				Analysis: &networkingv1alpha1.RolloutAnalysis{
+					URL: "http://analysis.example.com",
+				},
+			},
+		}
+
+		request = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "workload-namespace", Name: "rollout-0"}}
+	})
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(networkingv1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(route, rollout).Build()
+		reconciler = &networking.RouteRolloutReconciler{
+			Client:          k8sClient,
+			Log:             log.Log,
+			Scheme:          scheme,
+			AnalysisChecker: checker,
+		}
+	})
+
+	Context("when the rollout has not started", func() {
+		It("applies the first step without running the analysis", func() {
+			result, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+
+			Expect(checker.calls).To(Equal(0))
+			Expect(destinationWeights()).To(Equal(map[string]int{"stable-guid": 90, "canary-guid": 10}))
+			Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseProgressing))
+			Expect(rollout.Status.CurrentStep).To(Equal(1))
+			Expect(rollout.Status.CanaryWeight).To(Equal(10))
+			Expect(rollout.Status.LastStepTime).NotTo(BeNil())
+		})
+	})
+
+	Context("when the step interval has not elapsed yet", func() {
+		BeforeEach(func() {
+			lastStepTime := metav1.NewTime(time.Now().Add(-1 * time.Minute))
+			rollout.Status = networkingv1alpha1.RouteRolloutStatus{
+				Phase:        networkingv1alpha1.RolloutPhaseProgressing,
+				CurrentStep:  1,
+				CanaryWeight: 10,
+				LastStepTime: &lastStepTime,
+			}
+		})
+
+		It("waits for the rest of the interval", func() {
+			result, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", 4*time.Minute, time.Second))
+			Expect(rollout.Status.CurrentStep).To(Equal(1))
+			Expect(checker.calls).To(Equal(0))
+		})
+	})
+
+	Context("when the step interval has elapsed", func() {
+		BeforeEach(func() {
+			lastStepTime := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+			rollout.Status = networkingv1alpha1.RouteRolloutStatus{
+				Phase:        networkingv1alpha1.RolloutPhaseProgressing,
+				CurrentStep:  1,
+				CanaryWeight: 10,
+				LastStepTime: &lastStepTime,
+			}
+		})
+
+		It("runs the analysis and moves on to the next step", func() {
+			_, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checker.calls).To(Equal(1))
+			Expect(destinationWeights()).To(Equal(map[string]int{"stable-guid": 50, "canary-guid": 50}))
+			Expect(rollout.Status.CurrentStep).To(Equal(2))
+			Expect(rollout.Status.CanaryWeight).To(Equal(50))
+		})
+
+		Context("and the analysis fails", func() {
+			BeforeEach(func() {
+				checker.err = errors.New("error rate too high")
+			})
+
+			It("rolls back to the stable destination", func() {
+				_, err := reconcileAndFetch()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(destinationWeights()).To(Equal(map[string]int{"stable-guid": 100, "canary-guid": 0}))
+				Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseRolledBack))
+				Expect(rollout.Status.Message).To(Equal("analysis failed: error rate too high"))
+				Expect(rollout.Status.CanaryWeight).To(Equal(0))
+			})
+
+			Context("and the failure policy is Pause", func() {
+				BeforeEach(func() {
+					rollout.Spec.Analysis.FailurePolicy = networkingv1alpha1.AnalysisFailurePolicyPause
+				})
+
+				It("holds the current weights", func() {
+					result, err := reconcileAndFetch()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.RequeueAfter).To(BeZero())
+					Expect(rollout.Spec.Paused).To(BeTrue())
+					Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhasePaused))
+					Expect(rollout.Status.CurrentStep).To(Equal(1))
+					Expect(route.Spec.Destinations[0].Weight).To(BeNil())
+				})
+
+				It("stays paused when the analysis passes until the rollout is resumed", func() {
+					_, err := reconcileAndFetch()
+					Expect(err).NotTo(HaveOccurred())
+
+					checker.err = nil
+					_, err = reconcileAndFetch()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(checker.calls).To(Equal(1))
+					Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhasePaused))
+					Expect(rollout.Status.Message).To(Equal("analysis failed: error rate too high"))
+					Expect(rollout.Status.CurrentStep).To(Equal(1))
+					Expect(route.Spec.Destinations[0].Weight).To(BeNil())
+
+					rollout.Spec.Paused = false
+					Expect(k8sClient.Update(ctx, rollout)).To(Succeed())
+					lastStepTime := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+					rollout.Status.LastStepTime = &lastStepTime
+					Expect(k8sClient.Status().Update(ctx, rollout)).To(Succeed())
+
+					_, err = reconcileAndFetch()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(checker.calls).To(Equal(2))
+					Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseProgressing))
+					Expect(rollout.Status.CurrentStep).To(Equal(2))
+					Expect(destinationWeights()).To(Equal(map[string]int{"stable-guid": 50, "canary-guid": 50}))
+				})
+			})
+		})
+	})
+
+	Context("when every step has been applied", func() {
+		BeforeEach(func() {
+			lastStepTime := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+			rollout.Status = networkingv1alpha1.RouteRolloutStatus{
+				Phase:        networkingv1alpha1.RolloutPhaseProgressing,
+				CurrentStep:  3,
+				CanaryWeight: 100,
+				LastStepTime: &lastStepTime,
+			}
+		})
+
+		It("completes the rollout", func() {
+			result, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseCompleted))
+		})
+	})
+
+	Context("when the rollout is paused", func() {
+		BeforeEach(func() {
+			rollout.Spec.Paused = true
+		})
+
+		It("does not touch the route", func() {
+			_, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhasePaused))
+			Expect(route.Spec.Destinations[0].Weight).To(BeNil())
+		})
+	})
+
+	Context("when the steps do not increase", func() {
+		BeforeEach(func() {
+			rollout.Spec.Steps = []int{50, 10}
+		})
+
+		It("fails the rollout", func() {
+			_, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseFailed))
+			Expect(rollout.Status.Message).To(Equal("rollout steps must increase and be between 1 and 100"))
+		})
+	})
+
+	Context("when the analysis url is not an http or https url", func() {
+		BeforeEach(func() {
+			rollout.Spec.Analysis.URL = "file:///etc/passwd"
+		})
+
+		It("fails the rollout", func() {
+			_, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseFailed))
+			Expect(rollout.Status.Message).To(Equal(`rollout analysis url "file:///etc/passwd" must be an http or https url`))
+		})
+	})
+
+	Context("when the route has destinations that are not part of the rollout", func() {
+		BeforeEach(func() {
+			route.Spec.Destinations = append(route.Spec.Destinations, networkingv1alpha1.RouteDestination{Guid: "other-guid"})
+		})
+
+		It("fails the rollout", func() {
+			_, err := reconcileAndFetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rollout.Status.Phase).To(Equal(networkingv1alpha1.RolloutPhaseFailed))
+			Expect(rollout.Status.Message).To(Equal("route route-guid-0 has destination other-guid which is not part of the rollout"))
+		})
+	})
+})
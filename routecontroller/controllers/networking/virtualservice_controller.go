@@ -0,0 +1,429 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/resourcebuilders"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/security/v1beta1"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// VirtualServiceReconciler builds the VirtualService for an FQDN from all of
+// its Routes, the ServiceEntry that makes internal FQDNs resolvable inside
+// the mesh, and the EnvoyFilter and AuthorizationPolicy enforcing the rate
+// limits and source ranges of its Routes on the gateway. Its work items are
+// FQDNs rather than Routes, so events for Routes sharing an FQDN are
+// coalesced and each VirtualService is only ever built by one worker at a
+// time.
+//
+// It lists Routes with the FQDN index registered by the RouteReconciler.
+type VirtualServiceReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Config is read on every reconcile, so reloaded settings apply to the next one
+	Config *cfg.Store
+}
+
+const virtualServiceFQDNKey string = "metadata.annotations.fqdn"
+const fqdnAnnotation string = "cloudfoundry.org/fqdn"
+
+// Reconcile is called with the FQDN as the request name and no namespace
+func (r *VirtualServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	fqdn := req.Name
+	log := r.Log.WithValues("fqdn", fqdn, "reconcile_id", uuid.NewUUID())
+
+	routes := &networkingv1alpha1.RouteList{}
+	if err := r.List(ctx, routes, client.MatchingFields{fqdnFieldKey: fqdn}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	grants, err := listReferenceGrants(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	live := liveRoutes(routesForFQDN(routes.Items, fqdn))
+	ownedRoutes, _ := partitionRoutesByFQDNOwner(grants.filterRoutes(live))
+	ownedRoutes, _ = partitionDuplicateWildcardRoutes(ownedRoutes)
+	ownedRoutes, _ = partitionRoutesByFQDNValidity(ownedRoutes)
+	ownedRoutes, _ = partitionRoutesByPolicyValidity(ownedRoutes)
+	ownedRoutes, _ = partitionRoutesByActiveDestinationSet(ownedRoutes)
+	ownedRoutes, _ = partitionRoutesByWeightValidity(ownedRoutes)
+	ownerNamespace := ""
+	conflicts := []string{}
+	desired := []client.Object{}
+	if len(ownedRoutes) > 0 {
+		ownerNamespace = ownedRoutes[0].ObjectMeta.Namespace
+		desired, err = r.desiredResources(&networkingv1alpha1.RouteList{Items: ownedRoutes})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		conflicts, err = applyAll(ctx, r.Client, log, desired)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.reconcileConflictConditions(live, ownerNamespace, conflicts, log, ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Resources left behind by a previous owner or by Routes that are gone, or
+	// no longer needed, e.g. ServiceEntries once the domain is no longer
+	// internal, EnvoyFilters once no Route is rate limited, or those in the
+	// gateway's previous namespace
+	for _, list := range []client.ObjectList{
+		&istionetworkingv1alpha3.VirtualServiceList{},
+		&istionetworkingv1alpha3.ServiceEntryList{},
+		&istionetworkingv1alpha3.EnvoyFilterList{},
+		&istiosecurityv1beta1.AuthorizationPolicyList{},
+	} {
+		if err := r.deleteStale(fqdn, list, desired, log, ctx); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: r.Config.Get().ResyncInterval}, nil
+}
+
+// desiredResources builds the VirtualService and other resources of an FQDN.
+// The filters shared by every FQDN are applied along with the resources that
+// depend on them and left in place once none do, since they only act on
+// requests of aborted routes or routes with a rate limit.
+func (r *VirtualServiceReconciler) desiredResources(routes *networkingv1alpha1.RouteList) ([]client.Object, error) {
+	config := r.Config.Get()
+	desired := []client.Object{}
+
+	vsb := resourcebuilders.VirtualServiceBuilder{
+		IstioGateways:            []string{config.Istio.Gateway},
+		NoDestinationsBackend:    config.NoDestinations.Backend,
+		NoDestinationsStatusCode: config.NoDestinations.StatusCode,
+		OmitCFIdentityHeaders:    !config.Headers.CFIdentity,
+		Propagation:              metadataPropagation(config),
+	}
+	virtualServices, err := vsb.Build(routes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range virtualServices {
+		desired = append(desired, &virtualServices[i])
+	}
+
+	efb := resourcebuilders.EnvoyFilterBuilder{
+		GatewayNamespace: config.Istio.GatewayWorkload.Namespace,
+		GatewaySelector:  config.Istio.GatewayWorkload.Selector,
+	}
+	if abortsOnGateway(virtualServices) {
+		routerErrorFilter, err := efb.BuildRouterErrorFilter(config.NoDestinations.StatusCode)
+		if err != nil {
+			return nil, err
+		}
+		desired = append(desired, &routerErrorFilter)
+	}
+
+	seb := resourcebuilders.ServiceEntryBuilder{
+		Propagation: metadataPropagation(config),
+	}
+	serviceEntries := seb.Build(routes)
+	for i := range serviceEntries {
+		desired = append(desired, &serviceEntries[i])
+	}
+
+	envoyFilters, err := efb.Build(routes)
+	if err != nil {
+		return nil, err
+	}
+	if len(envoyFilters) > 0 {
+		rateLimitFilter, err := efb.BuildRateLimitFilter()
+		if err != nil {
+			return nil, err
+		}
+		desired = append(desired, &rateLimitFilter)
+	}
+	for i := range envoyFilters {
+		desired = append(desired, &envoyFilters[i])
+	}
+
+	authorizationPolicies, err := authorizationPolicyBuilder(config).Build(routes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range authorizationPolicies {
+		desired = append(desired, &authorizationPolicies[i])
+	}
+
+	return desired, nil
+}
+
+// abortsOnGateway is true when a route without destinations of an external
+// FQDN is aborted by the gateway rather than sent to a backend
+func abortsOnGateway(virtualServices []istionetworkingv1alpha3.VirtualService) bool {
+	for _, virtualService := range virtualServices {
+		if len(virtualService.Spec.Gateways) == 1 && virtualService.Spec.Gateways[0] == resourcebuilders.MeshInternalGateway {
+			continue
+		}
+		for _, httpRoute := range virtualService.Spec.Http {
+			if httpRoute.Fault != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Only the Routes in the owner namespace take part in the VirtualService, so
+// the conflict is reported on them and cleared on all the others
+func (r *VirtualServiceReconciler) reconcileConflictConditions(routes []networkingv1alpha1.Route, ownerNamespace string, conflicts []string, log logr.Logger, ctx context.Context) error {
+	for _, route := range routes {
+		conflicted := route.ObjectMeta.Namespace == ownerNamespace && len(conflicts) > 0
+		message := ""
+		if conflicted {
+			message = strings.Join(conflicts, "; ")
+		}
+		if hasCondition(route.Status, networkingv1alpha1.ConditionVirtualServiceFieldManagerConflict, conflicted, message) {
+			continue
+		}
+
+		// the RouteReconciler writes the Route's status too, so a stale copy is retried
+		key := client.ObjectKeyFromObject(&route)
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			latest := &networkingv1alpha1.Route{}
+			if err := r.Get(ctx, key, latest); err != nil {
+				return err
+			}
+			setConditionMessage(&latest.Status, networkingv1alpha1.ConditionVirtualServiceFieldManagerConflict, conflicted, message)
+			return r.Status().Update(ctx, latest)
+		})
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.Info("Route status has been reconciled", "route", key.String(), "action", "update_status", "result", "updated")
+	}
+	return nil
+}
+
+// deleteStale deletes the resources of the FQDN in the list that are not
+// desired. The resources in the gateway's namespace can't have owner
+// references to the Routes, so they are only ever deleted here.
+func (r *VirtualServiceReconciler) deleteStale(fqdn string, list client.ObjectList, desired []client.Object, log logr.Logger, ctx context.Context) error {
+	if err := r.List(ctx, list, client.MatchingFields{virtualServiceFQDNKey: fqdn}); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	desiredKeys := map[string]bool{}
+	for _, object := range desired {
+		desiredKeys[fmt.Sprintf("%T %s", object, objectKey(object))] = true
+	}
+
+	for _, item := range items {
+		object := item.(client.Object)
+		if object.GetAnnotations()[fqdnAnnotation] != fqdn || desiredKeys[fmt.Sprintf("%T %s", object, objectKey(object))] {
+			continue
+		}
+		gvk, err := apiutil.GVKForObject(object, r.Client.Scheme())
+		if err != nil {
+			return err
+		}
+		if err := r.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.Info(gvk.Kind+" has been deleted", strings.ToLower(gvk.Kind), objectKey(object), "action", "delete", "result", "deleted")
+	}
+
+	return nil
+}
+
+func (r *VirtualServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	indexFQDNAnnotation := func(rawObj client.Object) []string {
+		fqdn, ok := rawObj.GetAnnotations()[fqdnAnnotation]
+		if !ok {
+			return []string{}
+		}
+		return []string{fqdn}
+	}
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &istionetworkingv1alpha3.VirtualService{}, virtualServiceFQDNKey, indexFQDNAnnotation)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &istionetworkingv1alpha3.ServiceEntry{}, virtualServiceFQDNKey, indexFQDNAnnotation)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &istionetworkingv1alpha3.EnvoyFilter{}, virtualServiceFQDNKey, indexFQDNAnnotation)
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &istiosecurityv1beta1.AuthorizationPolicy{}, virtualServiceFQDNKey, indexFQDNAnnotation)
+	if err != nil {
+		return err
+	}
+
+	// There is no object named by the work items, so the controller is wired
+	// up by hand instead of through the builder's For
+	options := controllerOptions(r.Config.Get())
+	options.Reconciler = r
+	c, err := controller.New("virtualservice", mgr, options)
+	if err != nil {
+		return err
+	}
+
+	// Updates map both the old and the new Route, so a changed host rebuilds
+	// the VirtualServices of both FQDNs
+	err = c.Watch(&source.Kind{Type: &networkingv1alpha1.Route{}}, handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		return []reconcile.Request{fqdnRequest(obj.(*networkingv1alpha1.Route).FQDN())}
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &networkingv1alpha1.RouteReferenceGrant{}}, handler.EnqueueRequestsFromMapFunc(r.fqdnRequestsForReferenceGrant))
+	if err != nil {
+		return err
+	}
+
+	// VirtualServices, ServiceEntries, EnvoyFilters and AuthorizationPolicies
+	// that are changed or deleted by hand are rebuilt
+	annotatedFQDNRequests := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+		fqdn, ok := obj.GetAnnotations()[fqdnAnnotation]
+		if !ok {
+			return nil
+		}
+		return []reconcile.Request{fqdnRequest(fqdn)}
+	})
+	err = c.Watch(&source.Kind{Type: &istionetworkingv1alpha3.VirtualService{}}, annotatedFQDNRequests)
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &istionetworkingv1alpha3.ServiceEntry{}}, annotatedFQDNRequests)
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &istionetworkingv1alpha3.EnvoyFilter{}}, annotatedFQDNRequests)
+	if err != nil {
+		return err
+	}
+
+	return c.Watch(&source.Kind{Type: &istiosecurityv1beta1.AuthorizationPolicy{}}, annotatedFQDNRequests)
+}
+
+func (r *VirtualServiceReconciler) fqdnRequestsForReferenceGrant(obj client.Object) []reconcile.Request {
+	seen := map[string]bool{}
+	requests := []reconcile.Request{}
+	for _, route := range routesForReferenceGrant(r.Client, r.Log, obj) {
+		fqdn := route.FQDN()
+		if seen[fqdn] {
+			continue
+		}
+		seen[fqdn] = true
+		requests = append(requests, fqdnRequest(fqdn))
+	}
+	return requests
+}
+
+func fqdnRequest(fqdn string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Name: fqdn}}
+}
+
+// The index is not applied by every client, so the FQDN is checked again
+func routesForFQDN(routes []networkingv1alpha1.Route, fqdn string) []networkingv1alpha1.Route {
+	matching := []networkingv1alpha1.Route{}
+	for _, route := range routes {
+		if route.FQDN() == fqdn {
+			matching = append(matching, route)
+		}
+	}
+	return matching
+}
+
+// A Route with an invalid FQDN, e.g. a host with a wildcard that isn't its
+// first label, would fail the resources of its whole FQDN, so it is left out
+// and the RouteReconciler reports it on the Route instead
+func partitionRoutesByFQDNValidity(routes []networkingv1alpha1.Route) (valid, invalid []networkingv1alpha1.Route) {
+	for _, route := range routes {
+		if route.ValidateFQDN() != nil {
+			invalid = append(invalid, route)
+		} else {
+			valid = append(valid, route)
+		}
+	}
+	return valid, invalid
+}
+
+// A Route with an invalid policy would fail the resources of its whole FQDN,
+// so it is left out and the RouteReconciler reports it on the Route instead
+func partitionRoutesByPolicyValidity(routes []networkingv1alpha1.Route) (valid, invalid []networkingv1alpha1.Route) {
+	for _, route := range routes {
+		if route.ValidatePolicies() != nil {
+			invalid = append(invalid, route)
+		} else {
+			valid = append(valid, route)
+		}
+	}
+	return valid, invalid
+}
+
+// A Route whose granted destinations are all outside of its active
+// destination set would fail the resources of its whole FQDN too, so it is
+// left out like Routes with invalid policies
+func partitionRoutesByActiveDestinationSet(routes []networkingv1alpha1.Route) (valid, invalid []networkingv1alpha1.Route) {
+	for _, route := range routes {
+		if resourcebuilders.ValidateActiveDestinationSet(route) != nil {
+			invalid = append(invalid, route)
+		} else {
+			valid = append(valid, route)
+		}
+	}
+	return valid, invalid
+}
+
+// A Route with invalid weights would fail its FQDN as well, so it is left out too
+func partitionRoutesByWeightValidity(routes []networkingv1alpha1.Route) (valid, invalid []networkingv1alpha1.Route) {
+	for _, route := range routes {
+		if resourcebuilders.ValidateWeights(route) != nil {
+			invalid = append(invalid, route)
+		} else {
+			valid = append(valid, route)
+		}
+	}
+	return valid, invalid
+}
@@ -0,0 +1,547 @@
+package networking_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/security/v1beta1"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/controllers/networking"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/resourcebuilders"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("VirtualServiceReconciler", func() {
+	const fqdn = "test0.domain0.example.com"
+
+	var (
+		ctx        context.Context
+		k8sClient  client.Client
+		conflict   error
+		reconciler *networking.VirtualServiceReconciler
+		objects    []client.Object
+		request    ctrl.Request
+	)
+
+	// The fake client does not apply field selectors, so every Route in these
+	// tests shares the FQDN being reconciled
+	newRoute := func(namespace, name, path string) *networkingv1alpha1.Route {
+		return &networkingv1alpha1.Route{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: networkingv1alpha1.RouteSpec{
+				Host:   "test0",
+				Path:   path,
+				Domain: networkingv1alpha1.RouteDomain{Name: "domain0.example.com"},
+				Destinations: []networkingv1alpha1.RouteDestination{
+					{Guid: name + "-destination", Port: intPtr(8080), App: networkingv1alpha1.DestinationApp{Guid: "app-guid", Process: networkingv1alpha1.AppProcess{Type: "web"}}},
+				},
+			},
+		}
+	}
+
+	listVirtualServices := func() []istionetworkingv1alpha3.VirtualService {
+		virtualServices := &istionetworkingv1alpha3.VirtualServiceList{}
+		Expect(k8sClient.List(ctx, virtualServices)).To(Succeed())
+		return virtualServices.Items
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objects = []client.Object{}
+		conflict = nil
+		request = ctrl.Request{NamespacedName: types.NamespacedName{Name: fqdn}}
+	})
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(networkingv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(istionetworkingv1alpha3.AddToScheme(scheme)).To(Succeed())
+		Expect(istiosecurityv1beta1.AddToScheme(scheme)).To(Succeed())
+
+		k8sClient = &fakeApplyClient{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+			conflict: conflict,
+		}
+		config := &cfg.Config{ResyncInterval: 30 * time.Second}
+		config.Istio.Gateway = "some-gateway"
+		config.Istio.GatewayWorkload.Namespace = "istio-system"
+		config.Istio.GatewayWorkload.Selector = map[string]string{"istio": "ingressgateway"}
+		config.NoDestinations.StatusCode = 503
+		config.Headers.CFIdentity = true
+
+		reconciler = &networking.VirtualServiceReconciler{
+			Client: k8sClient,
+			Log:    log.Log,
+			Scheme: scheme,
+			Config: cfg.NewStore(config),
+		}
+	})
+
+	Context("when several Routes share the FQDN", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newRoute("workload-namespace", "route-guid-0", ""),
+				newRoute("workload-namespace", "route-guid-1", "/api"),
+			)
+		})
+
+		It("builds a single VirtualService for all of them", func() {
+			result, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].ObjectMeta.Namespace).To(Equal("workload-namespace"))
+			Expect(virtualServices[0].ObjectMeta.Name).To(Equal(resourcebuilders.VirtualServiceName(fqdn)))
+			Expect(virtualServices[0].Spec.Hosts).To(ConsistOf(fqdn))
+			Expect(virtualServices[0].Spec.Http).To(HaveLen(2))
+		})
+	})
+
+	Context("when the VirtualService already has the desired state", func() {
+		BeforeEach(func() {
+			objects = append(objects, newRoute("workload-namespace", "route-guid-0", ""))
+		})
+
+		It("does not write it again", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].ObjectMeta.Annotations).To(HaveKey(resourcebuilders.DesiredStateHashAnnotation))
+			resourceVersion := virtualServices[0].ObjectMeta.ResourceVersion
+
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listVirtualServices()[0].ObjectMeta.ResourceVersion).To(Equal(resourceVersion))
+		})
+
+		It("undoes changes made to the VirtualService by hand", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			desiredGateways := virtualServices[0].Spec.Gateways
+
+			// the desired state hash is kept, only the spec drifts
+			virtualServices[0].Spec.Gateways = []string{"hand-edited-gateway"}
+			Expect(k8sClient.Update(ctx, &virtualServices[0])).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listVirtualServices()[0].Spec.Gateways).To(Equal(desiredGateways))
+		})
+	})
+
+	Context("when a Route of the FQDN has an invalid policy", func() {
+		BeforeEach(func() {
+			invalid := newRoute("workload-namespace", "route-guid-1", "/api")
+			invalid.Spec.Cors = &networkingv1alpha1.RouteCorsPolicy{AllowOrigins: []string{"https://app.*.example.com"}}
+			objects = append(objects, newRoute("workload-namespace", "route-guid-0", ""), invalid)
+		})
+
+		It("leaves it out and still builds the VirtualService for the other Routes", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http[0].Match).To(BeEmpty())
+		})
+	})
+
+	Context("when a Route of the FQDN has an invalid FQDN", func() {
+		const wildcardFQDN = "*.test0.domain0.example.com"
+
+		BeforeEach(func() {
+			request = ctrl.Request{NamespacedName: types.NamespacedName{Name: wildcardFQDN}}
+
+			valid := newRoute("workload-namespace", "route-guid-0", "")
+			valid.Spec.Host = "*"
+			valid.Spec.Domain.Name = "test0.domain0.example.com"
+			// shares the wildcard FQDN, but Istio only supports a wildcard
+			// as the first label of a host
+			invalid := newRoute("workload-namespace", "route-guid-1", "/api")
+			invalid.Spec.Host = "*.test0"
+			objects = append(objects, valid, invalid)
+		})
+
+		It("leaves it out and still builds the VirtualService for the other Routes", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Hosts).To(ConsistOf(wildcardFQDN))
+			Expect(virtualServices[0].Spec.Http).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http[0].Match).To(BeEmpty())
+		})
+	})
+
+	Context("when none of a Route's destinations are in its active destination set", func() {
+		BeforeEach(func() {
+			inactive := newRoute("workload-namespace", "route-guid-1", "/api")
+			inactive.Spec.ActiveDestinationSet = "green"
+			inactive.Spec.Destinations[0].Set = "blue"
+			objects = append(objects, newRoute("workload-namespace", "route-guid-0", ""), inactive)
+		})
+
+		It("leaves it out and still builds the VirtualService for the other Routes", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http[0].Match).To(BeEmpty())
+		})
+	})
+
+	Context("when a Route's destinations have invalid weights", func() {
+		BeforeEach(func() {
+			invalid := newRoute("workload-namespace", "route-guid-1", "/api")
+			invalid.Spec.Destinations[0].Weight = intPtr(0)
+			objects = append(objects, newRoute("workload-namespace", "route-guid-0", ""), invalid)
+		})
+
+		It("leaves it out and still builds the VirtualService for the other Routes", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Http[0].Match).To(BeEmpty())
+		})
+	})
+
+	Context("when fields of the VirtualService are managed by another field manager", func() {
+		BeforeEach(func() {
+			owner := newRoute("workload-namespace", "route-guid-0", "")
+			owner.ObjectMeta.Annotations = map[string]string{"networking.cloudfoundry.org/fqdn-claim": "true"}
+			objects = append(objects, owner, newRoute("other-namespace", "route-guid-1", ""))
+			conflict = apierrors.NewConflict(
+				schema.GroupResource{Group: "networking.istio.io", Resource: "virtualservices"},
+				resourcebuilders.VirtualServiceName(fqdn),
+				errors.New(`Apply failed with 1 conflict: conflict with "kapp": .spec.gateways`),
+			)
+		})
+
+		routeCondition := func(namespace, name string) networkingv1alpha1.Condition {
+			route := &networkingv1alpha1.Route{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, route)).To(Succeed())
+			for _, condition := range route.Status.Conditions {
+				if condition.Type == networkingv1alpha1.ConditionVirtualServiceFieldManagerConflict {
+					return condition
+				}
+			}
+			return networkingv1alpha1.Condition{}
+		}
+
+		It("reports the conflict on the Routes that own the FQDN", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(listVirtualServices()).To(BeEmpty())
+
+			condition := routeCondition("workload-namespace", "route-guid-0")
+			Expect(condition.Status).To(BeTrue())
+			Expect(condition.Message).To(ContainSubstring(`conflict with "kapp"`))
+
+			Expect(routeCondition("other-namespace", "route-guid-1").Status).To(BeFalse())
+		})
+	})
+
+	Context("when the VirtualService was written with updates before server-side apply", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newRoute("workload-namespace", "route-guid-0", ""),
+				&istionetworkingv1alpha3.VirtualService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        resourcebuilders.VirtualServiceName(fqdn),
+						Namespace:   "workload-namespace",
+						Annotations: map[string]string{"cloudfoundry.org/fqdn": fqdn},
+						ManagedFields: []metav1.ManagedFieldsEntry{
+							{Manager: "routecontroller", Operation: metav1.ManagedFieldsOperationUpdate, APIVersion: "networking.istio.io/v1alpha3"},
+						},
+					},
+				},
+			)
+		})
+
+		It("takes over the fields the route controller updated and applies the VirtualService", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].Spec.Hosts).To(ConsistOf(fqdn))
+			Expect(virtualServices[0].ObjectMeta.ManagedFields).To(ConsistOf(
+				metav1.ManagedFieldsEntry{Manager: "routecontroller", Operation: metav1.ManagedFieldsOperationApply, APIVersion: "networking.istio.io/v1alpha3"},
+			))
+
+			route := &networkingv1alpha1.Route{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "workload-namespace", Name: "route-guid-0"}, route)).To(Succeed())
+			for _, condition := range route.Status.Conditions {
+				if condition.Type == networkingv1alpha1.ConditionVirtualServiceFieldManagerConflict {
+					Expect(condition.Status).To(BeFalse())
+				}
+			}
+		})
+	})
+
+	Context("when a VirtualService for the FQDN was left in another namespace", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newRoute("workload-namespace", "route-guid-0", ""),
+				&istionetworkingv1alpha3.VirtualService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        resourcebuilders.VirtualServiceName(fqdn),
+						Namespace:   "previous-namespace",
+						Annotations: map[string]string{"cloudfoundry.org/fqdn": fqdn},
+					},
+				},
+			)
+		})
+
+		It("deletes it", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			virtualServices := listVirtualServices()
+			Expect(virtualServices).To(HaveLen(1))
+			Expect(virtualServices[0].ObjectMeta.Namespace).To(Equal("workload-namespace"))
+		})
+	})
+
+	Context("when the last Route for the FQDN is being deleted", func() {
+		BeforeEach(func() {
+			route := newRoute("workload-namespace", "route-guid-0", "")
+			now := metav1.Now()
+			route.ObjectMeta.DeletionTimestamp = &now
+			route.ObjectMeta.Finalizers = []string{"routes.networking.cloudfoundry.org"}
+
+			objects = append(objects,
+				route,
+				&istionetworkingv1alpha3.VirtualService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        resourcebuilders.VirtualServiceName(fqdn),
+						Namespace:   "workload-namespace",
+						Annotations: map[string]string{"cloudfoundry.org/fqdn": fqdn},
+					},
+				},
+			)
+		})
+
+		It("deletes the VirtualService", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(listVirtualServices()).To(BeEmpty())
+		})
+	})
+
+	Context("when the Routes' domain is internal", func() {
+		BeforeEach(func() {
+			route := newRoute("workload-namespace", "route-guid-0", "")
+			route.Spec.Domain.Internal = true
+			objects = append(objects, route)
+		})
+
+		It("builds a ServiceEntry that makes the FQDN resolvable in the mesh", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceEntries := &istionetworkingv1alpha3.ServiceEntryList{}
+			Expect(k8sClient.List(ctx, serviceEntries)).To(Succeed())
+			Expect(serviceEntries.Items).To(HaveLen(1))
+			Expect(serviceEntries.Items[0].ObjectMeta.Namespace).To(Equal("workload-namespace"))
+			Expect(serviceEntries.Items[0].ObjectMeta.Name).To(Equal(resourcebuilders.ServiceEntryName(fqdn)))
+			Expect(serviceEntries.Items[0].Spec.Hosts).To(ConsistOf(fqdn))
+		})
+	})
+
+	Context("when a ServiceEntry is left for an FQDN that is no longer internal", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newRoute("workload-namespace", "route-guid-0", ""),
+				&istionetworkingv1alpha3.ServiceEntry{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        resourcebuilders.ServiceEntryName(fqdn),
+						Namespace:   "workload-namespace",
+						Annotations: map[string]string{"cloudfoundry.org/fqdn": fqdn},
+					},
+				},
+			)
+		})
+
+		It("deletes it", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceEntries := &istionetworkingv1alpha3.ServiceEntryList{}
+			Expect(k8sClient.List(ctx, serviceEntries)).To(Succeed())
+			Expect(serviceEntries.Items).To(BeEmpty())
+		})
+	})
+
+	Context("when a Route has a rate limit", func() {
+		BeforeEach(func() {
+			route := newRoute("workload-namespace", "route-guid-0", "")
+			route.Spec.RateLimit = &networkingv1alpha1.RouteRateLimit{RequestsPerSecond: 10}
+			objects = append(objects, route)
+		})
+
+		It("builds an EnvoyFilter in the gateway's namespace, along with the filter it depends on", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			envoyFilters := &istionetworkingv1alpha3.EnvoyFilterList{}
+			Expect(k8sClient.List(ctx, envoyFilters, client.InNamespace("istio-system"))).To(Succeed())
+			names := []string{}
+			for _, envoyFilter := range envoyFilters.Items {
+				names = append(names, envoyFilter.ObjectMeta.Name)
+			}
+			Expect(names).To(ConsistOf(resourcebuilders.EnvoyFilterName(fqdn), resourcebuilders.RateLimitFilterEnvoyFilterName))
+
+			Expect(listVirtualServices()[0].Spec.Http[0].Name).To(Equal("route-guid-0"))
+		})
+	})
+
+	Context("when a Route without destinations is aborted by the gateway", func() {
+		BeforeEach(func() {
+			route := newRoute("workload-namespace", "route-guid-0", "")
+			route.Spec.Destinations = nil
+			objects = append(objects, route)
+		})
+
+		It("builds the EnvoyFilter adding the router error header to the aborted requests", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			envoyFilter := &istionetworkingv1alpha3.EnvoyFilter{}
+			key := types.NamespacedName{Namespace: "istio-system", Name: resourcebuilders.RouterErrorEnvoyFilterName}
+			Expect(k8sClient.Get(ctx, key, envoyFilter)).To(Succeed())
+			Expect(listVirtualServices()[0].Spec.Http[0].Fault).NotTo(BeNil())
+		})
+	})
+
+	Context("when an EnvoyFilter is left for an FQDN that is no longer rate limited", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newRoute("workload-namespace", "route-guid-0", ""),
+				&istionetworkingv1alpha3.EnvoyFilter{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        resourcebuilders.EnvoyFilterName(fqdn),
+						Namespace:   "istio-system",
+						Annotations: map[string]string{"cloudfoundry.org/fqdn": fqdn},
+					},
+				},
+				&istionetworkingv1alpha3.EnvoyFilter{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      resourcebuilders.RateLimitFilterEnvoyFilterName,
+						Namespace: "istio-system",
+					},
+				},
+			)
+		})
+
+		It("deletes it and leaves the shared filter alone", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			envoyFilters := &istionetworkingv1alpha3.EnvoyFilterList{}
+			Expect(k8sClient.List(ctx, envoyFilters)).To(Succeed())
+			Expect(envoyFilters.Items).To(HaveLen(1))
+			Expect(envoyFilters.Items[0].ObjectMeta.Name).To(Equal(resourcebuilders.RateLimitFilterEnvoyFilterName))
+		})
+	})
+
+	Context("when a Route has source ranges", func() {
+		BeforeEach(func() {
+			route := newRoute("workload-namespace", "route-guid-0", "")
+			route.Spec.AllowedSourceRanges = []string{"10.0.0.0/8"}
+			objects = append(objects, route)
+		})
+
+		It("builds an AuthorizationPolicy in the gateway's namespace", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			authorizationPolicies := &istiosecurityv1beta1.AuthorizationPolicyList{}
+			Expect(k8sClient.List(ctx, authorizationPolicies)).To(Succeed())
+			Expect(authorizationPolicies.Items).To(HaveLen(1))
+			Expect(authorizationPolicies.Items[0].ObjectMeta.Namespace).To(Equal("istio-system"))
+			Expect(authorizationPolicies.Items[0].ObjectMeta.Name).To(Equal(resourcebuilders.AuthorizationPolicyName(fqdn)))
+			Expect(authorizationPolicies.Items[0].Spec.Rules).To(HaveLen(1))
+		})
+	})
+
+	Context("when an AuthorizationPolicy is left for an FQDN whose Routes no longer have source ranges", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newRoute("workload-namespace", "route-guid-0", ""),
+				&istiosecurityv1beta1.AuthorizationPolicy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        resourcebuilders.AuthorizationPolicyName(fqdn),
+						Namespace:   "istio-system",
+						Annotations: map[string]string{"cloudfoundry.org/fqdn": fqdn},
+					},
+				},
+			)
+		})
+
+		It("deletes it", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			authorizationPolicies := &istiosecurityv1beta1.AuthorizationPolicyList{}
+			Expect(k8sClient.List(ctx, authorizationPolicies)).To(Succeed())
+			Expect(authorizationPolicies.Items).To(BeEmpty())
+		})
+	})
+
+	Context("when a VirtualService belongs to another FQDN", func() {
+		BeforeEach(func() {
+			objects = append(objects, &istionetworkingv1alpha3.VirtualService{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        resourcebuilders.VirtualServiceName("other.example.com"),
+					Namespace:   "workload-namespace",
+					Annotations: map[string]string{"cloudfoundry.org/fqdn": "other.example.com"},
+				},
+			})
+		})
+
+		It("leaves it alone", func() {
+			_, err := reconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(listVirtualServices()).To(HaveLen(1))
+		})
+	})
+})
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -3,17 +3,23 @@ module code.cloudfoundry.org/cf-k8s-networking/routecontroller
 go 1.15
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-logr/logr v0.3.0
 	github.com/gogo/protobuf v1.3.1
+	github.com/google/gofuzz v1.1.0
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
 	github.com/prometheus/client_model v0.2.0
 	github.com/prometheus/prom2json v1.3.0
 	github.com/sirupsen/logrus v1.6.0
+	go.uber.org/zap v1.15.0
+	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
 	istio.io/api v0.0.0-20200410141105-715a3039a0b5
 	k8s.io/api v0.20.4
 	k8s.io/apimachinery v0.20.4
 	k8s.io/client-go v0.20.4
 	sigs.k8s.io/controller-runtime v0.8.3
 	sigs.k8s.io/kind v0.10.0
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// How long a probe waits for the informer caches before reporting them unsynced
+const cacheSyncTimeout = time.Second
+
+// CacheSyncer is implemented by the manager's cache
+type CacheSyncer interface {
+	WaitForCacheSync(ctx context.Context) bool
+}
+
+// CacheSynced fails until every informer cache has been synced
+func CacheSynced(cache CacheSyncer) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), cacheSyncTimeout)
+		defer cancel()
+
+		if !cache.WaitForCacheSync(ctx) {
+			return errors.New("informer caches are not synced")
+		}
+		return nil
+	}
+}
+
+// CRDPresent fails while the API server does not serve the given kind, e.g.
+// before Istio's VirtualService CRD has been installed
+func CRDPresent(mapper meta.RESTMapper, gvk schema.GroupVersionKind) healthz.Checker {
+	return func(req *http.Request) error {
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			return fmt.Errorf("%s is not installed: %s", gvk.Kind, err)
+		}
+		return nil
+	}
+}
+
+// Leader fails until this replica has been elected leader. Only the leader
+// reconciles, so this tells replicas apart rather than whether they work.
+func Leader(elected <-chan struct{}) healthz.Checker {
+	return func(req *http.Request) error {
+		select {
+		case <-elected:
+			return nil
+		default:
+			return errors.New("this replica is not the leader")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package health_test
+
+import (
+	"context"
+	"net/http"
+
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/health"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type fakeCache struct {
+	synced bool
+}
+
+func (c *fakeCache) WaitForCacheSync(ctx context.Context) bool {
+	return c.synced
+}
+
+var _ = Describe("Checks", func() {
+	var req *http.Request
+
+	BeforeEach(func() {
+		var err error
+		req, err = http.NewRequest(http.MethodGet, "/readyz", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("CacheSynced", func() {
+		It("passes once the caches are synced", func() {
+			Expect(health.CacheSynced(&fakeCache{synced: true})(req)).To(Succeed())
+		})
+
+		It("fails while the caches are not synced", func() {
+			err := health.CacheSynced(&fakeCache{synced: false})(req)
+			Expect(err).To(MatchError("informer caches are not synced"))
+		})
+	})
+
+	Describe("CRDPresent", func() {
+		var (
+			mapper *meta.DefaultRESTMapper
+			gvk    schema.GroupVersionKind
+		)
+
+		BeforeEach(func() {
+			gvk = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"}
+			mapper = meta.NewDefaultRESTMapper([]schema.GroupVersion{gvk.GroupVersion()})
+		})
+
+		It("passes when the kind is served", func() {
+			mapper.Add(gvk, meta.RESTScopeNamespace)
+			Expect(health.CRDPresent(mapper, gvk)(req)).To(Succeed())
+		})
+
+		It("fails when the kind is not served", func() {
+			err := health.CRDPresent(mapper, gvk)(req)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("VirtualService is not installed"))
+		})
+	})
+
+	Describe("Leader", func() {
+		It("passes once elected", func() {
+			elected := make(chan struct{})
+			close(elected)
+			Expect(health.Leader(elected)(req)).To(Succeed())
+		})
+
+		It("fails until elected", func() {
+			elected := make(chan struct{})
+			Expect(health.Leader(elected)(req)).To(MatchError("this replica is not the leader"))
+		})
+	})
+})
@@ -99,6 +99,18 @@ var _ = Describe("Integration", func() {
 		output, err = kubectlWithConfig(kubeConfigPath, nil, "-n", namespace, "apply", "-f", istioCRDPath)
 		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("kubectl apply crd failed with err: %s", string(output)))
 
+		serviceEntryCRDPath := filepath.Join("fixtures", "istio-service-entry.yaml")
+		output, err = kubectlWithConfig(kubeConfigPath, nil, "-n", namespace, "apply", "-f", serviceEntryCRDPath)
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("kubectl apply crd failed with err: %s", string(output)))
+
+		envoyFilterCRDPath := filepath.Join("fixtures", "istio-envoy-filter.yaml")
+		output, err = kubectlWithConfig(kubeConfigPath, nil, "-n", namespace, "apply", "-f", envoyFilterCRDPath)
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("kubectl apply crd failed with err: %s", string(output)))
+
+		authorizationPolicyCRDPath := filepath.Join("fixtures", "istio-authorization-policy.yaml")
+		output, err = kubectlWithConfig(kubeConfigPath, nil, "-n", namespace, "apply", "-f", authorizationPolicyCRDPath)
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("kubectl apply crd failed with err: %s", string(output)))
+
 		// Generate the YAML for the Route CRD with Kustomize, and then apply it with kubectl apply.
 		kustomizeOutput, err := kustomizeConfigCRD()
 		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("kustomize failed to render CRD yaml: %s", string(kustomizeOutput)))
@@ -17,19 +17,25 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	networkingv1beta1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1beta1"
 	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/cfg"
 	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/controllers/networking"
+	"code.cloudfoundry.org/cf-k8s-networking/routecontroller/health"
 
 	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/security/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -41,35 +47,57 @@ var (
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 	_ = networkingv1alpha1.AddToScheme(scheme)
+	_ = networkingv1beta1.AddToScheme(scheme)
 	_ = istionetworkingv1alpha3.AddToScheme(scheme)
+	_ = istiosecurityv1beta1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
+	var configFile string
 	var enableLeaderElection bool
+	var enableConversionWebhook bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":8081", "The address the /healthz and /readyz endpoints bind to.")
+	flag.StringVar(&configFile, "config-file", "", "Path to the YAML configuration file, which is reloaded when it changes. Env variables override its settings.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableConversionWebhook, "enable-conversion-webhook", false,
+		"Serve the webhook converting Routes between versions. It needs a serving certificate in /tmp/k8s-webhook-server/serving-certs.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(func(o *zap.Options) {
-		o.Development = true
-	}))
+	// the logger is configured by the config, so errors loading it go to stderr
+	config, err := cfg.LoadFile(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load config: %s\n", err)
+		os.Exit(1)
+	}
 
-	config, err := cfg.Load()
+	setLogLevel, err := cfg.ConfigureLogging(&opts, flag.CommandLine, config.Logging)
 	if err != nil {
-		setupLog.Error(err, "unable to load config")
+		fmt.Fprintf(os.Stderr, "unable to configure logging: %s\n", err)
 		os.Exit(1)
 	}
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "cf-k8s-networking-routecontroller",
+	configStore := cfg.NewStore(config)
+
+	restConfig := ctrl.GetConfigOrDie()
+	restConfig.QPS = config.KubernetesClient.QPS
+	restConfig.Burst = config.KubernetesClient.Burst
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
+		Scheme:                  scheme,
+		MetricsBindAddress:      metricsAddr,
+		HealthProbeBindAddress:  probeAddr,
+		LeaderElection:          enableLeaderElection,
+		LeaderElectionID:        "cf-k8s-networking-routecontroller",
 		LeaderElectionNamespace: config.LeaderElectionNamespace,
-		Port:               9443,
+		Port:                    9443,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -77,17 +105,77 @@ func main() {
 	}
 
 	if err = (&networking.RouteReconciler{
-		Client:         mgr.GetClient(),
-		Log:            ctrl.Log.WithName("controllers").WithName("Route"),
-		Scheme:         mgr.GetScheme(),
-		IstioGateway:   config.Istio.Gateway,
-		ResyncInterval: config.ResyncInterval,
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("Route"),
+		Scheme: mgr.GetScheme(),
+		Config: configStore,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Route")
 		os.Exit(1)
 	}
+	if err = (&networking.VirtualServiceReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("VirtualService"),
+		Scheme: mgr.GetScheme(),
+		Config: configStore,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VirtualService")
+		os.Exit(1)
+	}
+	if err = (&networking.RouteRolloutReconciler{
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("controllers").WithName("RouteRollout"),
+		Scheme:          mgr.GetScheme(),
+		AnalysisChecker: networking.NewHTTPAnalysisChecker(configStore, 10*time.Second),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RouteRollout")
+		os.Exit(1)
+	}
+	if enableConversionWebhook {
+		if err = ctrl.NewWebhookManagedBy(mgr).For(&networkingv1alpha1.Route{}).Complete(); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Route")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	if configFile != "" {
+		if err := mgr.Add(&cfg.Watcher{
+			Path:  configFile,
+			Store: configStore,
+			Log:   ctrl.Log.WithName("config"),
+			OnReload: func(reloaded *cfg.Config) {
+				// the level has been validated when the config was loaded
+				_ = setLogLevel(reloaded.Logging.Level)
+			},
+		}); err != nil {
+			setupLog.Error(err, "unable to watch config file")
+			os.Exit(1)
+		}
+	}
+
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("informers", health.CacheSynced(mgr.GetCache())); err != nil {
+		setupLog.Error(err, "unable to set up ready check", "check", "informers")
+		os.Exit(1)
+	}
+	virtualServiceGVK := istionetworkingv1alpha3.GroupVersion.WithKind("VirtualService")
+	if err := mgr.AddReadyzCheck("virtualservice-crd", health.CRDPresent(mgr.GetRESTMapper(), virtualServiceGVK)); err != nil {
+		setupLog.Error(err, "unable to set up ready check", "check", "virtualservice-crd")
+		os.Exit(1)
+	}
+	// The deployment's readiness probe excludes this check, otherwise a new replica
+	// could never become ready while the old one holds the lease
+	if enableLeaderElection {
+		if err := mgr.AddReadyzCheck("leader", health.Leader(mgr.Elected())); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", "leader")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
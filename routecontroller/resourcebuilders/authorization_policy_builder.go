@@ -0,0 +1,128 @@
+package resourcebuilders
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	istiosecurityv1beta1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/security/v1beta1"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	istiosecurity "istio.io/api/security/v1beta1"
+	istiotype "istio.io/api/type/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuthorizationPolicyBuilder builds an AuthorizationPolicy for each external
+// FQDN with Routes restricting their source ranges.
+//
+// The gateway is shared by every FQDN, and once an ALLOW policy applies to a
+// workload every request it doesn't match is denied. The allowed ranges are
+// therefore enforced by denying requests from outside of them, so the
+// policies only ever deny requests of their own FQDN.
+type AuthorizationPolicyBuilder struct {
+	// AuthorizationPolicies only apply to workloads in their own namespace,
+	// so they are created in the gateway's namespace rather than the Routes'
+	GatewayNamespace string
+	// Labels selecting the gateway's pods
+	GatewaySelector map[string]string
+}
+
+// authorization policy names cannot contain special characters
+func AuthorizationPolicyName(fqdn string) string {
+	sum := sha256.Sum256([]byte(fqdn))
+	return fmt.Sprintf("ap-%x", sum)
+}
+
+// Build returns AuthorizationPolicies for the FQDNs of external routes with
+// source ranges only
+func (b *AuthorizationPolicyBuilder) Build(routes *networkingv1alpha1.RouteList) ([]istiosecurityv1beta1.AuthorizationPolicy, error) {
+	authorizationPolicies := []istiosecurityv1beta1.AuthorizationPolicy{}
+	err := forEachFQDN(routes, func(fqdn string, routes []networkingv1alpha1.Route) error {
+		authorizationPolicy, ok, err := b.fqdnToAuthorizationPolicy(fqdn, routes)
+		if ok {
+			authorizationPolicies = append(authorizationPolicies, authorizationPolicy)
+		}
+		return err
+	})
+	if err != nil {
+		return []istiosecurityv1beta1.AuthorizationPolicy{}, err
+	}
+	return authorizationPolicies, nil
+}
+
+func (b *AuthorizationPolicyBuilder) fqdnToAuthorizationPolicy(fqdn string, routes []networkingv1alpha1.Route) (istiosecurityv1beta1.AuthorizationPolicy, bool, error) {
+	authorizationPolicy := istiosecurityv1beta1.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AuthorizationPolicyName(fqdn),
+			Namespace: b.GatewayNamespace,
+			Annotations: map[string]string{
+				"cloudfoundry.org/fqdn": fqdn,
+			},
+		},
+		Spec: istiosecurityv1beta1.AuthorizationPolicySpec{
+			AuthorizationPolicy: istiosecurity.AuthorizationPolicy{
+				Selector: &istiotype.WorkloadSelector{MatchLabels: cloneLabels(b.GatewaySelector)},
+				Action:   istiosecurity.AuthorizationPolicy_DENY,
+			},
+		},
+	}
+
+	sortRoutes(routes)
+
+	// routes without destinations get no http route when they share the
+	// FQDN, so their requests are served by the route of a shorter path
+	served := []networkingv1alpha1.Route{}
+	for _, route := range routes {
+		if len(route.Spec.Destinations) != 0 || len(routes) == 1 {
+			served = append(served, route)
+		}
+	}
+
+	for _, route := range served {
+		if !route.RestrictsSourceRanges() {
+			continue
+		}
+		if err := route.ValidateSourceRanges(); err != nil {
+			return istiosecurityv1beta1.AuthorizationPolicy{}, false, err
+		}
+
+		to := []*istiosecurity.Rule_To{{Operation: routeOperation(fqdn, route, served)}}
+		if len(route.Spec.DeniedSourceRanges) > 0 {
+			authorizationPolicy.Spec.Rules = append(authorizationPolicy.Spec.Rules, &istiosecurity.Rule{
+				From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{IpBlocks: route.Spec.DeniedSourceRanges}}},
+				To:   to,
+			})
+		}
+		if len(route.Spec.AllowedSourceRanges) > 0 {
+			authorizationPolicy.Spec.Rules = append(authorizationPolicy.Spec.Rules, &istiosecurity.Rule{
+				From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{NotIpBlocks: route.Spec.AllowedSourceRanges}}},
+				To:   to,
+			})
+		}
+	}
+
+	return authorizationPolicy, len(authorizationPolicy.Spec.Rules) > 0, nil
+}
+
+// routeOperation matches the requests the VirtualService routes to the route:
+// those for the FQDN with the route's path as a prefix, but not the path of a
+// more specific route of the FQDN
+func routeOperation(fqdn string, route networkingv1alpha1.Route, routes []networkingv1alpha1.Route) *istiosecurity.Operation {
+	operation := &istiosecurity.Operation{Hosts: []string{fqdn}}
+	// the host header may carry a port, wildcard hosts can't also match one
+	if !route.IsWildcard() {
+		operation.Hosts = append(operation.Hosts, fqdn+":*")
+	}
+
+	path := route.CanonicalPath()
+	if path != "" {
+		operation.Paths = []string{path + "*"}
+	}
+	for _, other := range routes {
+		otherPath := other.CanonicalPath()
+		if otherPath != path && strings.HasPrefix(otherPath, path) {
+			operation.NotPaths = append(operation.NotPaths, otherPath+"*")
+		}
+	}
+	return operation
+}
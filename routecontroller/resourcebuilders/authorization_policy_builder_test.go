@@ -0,0 +1,148 @@
+package resourcebuilders
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	istiosecurity "istio.io/api/security/v1beta1"
+)
+
+var _ = Describe("AuthorizationPolicyBuilder", func() {
+	var (
+		routes  networkingv1alpha1.RouteList
+		builder AuthorizationPolicyBuilder
+	)
+
+	BeforeEach(func() {
+		builder = AuthorizationPolicyBuilder{
+			GatewayNamespace: "istio-system",
+			GatewaySelector:  map[string]string{"istio": "ingressgateway"},
+		}
+
+		routes = networkingv1alpha1.RouteList{
+			Items: []networkingv1alpha1.Route{
+				constructRoute(routeParams{
+					name:   "route-guid-0",
+					host:   "test0",
+					path:   "/admin",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+					},
+				}),
+				constructRoute(routeParams{
+					name:   "route-guid-1",
+					host:   "test0",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-1-destination-guid-0", port: 8080, appGUID: "app-guid-1"},
+					},
+				}),
+				constructRoute(routeParams{
+					name:   "route-guid-2",
+					host:   "test1",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-2-destination-guid-0", port: 8080, appGUID: "app-guid-2"},
+					},
+				}),
+			},
+		}
+		routes.Items[0].Spec.AllowedSourceRanges = []string{"10.0.0.0/8"}
+		routes.Items[0].Spec.DeniedSourceRanges = []string{"10.1.0.0/16", "10.2.0.1"}
+	})
+
+	It("builds a DENY AuthorizationPolicy on the gateway for each FQDN with source ranges only", func() {
+		authorizationPolicies, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(authorizationPolicies).To(HaveLen(1))
+		authorizationPolicy := authorizationPolicies[0]
+		Expect(authorizationPolicy.ObjectMeta.Name).To(Equal(AuthorizationPolicyName("test0.domain0.example.com")))
+		Expect(authorizationPolicy.ObjectMeta.Namespace).To(Equal("istio-system"))
+		Expect(authorizationPolicy.ObjectMeta.Annotations).To(HaveKeyWithValue("cloudfoundry.org/fqdn", "test0.domain0.example.com"))
+		Expect(authorizationPolicy.ObjectMeta.OwnerReferences).To(BeEmpty())
+		Expect(authorizationPolicy.Spec.Selector.MatchLabels).To(Equal(map[string]string{"istio": "ingressgateway"}))
+		Expect(authorizationPolicy.Spec.Action).To(Equal(istiosecurity.AuthorizationPolicy_DENY))
+	})
+
+	It("denies the denied ranges and everything outside the allowed ranges for the route's host and path", func() {
+		authorizationPolicies, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+
+		operation := &istiosecurity.Operation{
+			Hosts: []string{"test0.domain0.example.com", "test0.domain0.example.com:*"},
+			Paths: []string{"/admin*"},
+		}
+		Expect(authorizationPolicies[0].Spec.Rules).To(Equal([]*istiosecurity.Rule{
+			{
+				From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{IpBlocks: []string{"10.1.0.0/16", "10.2.0.1"}}}},
+				To:   []*istiosecurity.Rule_To{{Operation: operation}},
+			},
+			{
+				From: []*istiosecurity.Rule_From{{Source: &istiosecurity.Source{NotIpBlocks: []string{"10.0.0.0/8"}}}},
+				To:   []*istiosecurity.Rule_To{{Operation: operation}},
+			},
+		}))
+	})
+
+	It("leaves out the paths of more specific routes of the FQDN", func() {
+		routes.Items[0].Spec.AllowedSourceRanges = nil
+		routes.Items[0].Spec.DeniedSourceRanges = nil
+		routes.Items[1].Spec.AllowedSourceRanges = []string{"10.0.0.0/8"}
+
+		authorizationPolicies, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+
+		rules := authorizationPolicies[0].Spec.Rules
+		Expect(rules).To(HaveLen(1))
+		Expect(rules[0].To[0].Operation.Paths).To(BeEmpty())
+		Expect(rules[0].To[0].Operation.NotPaths).To(Equal([]string{"/admin*"}))
+	})
+
+	It("keeps the paths of more specific routes without destinations, as the route serves them", func() {
+		routes.Items[0].Spec.AllowedSourceRanges = nil
+		routes.Items[0].Spec.DeniedSourceRanges = nil
+		routes.Items[0].Spec.Destinations = nil
+		routes.Items[1].Spec.AllowedSourceRanges = []string{"10.0.0.0/8"}
+
+		authorizationPolicies, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authorizationPolicies[0].Spec.Rules[0].To[0].Operation.NotPaths).To(BeEmpty())
+	})
+
+	It("only matches the wildcard host of wildcard routes", func() {
+		wildcard := constructRoute(routeParams{
+			name:   "route-guid-3",
+			host:   "*",
+			domain: "domain1.example.com",
+			destinations: []routeDestParams{
+				{destGUID: "route-3-destination-guid-0", port: 8080, appGUID: "app-guid-3"},
+			},
+		})
+		wildcard.Spec.DeniedSourceRanges = []string{"10.1.0.0/16"}
+		routes.Items = []networkingv1alpha1.Route{wildcard}
+
+		authorizationPolicies, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authorizationPolicies[0].Spec.Rules[0].To[0].Operation.Hosts).To(Equal([]string{"*.domain1.example.com"}))
+	})
+
+	It("builds no AuthorizationPolicies for internal routes", func() {
+		for i := range routes.Items {
+			routes.Items[i].Spec.Domain.Internal = true
+		}
+
+		authorizationPolicies, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(authorizationPolicies).To(BeEmpty())
+	})
+
+	It("returns an error for source ranges that are neither CIDRs nor IPs", func() {
+		routes.Items[0].Spec.AllowedSourceRanges = []string{"10.0.0.0/33"}
+
+		_, err := builder.Build(&routes)
+		Expect(err).To(MatchError(`route guid route-guid-0 has allowed source range "10.0.0.0/33", which is neither a CIDR nor an IP`))
+	})
+})
@@ -0,0 +1,132 @@
+package resourcebuilders
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DesiredStateHashAnnotation holds a hash of the state the route controller
+// built for a resource. A resource that still carries the hash of the current
+// desired state does not need to be written again.
+const DesiredStateHashAnnotation = "cloudfoundry.org/desired-state-hash"
+
+// SetDesiredStateHash annotates the desired resource with the hash of its
+// labels, annotations, owner references and spec. The spec should be passed
+// as a pointer so types with their own JSON marshalling hash consistently.
+func SetDesiredStateHash(meta metav1.Object, spec interface{}) error {
+	annotations := map[string]string{}
+	for key, value := range meta.GetAnnotations() {
+		if key != DesiredStateHashAnnotation {
+			annotations[key] = value
+		}
+	}
+
+	// maps are marshalled with sorted keys, so equal states hash equally
+	desiredState, err := json.Marshal(struct {
+		Labels          map[string]string       `json:"labels"`
+		Annotations     map[string]string       `json:"annotations"`
+		OwnerReferences []metav1.OwnerReference `json:"ownerReferences"`
+		Spec            interface{}             `json:"spec"`
+	}{meta.GetLabels(), annotations, meta.GetOwnerReferences(), spec})
+	if err != nil {
+		return err
+	}
+
+	annotations[DesiredStateHashAnnotation] = fmt.Sprintf("%x", sha256.Sum256(desiredState))
+	meta.SetAnnotations(annotations)
+	return nil
+}
+
+// HasDesiredStateHash is true when the actual resource was last written with
+// the same desired state. Changes made to it by others are not detected, see
+// HasDesiredState.
+func HasDesiredStateHash(actual, desired metav1.Object) bool {
+	hash, ok := desired.GetAnnotations()[DesiredStateHashAnnotation]
+	return ok && actual.GetAnnotations()[DesiredStateHashAnnotation] == hash
+}
+
+// HasDesiredState is true when the actual resource was last written with the
+// same desired state and still contains it. Fields that the apiserver or other
+// tools added are ignored, so defaulted fields don't cause a write on every
+// resync, but fields of the desired state that were edited or removed by hand
+// are drift.
+func HasDesiredState(actual, desired metav1.Object) (bool, error) {
+	if !HasDesiredStateHash(actual, desired) {
+		return false, nil
+	}
+
+	actualFields, err := desiredStateFields(actual)
+	if err != nil {
+		return false, err
+	}
+	desiredFields, err := desiredStateFields(desired)
+	if err != nil {
+		return false, err
+	}
+	return containsFields(actualFields, desiredFields), nil
+}
+
+// desiredStateFields are the resource's fields as JSON, without the metadata
+// that the apiserver maintains and without its status. Typed resources that
+// were read from the apiserver often have no kind, so it is left out too.
+func desiredStateFields(o metav1.Object) (map[string]interface{}, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	delete(fields, "status")
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	metadata, _ := fields["metadata"].(map[string]interface{})
+	desiredMetadata := map[string]interface{}{}
+	for _, key := range []string{"labels", "annotations", "ownerReferences"} {
+		if value, ok := metadata[key]; ok {
+			desiredMetadata[key] = value
+		}
+	}
+	fields["metadata"] = desiredMetadata
+	return fields, nil
+}
+
+// containsFields is true when every field of desired has the same value in
+// actual. Lists must have the same length, their items are compared in order.
+func containsFields(actual, desired interface{}) bool {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		actualMap, ok := actual.(map[string]interface{})
+		if !ok {
+			return len(desired) == 0 && actual == nil
+		}
+		for key, value := range desired {
+			if !containsFields(actualMap[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		actualList, ok := actual.([]interface{})
+		if !ok {
+			return len(desired) == 0 && actual == nil
+		}
+		if len(actualList) != len(desired) {
+			return false
+		}
+		for i := range desired {
+			if !containsFields(actualList[i], desired[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(actual, desired)
+	}
+}
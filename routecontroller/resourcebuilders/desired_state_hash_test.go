@@ -0,0 +1,130 @@
+package resourcebuilders
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("DesiredStateHash", func() {
+	var service corev1.Service
+
+	BeforeEach(func() {
+		service = constructService(serviceParams{
+			fqdn:        "test0.domain0.example.com",
+			destGUID:    "route-0-destination-guid-0",
+			routeGUID:   "route-guid-0",
+			routeUID:    "route-uid-0",
+			appGUID:     "app-guid-0",
+			processType: "web",
+			port:        8080,
+		})
+	})
+
+	hashOf := func(s corev1.Service) string {
+		Expect(SetDesiredStateHash(&s.ObjectMeta, &s.Spec)).To(Succeed())
+		return s.ObjectMeta.Annotations[DesiredStateHashAnnotation]
+	}
+
+	It("annotates the resource and keeps its other annotations", func() {
+		Expect(SetDesiredStateHash(&service.ObjectMeta, &service.Spec)).To(Succeed())
+
+		Expect(service.ObjectMeta.Annotations).To(HaveKeyWithValue("cloudfoundry.org/route-fqdn", "test0.domain0.example.com"))
+		Expect(service.ObjectMeta.Annotations[DesiredStateHashAnnotation]).To(HaveLen(64))
+	})
+
+	It("is the same for the same desired state", func() {
+		Expect(hashOf(service)).To(Equal(hashOf(*service.DeepCopy())))
+	})
+
+	It("does not hash a previous hash", func() {
+		first := hashOf(service)
+		service.ObjectMeta.Annotations[DesiredStateHashAnnotation] = "stale"
+
+		Expect(hashOf(service)).To(Equal(first))
+	})
+
+	It("changes when the spec, labels, annotations or owners change", func() {
+		original := hashOf(service)
+
+		changedSpec := service.DeepCopy()
+		changedSpec.Spec.Ports[0].Port = 9090
+		changedLabels := service.DeepCopy()
+		changedLabels.ObjectMeta.Labels["cloudfoundry.org/app_guid"] = "app-guid-1"
+		changedAnnotations := service.DeepCopy()
+		changedAnnotations.ObjectMeta.Annotations["cloudfoundry.org/route-fqdn"] = "test1.domain0.example.com"
+		changedOwners := service.DeepCopy()
+		changedOwners.ObjectMeta.OwnerReferences = nil
+
+		Expect(hashOf(*changedSpec)).NotTo(Equal(original))
+		Expect(hashOf(*changedLabels)).NotTo(Equal(original))
+		Expect(hashOf(*changedAnnotations)).NotTo(Equal(original))
+		Expect(hashOf(*changedOwners)).NotTo(Equal(original))
+	})
+
+	Describe("HasDesiredStateHash", func() {
+		It("is true only when the actual resource has the desired hash", func() {
+			desired := service.DeepCopy()
+			Expect(SetDesiredStateHash(&desired.ObjectMeta, &desired.Spec)).To(Succeed())
+
+			Expect(HasDesiredStateHash(&service, desired)).To(BeFalse())
+
+			actual := desired.DeepCopy()
+			Expect(HasDesiredStateHash(actual, desired)).To(BeTrue())
+
+			actual.ObjectMeta.Annotations[DesiredStateHashAnnotation] = "stale"
+			Expect(HasDesiredStateHash(actual, desired)).To(BeFalse())
+		})
+
+		It("is false when the desired resource has no hash", func() {
+			Expect(HasDesiredStateHash(&service, &service)).To(BeFalse())
+		})
+	})
+
+	Describe("HasDesiredState", func() {
+		var desired *corev1.Service
+
+		BeforeEach(func() {
+			desired = service.DeepCopy()
+			Expect(SetDesiredStateHash(&desired.ObjectMeta, &desired.Spec)).To(Succeed())
+		})
+
+		It("is true when the actual resource has the desired hash and fields", func() {
+			Expect(HasDesiredState(desired.DeepCopy(), desired)).To(BeTrue())
+		})
+
+		It("ignores fields the apiserver or others added", func() {
+			actual := desired.DeepCopy()
+			actual.ObjectMeta.ResourceVersion = "42"
+			desired.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Service"}
+			actual.ObjectMeta.Labels["added-by"] = "someone-else"
+			actual.Spec.ClusterIP = "10.96.0.10"
+			actual.Spec.Ports[0].Protocol = corev1.ProtocolTCP
+
+			Expect(HasDesiredState(actual, desired)).To(BeTrue())
+		})
+
+		It("is false when a desired field was edited by hand", func() {
+			actual := desired.DeepCopy()
+			actual.Spec.Ports[0].Port = 9090
+
+			Expect(HasDesiredState(actual, desired)).To(BeFalse())
+		})
+
+		It("is false when a desired field was removed by hand", func() {
+			actual := desired.DeepCopy()
+			delete(actual.ObjectMeta.Labels, "cloudfoundry.org/app_guid")
+
+			Expect(HasDesiredState(actual, desired)).To(BeFalse())
+		})
+
+		It("is false when the actual resource has another hash", func() {
+			actual := desired.DeepCopy()
+			actual.ObjectMeta.Annotations[DesiredStateHashAnnotation] = "stale"
+
+			Expect(HasDesiredState(actual, desired)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,343 @@
+package resourcebuilders
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RateLimitFilterName is Envoy's local rate limit HTTP filter. Its per-route
+// config holds the rate limits of Routes.
+const RateLimitFilterName = "envoy.filters.http.local_ratelimit"
+
+// RateLimitFilterEnvoyFilterName is the EnvoyFilter adding the local rate limit
+// filter to the gateway's HTTP filters. It is shared by every rate limited
+// FQDN, as each EnvoyFilter inserting it would add another copy.
+const RateLimitFilterEnvoyFilterName = "cf-local-ratelimit"
+
+const rateLimitStatPrefix = "http_local_rate_limiter"
+
+// rateLimitHeaderDescriptorKey is the descriptor key of the header telling
+// clients of a per client rate limit apart. Client addresses use Envoy's
+// remote_address key.
+const rateLimitHeaderDescriptorKey = "client_header"
+
+// maxRateLimitClients bounds the token buckets each gateway replica keeps for
+// the clients of a per client rate limit. The least recently used are evicted.
+const maxRateLimitClients = 10000
+
+// RouterErrorEnvoyFilterName is the EnvoyFilter adding gorouter's router error
+// header to the responses of aborted routes without destinations. It is
+// shared by every FQDN, like the rate limit filter.
+const RouterErrorEnvoyFilterName = "cf-router-error"
+
+// EnvoyFilterBuilder builds an EnvoyFilter for each external FQDN with rate
+// limited Routes. The filters patch the gateway's routes, which are matched by
+// name, so the VirtualService names the routes of rate limited Routes after
+// them.
+type EnvoyFilterBuilder struct {
+	// EnvoyFilters only apply to workloads in their own namespace, so they
+	// are created in the gateway's namespace rather than the Routes'
+	GatewayNamespace string
+	// Labels selecting the gateway's pods
+	GatewaySelector map[string]string
+}
+
+// envoy filter names cannot contain special characters
+func EnvoyFilterName(fqdn string) string {
+	sum := sha256.Sum256([]byte(fqdn))
+	return fmt.Sprintf("ef-%x", sum)
+}
+
+// Build returns EnvoyFilters for the FQDNs of external routes with a rate
+// limit only
+func (b *EnvoyFilterBuilder) Build(routes *networkingv1alpha1.RouteList) ([]istionetworkingv1alpha3.EnvoyFilter, error) {
+	envoyFilters := []istionetworkingv1alpha3.EnvoyFilter{}
+	err := forEachFQDN(routes, func(fqdn string, routes []networkingv1alpha1.Route) error {
+		envoyFilter, ok, err := b.fqdnToEnvoyFilter(fqdn, routes)
+		if ok {
+			envoyFilters = append(envoyFilters, envoyFilter)
+		}
+		return err
+	})
+	if err != nil {
+		return []istionetworkingv1alpha3.EnvoyFilter{}, err
+	}
+	return envoyFilters, nil
+}
+
+// BuildRateLimitFilter returns the EnvoyFilter adding the local rate limit
+// filter to the gateway. Without a token bucket of its own it only limits
+// routes with a per-route config.
+func (b *EnvoyFilterBuilder) BuildRateLimitFilter() (istionetworkingv1alpha3.EnvoyFilter, error) {
+	value, err := toStruct(map[string]interface{}{
+		"name": RateLimitFilterName,
+		"typed_config": map[string]interface{}{
+			"@type":    "type.googleapis.com/udpa.type.v1.TypedStruct",
+			"type_url": "type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit",
+			"value": map[string]interface{}{
+				"stat_prefix": rateLimitStatPrefix,
+			},
+		},
+	})
+	if err != nil {
+		return istionetworkingv1alpha3.EnvoyFilter{}, err
+	}
+
+	return istionetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RateLimitFilterEnvoyFilterName,
+			Namespace: b.GatewayNamespace,
+		},
+		Spec: istionetworkingv1alpha3.EnvoyFilterSpec{
+			EnvoyFilter: istiov1alpha3.EnvoyFilter{
+				WorkloadSelector: &istiov1alpha3.WorkloadSelector{Labels: cloneLabels(b.GatewaySelector)},
+				ConfigPatches: []*istiov1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+					{
+						ApplyTo: istiov1alpha3.EnvoyFilter_HTTP_FILTER,
+						Match: &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+							Context: istiov1alpha3.EnvoyFilter_GATEWAY,
+							ObjectTypes: &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+								Listener: &istiov1alpha3.EnvoyFilter_ListenerMatch{
+									FilterChain: &istiov1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+										Filter: &istiov1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+											Name:      "envoy.filters.network.http_connection_manager",
+											SubFilter: &istiov1alpha3.EnvoyFilter_ListenerMatch_SubFilterMatch{Name: "envoy.filters.http.router"},
+										},
+									},
+								},
+							},
+						},
+						Patch: &istiov1alpha3.EnvoyFilter_Patch{
+							Operation: istiov1alpha3.EnvoyFilter_Patch_INSERT_BEFORE,
+							Value:     value,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// BuildRouterErrorFilter returns the EnvoyFilter that maps the gateway's local
+// replies for requests aborted with the status code of routes without
+// destinations to replies carrying the router error header. Envoy doesn't apply
+// a route's response header operations to its fault injection aborts.
+func (b *EnvoyFilterBuilder) BuildRouterErrorFilter(statusCode int) (istionetworkingv1alpha3.EnvoyFilter, error) {
+	statusCode = noDestinationsStatusCode(statusCode)
+	value, err := toStruct(map[string]interface{}{
+		"typed_config": map[string]interface{}{
+			"@type": "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+			"local_reply_config": map[string]interface{}{
+				"mappers": []interface{}{
+					map[string]interface{}{
+						"filter": map[string]interface{}{
+							"and_filter": map[string]interface{}{
+								"filters": []interface{}{
+									// FI is the response flag of fault injection aborts
+									map[string]interface{}{"response_flag_filter": map[string]interface{}{"flags": []interface{}{"FI"}}},
+									map[string]interface{}{"status_code_filter": map[string]interface{}{
+										"comparison": map[string]interface{}{
+											"op": "EQ",
+											"value": map[string]interface{}{
+												"default_value": statusCode,
+												"runtime_key":   "cf_no_destinations_status_code",
+											},
+										},
+									}},
+								},
+							},
+						},
+						"headers_to_add": []interface{}{
+							map[string]interface{}{
+								"header": map[string]interface{}{
+									"key":   RouterErrorHeader,
+									"value": routerErrorForStatusCode(statusCode),
+								},
+								"append": false,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return istionetworkingv1alpha3.EnvoyFilter{}, err
+	}
+
+	return istionetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RouterErrorEnvoyFilterName,
+			Namespace: b.GatewayNamespace,
+		},
+		Spec: istionetworkingv1alpha3.EnvoyFilterSpec{
+			EnvoyFilter: istiov1alpha3.EnvoyFilter{
+				WorkloadSelector: &istiov1alpha3.WorkloadSelector{Labels: cloneLabels(b.GatewaySelector)},
+				ConfigPatches: []*istiov1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+					{
+						ApplyTo: istiov1alpha3.EnvoyFilter_NETWORK_FILTER,
+						Match: &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+							Context: istiov1alpha3.EnvoyFilter_GATEWAY,
+							ObjectTypes: &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_Listener{
+								Listener: &istiov1alpha3.EnvoyFilter_ListenerMatch{
+									FilterChain: &istiov1alpha3.EnvoyFilter_ListenerMatch_FilterChainMatch{
+										Filter: &istiov1alpha3.EnvoyFilter_ListenerMatch_FilterMatch{
+											Name: "envoy.filters.network.http_connection_manager",
+										},
+									},
+								},
+							},
+						},
+						Patch: &istiov1alpha3.EnvoyFilter_Patch{
+							Operation: istiov1alpha3.EnvoyFilter_Patch_MERGE,
+							Value:     value,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (b *EnvoyFilterBuilder) fqdnToEnvoyFilter(fqdn string, routes []networkingv1alpha1.Route) (istionetworkingv1alpha3.EnvoyFilter, bool, error) {
+	envoyFilter := istionetworkingv1alpha3.EnvoyFilter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      EnvoyFilterName(fqdn),
+			Namespace: b.GatewayNamespace,
+			Annotations: map[string]string{
+				"cloudfoundry.org/fqdn": fqdn,
+			},
+		},
+		Spec: istionetworkingv1alpha3.EnvoyFilterSpec{
+			EnvoyFilter: istiov1alpha3.EnvoyFilter{
+				WorkloadSelector: &istiov1alpha3.WorkloadSelector{Labels: cloneLabels(b.GatewaySelector)},
+			},
+		},
+	}
+
+	sortRoutes(routes)
+
+	for _, route := range routes {
+		rateLimit := route.EffectiveRateLimit()
+		if rateLimit == nil {
+			continue
+		}
+		if err := route.ValidateRateLimit(); err != nil {
+			return istionetworkingv1alpha3.EnvoyFilter{}, false, err
+		}
+
+		patch, err := rateLimitRoutePatch(route.ObjectMeta.Name, rateLimit)
+		if err != nil {
+			return istionetworkingv1alpha3.EnvoyFilter{}, false, err
+		}
+		envoyFilter.Spec.ConfigPatches = append(envoyFilter.Spec.ConfigPatches, patch)
+	}
+
+	return envoyFilter, len(envoyFilter.Spec.ConfigPatches) > 0, nil
+}
+
+// rateLimitRoutePatch gives the gateway route of a Route its token buckets in
+// each gateway replica. Per client rate limits have the route emit a
+// descriptor keyed on the client's address or header, and the filter keeps a
+// bucket for each value of it. Requests without the header fall back to the
+// route's bucket, which is the only one for limits shared by every client.
+func rateLimitRoutePatch(routeName string, rateLimit *networkingv1alpha1.RouteRateLimitStatus) (*istiov1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, error) {
+	fullyEnabled := func(runtimeKey string) map[string]interface{} {
+		return map[string]interface{}{
+			"runtime_key":   runtimeKey,
+			"default_value": map[string]interface{}{"numerator": 100, "denominator": "HUNDRED"},
+		}
+	}
+	tokenBucket := map[string]interface{}{
+		"max_tokens":      rateLimit.Burst,
+		"tokens_per_fill": rateLimit.RequestsPerSecond,
+		"fill_interval":   "1s",
+	}
+	config := map[string]interface{}{
+		"stat_prefix":     rateLimitStatPrefix,
+		"token_bucket":    tokenBucket,
+		"filter_enabled":  fullyEnabled("local_rate_limit_enabled"),
+		"filter_enforced": fullyEnabled("local_rate_limit_enforced"),
+		"status":          map[string]interface{}{"code": rateLimit.ResponseCode},
+	}
+	patch := map[string]interface{}{
+		"typed_per_filter_config": map[string]interface{}{
+			RateLimitFilterName: map[string]interface{}{
+				"@type":    "type.googleapis.com/udpa.type.v1.TypedStruct",
+				"type_url": "type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit",
+				"value":    config,
+			},
+		},
+	}
+
+	if rateLimit.Scope == networkingv1alpha1.RateLimitScopeClientPerGatewayReplica {
+		descriptorKey := "remote_address"
+		action := map[string]interface{}{"remote_address": map[string]interface{}{}}
+		if rateLimit.Header != "" {
+			descriptorKey = rateLimitHeaderDescriptorKey
+			action = map[string]interface{}{"request_headers": map[string]interface{}{
+				"header_name":    rateLimit.Header,
+				"descriptor_key": rateLimitHeaderDescriptorKey,
+			}}
+		}
+		patch["route"] = map[string]interface{}{
+			"rate_limits": []interface{}{
+				map[string]interface{}{"actions": []interface{}{action}},
+			},
+		}
+		// A descriptor entry without a value matches every value, with a
+		// token bucket of its own for each
+		config["descriptors"] = []interface{}{
+			map[string]interface{}{
+				"entries":      []interface{}{map[string]interface{}{"key": descriptorKey}},
+				"token_bucket": tokenBucket,
+			},
+		}
+		config["max_dynamic_descriptors"] = maxRateLimitClients
+		config["always_consume_default_token_bucket"] = false
+	}
+
+	value, err := toStruct(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectPatch{
+		ApplyTo: istiov1alpha3.EnvoyFilter_HTTP_ROUTE,
+		Match: &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectMatch{
+			Context: istiov1alpha3.EnvoyFilter_GATEWAY,
+			ObjectTypes: &istiov1alpha3.EnvoyFilter_EnvoyConfigObjectMatch_RouteConfiguration{
+				RouteConfiguration: &istiov1alpha3.EnvoyFilter_RouteConfigurationMatch{
+					Vhost: &istiov1alpha3.EnvoyFilter_RouteConfigurationMatch_VirtualHostMatch{
+						Route: &istiov1alpha3.EnvoyFilter_RouteConfigurationMatch_RouteMatch{Name: routeName},
+					},
+				},
+			},
+		},
+		Patch: &istiov1alpha3.EnvoyFilter_Patch{
+			Operation: istiov1alpha3.EnvoyFilter_Patch_MERGE,
+			Value:     value,
+		},
+	}, nil
+}
+
+// toStruct converts a patch value to the protobuf struct EnvoyFilters hold it in
+func toStruct(value map[string]interface{}) (*types.Struct, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	result := &types.Struct{}
+	if err := jsonpb.UnmarshalString(string(data), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,258 @@
+package resourcebuilders
+
+import (
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/gogo/protobuf/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+)
+
+var _ = Describe("EnvoyFilterBuilder", func() {
+	var (
+		routes  networkingv1alpha1.RouteList
+		builder EnvoyFilterBuilder
+	)
+
+	structJSON := func(value *types.Struct) string {
+		json, err := (&jsonpb.Marshaler{}).MarshalToString(value)
+		Expect(err).NotTo(HaveOccurred())
+		return json
+	}
+
+	BeforeEach(func() {
+		builder = EnvoyFilterBuilder{
+			GatewayNamespace: "istio-system",
+			GatewaySelector:  map[string]string{"istio": "ingressgateway"},
+		}
+
+		routes = networkingv1alpha1.RouteList{
+			Items: []networkingv1alpha1.Route{
+				constructRoute(routeParams{
+					name:   "route-guid-0",
+					host:   "test0",
+					path:   "/path0",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+					},
+				}),
+				constructRoute(routeParams{
+					name:   "route-guid-1",
+					host:   "test0",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-1-destination-guid-0", port: 8080, appGUID: "app-guid-1"},
+					},
+				}),
+				constructRoute(routeParams{
+					name:   "route-guid-2",
+					host:   "test1",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-2-destination-guid-0", port: 8080, appGUID: "app-guid-2"},
+					},
+				}),
+			},
+		}
+		burst := 20
+		routes.Items[0].Spec.RateLimit = &networkingv1alpha1.RouteRateLimit{RequestsPerSecond: 10, Burst: &burst}
+	})
+
+	It("builds an EnvoyFilter for each FQDN with rate limited routes only", func() {
+		envoyFilters, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(envoyFilters).To(HaveLen(1))
+		envoyFilter := envoyFilters[0]
+		Expect(envoyFilter.ObjectMeta.Name).To(Equal(EnvoyFilterName("test0.domain0.example.com")))
+		Expect(envoyFilter.ObjectMeta.Namespace).To(Equal("istio-system"))
+		Expect(envoyFilter.ObjectMeta.Annotations).To(HaveKeyWithValue("cloudfoundry.org/fqdn", "test0.domain0.example.com"))
+		Expect(envoyFilter.ObjectMeta.OwnerReferences).To(BeEmpty())
+		Expect(envoyFilter.Spec.WorkloadSelector.Labels).To(Equal(map[string]string{"istio": "ingressgateway"}))
+	})
+
+	It("gives the gateway route of each rate limited route a token bucket", func() {
+		perClient := false
+		routes.Items[0].Spec.RateLimit.PerClient = &perClient
+
+		envoyFilters, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+
+		patches := envoyFilters[0].Spec.ConfigPatches
+		Expect(patches).To(HaveLen(1))
+		Expect(patches[0].ApplyTo).To(Equal(istiov1alpha3.EnvoyFilter_HTTP_ROUTE))
+		Expect(patches[0].Match.Context).To(Equal(istiov1alpha3.EnvoyFilter_GATEWAY))
+		Expect(patches[0].Match.GetRouteConfiguration().Vhost.Route.Name).To(Equal("route-guid-0"))
+		Expect(patches[0].Patch.Operation).To(Equal(istiov1alpha3.EnvoyFilter_Patch_MERGE))
+		Expect(structJSON(patches[0].Patch.Value)).To(MatchJSON(`{
+			"typed_per_filter_config": {
+				"envoy.filters.http.local_ratelimit": {
+					"@type": "type.googleapis.com/udpa.type.v1.TypedStruct",
+					"type_url": "type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit",
+					"value": {
+						"stat_prefix": "http_local_rate_limiter",
+						"token_bucket": {"max_tokens": 20, "tokens_per_fill": 10, "fill_interval": "1s"},
+						"filter_enabled": {"runtime_key": "local_rate_limit_enabled", "default_value": {"numerator": 100, "denominator": "HUNDRED"}},
+						"filter_enforced": {"runtime_key": "local_rate_limit_enforced", "default_value": {"numerator": 100, "denominator": "HUNDRED"}},
+						"status": {"code": 429}
+					}
+				}
+			}
+		}`))
+	})
+
+	Context("when the rate limit is per client", func() {
+		It("gives two clients with different addresses separate token buckets", func() {
+			envoyFilters, err := builder.Build(&routes)
+			Expect(err).NotTo(HaveOccurred())
+
+			patches := envoyFilters[0].Spec.ConfigPatches
+			Expect(patches).To(HaveLen(1))
+			// The descriptor entry has no value, so the filter keeps a bucket
+			// for each client address the route's rate limit action emits,
+			// and requests don't also take a token from the route's bucket
+			Expect(structJSON(patches[0].Patch.Value)).To(MatchJSON(`{
+				"route": {
+					"rate_limits": [{"actions": [{"remote_address": {}}]}]
+				},
+				"typed_per_filter_config": {
+					"envoy.filters.http.local_ratelimit": {
+						"@type": "type.googleapis.com/udpa.type.v1.TypedStruct",
+						"type_url": "type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit",
+						"value": {
+							"stat_prefix": "http_local_rate_limiter",
+							"token_bucket": {"max_tokens": 20, "tokens_per_fill": 10, "fill_interval": "1s"},
+							"descriptors": [{
+								"entries": [{"key": "remote_address"}],
+								"token_bucket": {"max_tokens": 20, "tokens_per_fill": 10, "fill_interval": "1s"}
+							}],
+							"max_dynamic_descriptors": 10000,
+							"always_consume_default_token_bucket": false,
+							"filter_enabled": {"runtime_key": "local_rate_limit_enabled", "default_value": {"numerator": 100, "denominator": "HUNDRED"}},
+							"filter_enforced": {"runtime_key": "local_rate_limit_enforced", "default_value": {"numerator": 100, "denominator": "HUNDRED"}},
+							"status": {"code": 429}
+						}
+					}
+				}
+			}`))
+		})
+
+		It("tells clients apart by the configured header", func() {
+			routes.Items[0].Spec.RateLimit.Header = "X-Api-Key"
+
+			envoyFilters, err := builder.Build(&routes)
+			Expect(err).NotTo(HaveOccurred())
+
+			value := structJSON(envoyFilters[0].Spec.ConfigPatches[0].Patch.Value)
+			Expect(value).To(ContainSubstring(`"rate_limits":[{"actions":[{"request_headers":{"descriptor_key":"client_header","header_name":"X-Api-Key"}}]}]`))
+			Expect(value).To(ContainSubstring(`"entries":[{"key":"client_header"}]`))
+		})
+	})
+
+	It("patches every rate limited route of the FQDN", func() {
+		responseCode := 503
+		routes.Items[1].Spec.RateLimit = &networkingv1alpha1.RouteRateLimit{RequestsPerSecond: 5, ResponseCode: &responseCode}
+
+		envoyFilters, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+
+		patches := envoyFilters[0].Spec.ConfigPatches
+		Expect(patches).To(HaveLen(2))
+		Expect(patches[0].Match.GetRouteConfiguration().Vhost.Route.Name).To(Equal("route-guid-0"))
+		Expect(patches[1].Match.GetRouteConfiguration().Vhost.Route.Name).To(Equal("route-guid-1"))
+		Expect(structJSON(patches[1].Patch.Value)).To(ContainSubstring(`"token_bucket":{"fill_interval":"1s","max_tokens":5,"tokens_per_fill":5}`))
+		Expect(structJSON(patches[1].Patch.Value)).To(ContainSubstring(`"status":{"code":503}`))
+	})
+
+	It("builds no EnvoyFilters for internal routes", func() {
+		routes.Items[0].Spec.Domain.Internal = true
+		routes.Items[1].Spec.Domain.Internal = true
+
+		envoyFilters, err := builder.Build(&routes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(envoyFilters).To(BeEmpty())
+	})
+
+	It("returns an error for response codes Envoy can't return", func() {
+		responseCode := 499
+		routes.Items[0].Spec.RateLimit.ResponseCode = &responseCode
+
+		_, err := builder.Build(&routes)
+		Expect(err).To(MatchError("route guid route-guid-0 has rate limit response code 499, which is not a known 4xx or 5xx status code"))
+	})
+
+	Describe("BuildRouterErrorFilter", func() {
+		It("adds the router error header to the gateway's replies for aborted routes without destinations", func() {
+			envoyFilter, err := builder.BuildRouterErrorFilter(404)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envoyFilter.ObjectMeta.Name).To(Equal(RouterErrorEnvoyFilterName))
+			Expect(envoyFilter.ObjectMeta.Namespace).To(Equal("istio-system"))
+			Expect(envoyFilter.Spec.WorkloadSelector.Labels).To(Equal(map[string]string{"istio": "ingressgateway"}))
+
+			patches := envoyFilter.Spec.ConfigPatches
+			Expect(patches).To(HaveLen(1))
+			Expect(patches[0].ApplyTo).To(Equal(istiov1alpha3.EnvoyFilter_NETWORK_FILTER))
+			Expect(patches[0].Match.Context).To(Equal(istiov1alpha3.EnvoyFilter_GATEWAY))
+			Expect(patches[0].Match.GetListener().FilterChain.Filter.Name).To(Equal("envoy.filters.network.http_connection_manager"))
+			Expect(patches[0].Patch.Operation).To(Equal(istiov1alpha3.EnvoyFilter_Patch_MERGE))
+			Expect(structJSON(patches[0].Patch.Value)).To(MatchJSON(`{
+				"typed_config": {
+					"@type": "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"local_reply_config": {
+						"mappers": [{
+							"filter": {
+								"and_filter": {
+									"filters": [
+										{"response_flag_filter": {"flags": ["FI"]}},
+										{"status_code_filter": {"comparison": {"op": "EQ", "value": {"default_value": 404, "runtime_key": "cf_no_destinations_status_code"}}}}
+									]
+								}
+							},
+							"headers_to_add": [{"header": {"key": "X-Cf-Routererror", "value": "unknown_route"}, "append": false}]
+						}]
+					}
+				}
+			}`))
+		})
+
+		It("defaults to the no_endpoints router error of a 503", func() {
+			envoyFilter, err := builder.BuildRouterErrorFilter(0)
+			Expect(err).NotTo(HaveOccurred())
+
+			value := structJSON(envoyFilter.Spec.ConfigPatches[0].Patch.Value)
+			Expect(value).To(ContainSubstring(`"default_value":503`))
+			Expect(value).To(ContainSubstring(`"value":"no_endpoints"`))
+		})
+	})
+
+	Describe("BuildRateLimitFilter", func() {
+		It("inserts the local rate limit filter before the router of the gateway", func() {
+			envoyFilter, err := builder.BuildRateLimitFilter()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(envoyFilter.ObjectMeta.Name).To(Equal(RateLimitFilterEnvoyFilterName))
+			Expect(envoyFilter.ObjectMeta.Namespace).To(Equal("istio-system"))
+			Expect(envoyFilter.ObjectMeta.Annotations).NotTo(HaveKey("cloudfoundry.org/fqdn"))
+			Expect(envoyFilter.Spec.WorkloadSelector.Labels).To(Equal(map[string]string{"istio": "ingressgateway"}))
+
+			patches := envoyFilter.Spec.ConfigPatches
+			Expect(patches).To(HaveLen(1))
+			Expect(patches[0].ApplyTo).To(Equal(istiov1alpha3.EnvoyFilter_HTTP_FILTER))
+			Expect(patches[0].Match.Context).To(Equal(istiov1alpha3.EnvoyFilter_GATEWAY))
+			Expect(patches[0].Match.GetListener().FilterChain.Filter.SubFilter.Name).To(Equal("envoy.filters.http.router"))
+			Expect(patches[0].Patch.Operation).To(Equal(istiov1alpha3.EnvoyFilter_Patch_INSERT_BEFORE))
+			Expect(structJSON(patches[0].Patch.Value)).To(MatchJSON(`{
+				"name": "envoy.filters.http.local_ratelimit",
+				"typed_config": {
+					"@type": "type.googleapis.com/udpa.type.v1.TypedStruct",
+					"type_url": "type.googleapis.com/envoy.extensions.filters.http.local_ratelimit.v3.LocalRateLimit",
+					"value": {"stat_prefix": "http_local_rate_limiter"}
+				}
+			}`))
+		})
+	})
+})
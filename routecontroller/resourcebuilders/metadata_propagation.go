@@ -0,0 +1,39 @@
+package resourcebuilders
+
+import (
+	"strings"
+)
+
+// MetadataPropagation selects the labels and annotations of a Route that are
+// copied to the Services and VirtualServices generated for it. Keys the route
+// controller sets itself are never overwritten.
+type MetadataPropagation struct {
+	// Keys starting with one of these prefixes are copied
+	Prefixes []string
+	// Keys that are copied as they are
+	Keys []string
+}
+
+func (p MetadataPropagation) selects(key string) bool {
+	for _, allowed := range p.Keys {
+		if key == allowed {
+			return true
+		}
+	}
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// propagate copies the selected entries of from that are not in to yet
+func (p MetadataPropagation) propagate(from, to map[string]string) {
+	for key, value := range from {
+		if _, exists := to[key]; exists || !p.selects(key) {
+			continue
+		}
+		to[key] = value
+	}
+}
@@ -4,33 +4,27 @@ import (
 	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-type ServiceBuilder struct{}
+// Services for destinations in another namespace than their route cannot be
+// owned by it, so this label records the route's namespace instead
+const RouteNamespaceLabel = "cloudfoundry.org/route_namespace"
 
-func (b *ServiceBuilder) BuildMutateFunction(actualService, desiredService *corev1.Service) controllerutil.MutateFn {
-	return func() error {
-		actualService.ObjectMeta.Labels = desiredService.ObjectMeta.Labels
-		actualService.ObjectMeta.Annotations = desiredService.ObjectMeta.Annotations
-		actualService.ObjectMeta.OwnerReferences = desiredService.ObjectMeta.OwnerReferences
-		actualService.Spec.Selector = desiredService.Spec.Selector
-		actualService.Spec.Ports = desiredService.Spec.Ports
-		return nil
-	}
+type ServiceBuilder struct {
+	Propagation MetadataPropagation
 }
 
 func (b *ServiceBuilder) Build(route *networkingv1alpha1.Route) []corev1.Service {
 	const httpPortName = "http"
 	services := []corev1.Service{}
 	for _, dest := range route.Spec.Destinations {
+		namespace := DestinationNamespace(*route, dest)
 		service := corev1.Service{
 			ObjectMeta: metav1.ObjectMeta{
-				OwnerReferences: []metav1.OwnerReference{routeToOwnerRef(route)},
-				Name:            serviceName(dest),
-				Namespace:       route.ObjectMeta.Namespace,
-				Labels:          map[string]string{},
-				Annotations:     map[string]string{},
+				Name:        serviceName(dest),
+				Namespace:   namespace,
+				Labels:      map[string]string{},
+				Annotations: map[string]string{},
 			},
 			Spec: corev1.ServiceSpec{
 				Selector: dest.Selector.MatchLabels,
@@ -45,15 +39,33 @@ func (b *ServiceBuilder) Build(route *networkingv1alpha1.Route) []corev1.Service
 		service.ObjectMeta.Labels["cloudfoundry.org/process_type"] = dest.App.Process.Type
 		service.ObjectMeta.Labels["cloudfoundry.org/route_guid"] = route.ObjectMeta.Name
 		service.ObjectMeta.Annotations["cloudfoundry.org/route-fqdn"] = route.FQDN()
+		if namespace == route.ObjectMeta.Namespace {
+			service.ObjectMeta.OwnerReferences = []metav1.OwnerReference{routeToOwnerRef(route)}
+		} else {
+			service.ObjectMeta.Labels[RouteNamespaceLabel] = route.ObjectMeta.Namespace
+		}
+		b.Propagation.propagate(route.ObjectMeta.Labels, service.ObjectMeta.Labels)
+		b.Propagation.propagate(route.ObjectMeta.Annotations, service.ObjectMeta.Annotations)
 		services = append(services, service)
 	}
 	return services
 }
 
+// DestinationNamespace is the namespace the destination's app runs in, which
+// is also where its Service lives
+func DestinationNamespace(route networkingv1alpha1.Route, dest networkingv1alpha1.RouteDestination) string {
+	if dest.Namespace == "" {
+		return route.ObjectMeta.Namespace
+	}
+	return dest.Namespace
+}
+
+// Routes read from a list don't always have their kind set, so it is not
+// taken from the Route
 func routeToOwnerRef(r *networkingv1alpha1.Route) metav1.OwnerReference {
 	return metav1.OwnerReference{
 		APIVersion: networkingv1alpha1.SchemeBuilder.GroupVersion.String(),
-		Kind:       r.TypeMeta.Kind,
+		Kind:       "Route",
 		Name:       r.ObjectMeta.Name,
 		UID:        r.ObjectMeta.UID,
 	}
@@ -136,6 +136,82 @@ var _ = Describe("ServiceBuilder", func() {
 			Expect(builder.Build(&route.Items[0])).To(Equal(expectedServices))
 		})
 
+		Context("when a destination is in another namespace", func() {
+			It("creates its Service in that namespace, labelled with the route instead of owned by it", func() {
+				route := constructRoute(routeParams{
+					name:   "route-guid-0",
+					host:   "test0",
+					path:   "/path0",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{
+							destGUID: "route-0-destination-guid-0",
+							port:     9000,
+							appGUID:  "app-guid-0",
+						},
+					},
+				})
+				route.Spec.Destinations[0].Namespace = "other-namespace"
+
+				builder := ServiceBuilder{}
+				services := builder.Build(&route)
+				Expect(services).To(HaveLen(1))
+
+				service := services[0]
+				Expect(service.ObjectMeta.Name).To(Equal("s-route-0-destination-guid-0"))
+				Expect(service.ObjectMeta.Namespace).To(Equal("other-namespace"))
+				Expect(service.ObjectMeta.OwnerReferences).To(BeEmpty())
+				Expect(service.ObjectMeta.Labels).To(Equal(map[string]string{
+					"cloudfoundry.org/route_guid":      "route-guid-0",
+					"cloudfoundry.org/route_namespace": "workload-namespace",
+					"cloudfoundry.org/app_guid":        "app-guid-0",
+					"cloudfoundry.org/process_type":    "process-type-1",
+				}))
+			})
+		})
+
+		Context("when route labels and annotations are propagated", func() {
+			It("copies the selected ones without overwriting the generated ones", func() {
+				route := constructRoute(routeParams{
+					name:   "route-guid-0",
+					host:   "test0",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{
+							destGUID: "route-0-destination-guid-0",
+							port:     9000,
+							appGUID:  "app-guid-0",
+						},
+					},
+				})
+				route.ObjectMeta.Labels["team"] = "routing"
+				route.ObjectMeta.Labels["cloudfoundry.org/app_guid"] = "not-the-app-guid"
+				route.ObjectMeta.Annotations = map[string]string{
+					"prometheus.io/scrape": "true",
+					"prometheus.io/port":   "9102",
+					"kapp.k14s.io/change":  "not-propagated",
+				}
+
+				builder := ServiceBuilder{
+					Propagation: MetadataPropagation{
+						Prefixes: []string{"prometheus.io/", "cloudfoundry.org/"},
+						Keys:     []string{"team"},
+					},
+				}
+				services := builder.Build(&route)
+				Expect(services).To(HaveLen(1))
+
+				Expect(services[0].ObjectMeta.Labels).To(HaveKeyWithValue("team", "routing"))
+				Expect(services[0].ObjectMeta.Labels).To(HaveKeyWithValue("cloudfoundry.org/app_guid", "app-guid-0"))
+				Expect(services[0].ObjectMeta.Labels).To(HaveKeyWithValue("cloudfoundry.org/space_guid", "space-guid-0"))
+				Expect(services[0].ObjectMeta.Annotations).To(Equal(map[string]string{
+					"cloudfoundry.org/route-fqdn": "test0.domain0.example.com",
+					"prometheus.io/scrape":        "true",
+					"prometheus.io/port":          "9102",
+				}))
+			})
+		})
+
 		Context("when a route has no destinations", func() {
 			It("does not create a Service", func() {
 				route := networkingv1alpha1.RouteList{
@@ -156,52 +232,4 @@ var _ = Describe("ServiceBuilder", func() {
 			})
 		})
 	})
-
-	Describe("BuildMutateFunction", func() {
-		It("builds a mutate function that copies desired state to actual resource", func() {
-			actualServiceParams := serviceParams{
-				destGUID:   "route-0-destination-guid-1",
-				serviceUID: "some-uid",
-				clusterIP:  "1.2.3.4",
-			}
-
-			desiredServiceParams := serviceParams{
-				fqdn:        "test0.domain0.example.com",
-				destGUID:    "route-0-destination-guid-1",
-				routeGUID:   "routey-boi",
-				routeUID:    "asdfa-adfsdf-fdsfdsf",
-				appGUID:     "app-guid-1",
-				processType: "process-type-1",
-				port:        9001,
-			}
-
-			actualService := constructService(actualServiceParams)
-			desiredService := constructService(desiredServiceParams)
-			builder := ServiceBuilder{}
-			mutateFn := builder.BuildMutateFunction(&actualService, &desiredService)
-			err := mutateFn()
-			Expect(err).NotTo(HaveOccurred())
-
-			Expect(actualService.ObjectMeta.Name).To(Equal("s-route-0-destination-guid-1"))
-			Expect(actualService.ObjectMeta.Namespace).To(Equal("workload-namespace"))
-			Expect(actualService.ObjectMeta.UID).To(Equal(types.UID("some-uid")))
-			Expect(actualService.ObjectMeta.Labels).To(Equal(desiredService.ObjectMeta.Labels))
-			Expect(actualService.ObjectMeta.Annotations).To(Equal(desiredService.ObjectMeta.Annotations))
-			Expect(len(actualService.ObjectMeta.OwnerReferences)).NotTo(BeZero())
-			Expect(actualService.ObjectMeta.OwnerReferences).To(Equal(desiredService.ObjectMeta.OwnerReferences))
-			Expect(actualService.Spec).To(Equal(corev1.ServiceSpec{
-				ClusterIP: "1.2.3.4",
-				Selector: map[string]string{
-					"cloudfoundry.org/app_guid":     "app-guid-1",
-					"cloudfoundry.org/process_type": "process-type-1",
-				},
-				Ports: []corev1.ServicePort{
-					{
-						Port: 9001,
-						Name: "http",
-					},
-				},
-			}))
-		})
-	})
 })
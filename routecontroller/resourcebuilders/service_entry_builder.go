@@ -0,0 +1,100 @@
+package resourcebuilders
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Port of the ServiceEntry for internal routes without destinations
+const defaultInternalRoutePort = 80
+
+// ServiceEntryBuilder builds a ServiceEntry for each internal FQDN, so the
+// FQDN is known to the mesh and Istio's DNS proxy resolves it inside pods.
+// Requests to it are then routed by the FQDN's VirtualService. The
+// ServiceEntries have no addresses, so the mesh needs DNS capture and auto
+// allocation, see doc/internal-routes.md.
+type ServiceEntryBuilder struct {
+	// Route labels and annotations copied to the ServiceEntry. When Routes
+	// sharing the FQDN disagree, the Route matched first wins.
+	Propagation MetadataPropagation
+}
+
+// service entry names cannot contain special characters
+func ServiceEntryName(fqdn string) string {
+	sum := sha256.Sum256([]byte(fqdn))
+	return fmt.Sprintf("se-%x", sum)
+}
+
+// Build returns ServiceEntries for the FQDNs of internal routes only
+func (b *ServiceEntryBuilder) Build(routes *networkingv1alpha1.RouteList) []istionetworkingv1alpha3.ServiceEntry {
+	serviceEntries := []istionetworkingv1alpha3.ServiceEntry{}
+	_ = forEachFQDN(routes, func(fqdn string, routes []networkingv1alpha1.Route) error {
+		if routes[0].Spec.Domain.Internal {
+			serviceEntries = append(serviceEntries, b.fqdnToServiceEntry(fqdn, routes))
+		}
+		return nil
+	})
+	return serviceEntries
+}
+
+func (b *ServiceEntryBuilder) fqdnToServiceEntry(fqdn string, routes []networkingv1alpha1.Route) istionetworkingv1alpha3.ServiceEntry {
+	serviceEntry := istionetworkingv1alpha3.ServiceEntry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ServiceEntryName(fqdn),
+			Namespace: routes[0].ObjectMeta.Namespace,
+			Labels:    map[string]string{},
+			Annotations: map[string]string{
+				"cloudfoundry.org/fqdn": fqdn,
+			},
+			OwnerReferences: []metav1.OwnerReference{},
+		},
+		// There are no endpoints to resolve, the VirtualService routes the
+		// requests to the destinations' Services
+		Spec: istionetworkingv1alpha3.ServiceEntrySpec{
+			ServiceEntry: istiov1alpha3.ServiceEntry{
+				Hosts:      []string{fqdn},
+				Location:   istiov1alpha3.ServiceEntry_MESH_INTERNAL,
+				Resolution: istiov1alpha3.ServiceEntry_NONE,
+			},
+		},
+	}
+
+	sortRoutes(routes)
+
+	ports := map[int]bool{}
+	for _, route := range routes {
+		serviceEntry.ObjectMeta.OwnerReferences = append(serviceEntry.ObjectMeta.OwnerReferences, routeToOwnerRef(&route))
+		b.Propagation.propagate(route.ObjectMeta.Labels, serviceEntry.ObjectMeta.Labels)
+		b.Propagation.propagate(route.ObjectMeta.Annotations, serviceEntry.ObjectMeta.Annotations)
+		for _, destination := range route.Spec.Destinations {
+			if destination.Port != nil {
+				ports[*destination.Port] = true
+			}
+		}
+	}
+
+	// apps are reached on the ports they listen on, as with container networking
+	if len(ports) == 0 {
+		ports[defaultInternalRoutePort] = true
+	}
+	sortedPorts := []int{}
+	for port := range ports {
+		sortedPorts = append(sortedPorts, port)
+	}
+	sort.Ints(sortedPorts)
+	for _, port := range sortedPorts {
+		serviceEntry.Spec.Ports = append(serviceEntry.Spec.Ports, &istiov1alpha3.Port{
+			Number:   uint32(port),
+			Protocol: "HTTP",
+			Name:     fmt.Sprintf("http-%d", port),
+		})
+	}
+
+	return serviceEntry
+}
@@ -0,0 +1,107 @@
+package resourcebuilders
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	istiov1alpha3 "istio.io/api/networking/v1alpha3"
+)
+
+var _ = Describe("ServiceEntryBuilder", func() {
+	var routes networkingv1alpha1.RouteList
+
+	BeforeEach(func() {
+		routes = networkingv1alpha1.RouteList{
+			Items: []networkingv1alpha1.Route{
+				constructRoute(routeParams{
+					name:     "route-guid-0",
+					host:     "test0",
+					path:     "/path0",
+					domain:   "apps.internal",
+					internal: true,
+					destinations: []routeDestParams{
+						{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+					},
+				}),
+				constructRoute(routeParams{
+					name:     "route-guid-1",
+					host:     "test0",
+					domain:   "apps.internal",
+					internal: true,
+					destinations: []routeDestParams{
+						{destGUID: "route-1-destination-guid-0", port: 9000, appGUID: "app-guid-1"},
+						{destGUID: "route-1-destination-guid-1", port: 8080, appGUID: "app-guid-1"},
+					},
+				}),
+				constructRoute(routeParams{
+					name:   "route-guid-2",
+					host:   "test0",
+					domain: "domain0.example.com",
+					destinations: []routeDestParams{
+						{destGUID: "route-2-destination-guid-0", port: 8080, appGUID: "app-guid-2"},
+					},
+				}),
+			},
+		}
+	})
+
+	It("builds a ServiceEntry for each internal FQDN only", func() {
+		builder := ServiceEntryBuilder{}
+		serviceEntries := builder.Build(&routes)
+
+		Expect(serviceEntries).To(HaveLen(1))
+		serviceEntry := serviceEntries[0]
+		Expect(serviceEntry.ObjectMeta.Name).To(Equal(ServiceEntryName("test0.apps.internal")))
+		Expect(serviceEntry.ObjectMeta.Namespace).To(Equal("workload-namespace"))
+		Expect(serviceEntry.ObjectMeta.Annotations).To(HaveKeyWithValue("cloudfoundry.org/fqdn", "test0.apps.internal"))
+		Expect(serviceEntry.ObjectMeta.OwnerReferences).To(ConsistOf(
+			routeToOwnerRef(&routes.Items[0]),
+			routeToOwnerRef(&routes.Items[1]),
+		))
+
+		Expect(serviceEntry.Spec.Hosts).To(ConsistOf("test0.apps.internal"))
+		Expect(serviceEntry.Spec.Location).To(Equal(istiov1alpha3.ServiceEntry_MESH_INTERNAL))
+		Expect(serviceEntry.Spec.Resolution).To(Equal(istiov1alpha3.ServiceEntry_NONE))
+	})
+
+	It("exposes every port of the destinations", func() {
+		builder := ServiceEntryBuilder{}
+		serviceEntries := builder.Build(&routes)
+
+		Expect(serviceEntries[0].Spec.Ports).To(Equal([]*istiov1alpha3.Port{
+			{Number: 8080, Protocol: "HTTP", Name: "http-8080"},
+			{Number: 9000, Protocol: "HTTP", Name: "http-9000"},
+		}))
+	})
+
+	It("exposes port 80 when there are no destinations", func() {
+		routes.Items[0].Spec.Destinations = nil
+		routes.Items[1].Spec.Destinations = nil
+
+		builder := ServiceEntryBuilder{}
+		serviceEntries := builder.Build(&routes)
+
+		Expect(serviceEntries[0].Spec.Ports).To(Equal([]*istiov1alpha3.Port{
+			{Number: 80, Protocol: "HTTP", Name: "http-80"},
+		}))
+	})
+
+	It("propagates the selected route metadata", func() {
+		routes.Items[0].ObjectMeta.Labels["example.com/team"] = "networking"
+
+		builder := ServiceEntryBuilder{Propagation: MetadataPropagation{Prefixes: []string{"example.com/"}}}
+		serviceEntries := builder.Build(&routes)
+
+		Expect(serviceEntries[0].ObjectMeta.Labels).To(Equal(map[string]string{"example.com/team": "networking"}))
+		Expect(serviceEntries[0].ObjectMeta.Annotations).To(HaveLen(1))
+	})
+
+	Describe("ServiceEntryName", func() {
+		It("creates valid and distinct resource names based on FQDN", func() {
+			name := ServiceEntryName("test0.apps.internal")
+			Expect(name).To(MatchRegexp(`^se-[0-9a-f]{64}$`))
+			Expect(ServiceEntryName("test1.apps.internal")).NotTo(Equal(name))
+		})
+	})
+})
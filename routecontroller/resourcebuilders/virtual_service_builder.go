@@ -4,12 +4,15 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
 
 	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
 	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	"github.com/gogo/protobuf/types"
 	istiov1alpha3 "istio.io/api/networking/v1alpha3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"sort"
 )
@@ -24,8 +27,23 @@ const MeshInternalGateway = "mesh"
 // https://istio.io/docs/concepts/traffic-management/
 const IstioExpectedWeight = int(100)
 
+// Gorouter sets this header on responses it generates itself, so that clients
+// can tell router errors apart from errors returned by the app
+const RouterErrorHeader = "X-Cf-Routererror"
+
 type VirtualServiceBuilder struct {
 	IstioGateways []string
+	// Host of a Service that renders the "no app mapped" page for routes
+	// without destinations. When empty those requests are aborted instead.
+	NoDestinationsBackend string
+	// Status code returned for routes without destinations, defaults to 503
+	NoDestinationsStatusCode int
+	// Leaves out the CF-App-Id, CF-App-Process-Type, CF-Space-Id and
+	// CF-Organization-Id request headers
+	OmitCFIdentityHeaders bool
+	// Route labels and annotations copied to the VirtualService. When Routes
+	// sharing the FQDN disagree, the Route matched first wins.
+	Propagation MetadataPropagation
 }
 
 // virtual service names cannot contain special characters
@@ -34,16 +52,6 @@ func VirtualServiceName(fqdn string) string {
 	return fmt.Sprintf("vs-%x", sum)
 }
 
-func (b *VirtualServiceBuilder) BuildMutateFunction(actualVirtualService, desiredVirtualService *istionetworkingv1alpha3.VirtualService) controllerutil.MutateFn {
-	return func() error {
-		actualVirtualService.ObjectMeta.Labels = desiredVirtualService.ObjectMeta.Labels
-		actualVirtualService.ObjectMeta.Annotations = desiredVirtualService.ObjectMeta.Annotations
-		actualVirtualService.ObjectMeta.OwnerReferences = desiredVirtualService.ObjectMeta.OwnerReferences
-		actualVirtualService.Spec = desiredVirtualService.Spec
-		return nil
-	}
-}
-
 func (b *VirtualServiceBuilder) Build(routes *networkingv1alpha1.RouteList) ([]istionetworkingv1alpha3.VirtualService, error) {
 	resources := []istionetworkingv1alpha3.VirtualService{}
 
@@ -74,6 +82,8 @@ func (b *VirtualServiceBuilder) fqdnToVirtualService(fqdn string, routes []netwo
 			},
 			OwnerReferences: []metav1.OwnerReference{},
 		},
+		// The FQDN of wildcard routes is a wildcard host, which Istio only
+		// matches when no VirtualService has an exact host for the request
 		Spec: istionetworkingv1alpha3.VirtualServiceSpec{
 			VirtualService: istiov1alpha3.VirtualService{Hosts: []string{fqdn}},
 		},
@@ -94,33 +104,55 @@ func (b *VirtualServiceBuilder) fqdnToVirtualService(fqdn string, routes []netwo
 
 	for _, route := range routes {
 		vs.ObjectMeta.OwnerReferences = append(vs.ObjectMeta.OwnerReferences, routeToOwnerRef(&route))
+		b.Propagation.propagate(route.ObjectMeta.Labels, vs.ObjectMeta.Labels)
+		b.Propagation.propagate(route.ObjectMeta.Annotations, vs.ObjectMeta.Annotations)
 		istioRoute := istiov1alpha3.HTTPRoute{}
 
 		if len(route.Spec.Destinations) != 0 {
-			istioDestinations, err := destinationsToHttpRouteDestinations(route, route.Spec.Destinations)
+			destinations, err := activeDestinations(route)
+			if err != nil {
+				return istionetworkingv1alpha3.VirtualService{}, err
+			}
+
+			istioDestinations, err := destinationsToHttpRouteDestinations(route, destinations)
 			if err != nil {
 				return istionetworkingv1alpha3.VirtualService{}, err
 			}
 
+			if b.OmitCFIdentityHeaders {
+				for _, istioDestination := range istioDestinations {
+					istioDestination.Headers = nil
+				}
+			}
+
 			istioRoute.Route = istioDestinations
 		} else if len(routes) > 1 {
 			continue
 		} else {
-			istioRoute.Route = httpRouteDestinationPlaceholder()
+			b.setNoDestinationsResponse(&istioRoute)
 		}
 
-		if route.Spec.Path != "" {
+		if path := route.CanonicalPath(); path != "" {
 			istioRoute.Match = []*istiov1alpha3.HTTPMatchRequest{
 				{
 					Uri: &istiov1alpha3.StringMatch{
 						MatchType: &istiov1alpha3.StringMatch_Prefix{
-							Prefix: route.Spec.Path,
+							Prefix: path,
 						},
 					},
 				},
 			}
 		}
 
+		if route.Spec.Cors != nil {
+			istioRoute.CorsPolicy = corsPolicyToIstioCorsPolicy(route.Spec.Cors)
+		}
+
+		// the EnvoyFilter enforcing the rate limit matches the gateway's route by name
+		if route.EffectiveRateLimit() != nil {
+			istioRoute.Name = route.ObjectMeta.Name
+		}
+
 		vs.Spec.Http = append(vs.Spec.Http, &istioRoute)
 	}
 
@@ -147,6 +179,16 @@ func validateRoutesForFQDN(routes []networkingv1alpha1.Route) error {
 				route.ObjectMeta.Name)
 			return errors.New(msg)
 		}
+
+		// the reconciler leaves out routes with invalid FQDNs or policies, so
+		// they only fail the FQDN when the builder is handed them anyway
+		if err := route.ValidateFQDN(); err != nil {
+			return err
+		}
+
+		if err := route.ValidatePolicies(); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -170,6 +212,18 @@ func groupByFQDN(routes *networkingv1alpha1.RouteList) map[string][]networkingv1
 	return fqdns
 }
 
+// forEachFQDN calls build with the Routes of each FQDN, in a stable order,
+// and stops at the first error
+func forEachFQDN(routes *networkingv1alpha1.RouteList, build func(fqdn string, routes []networkingv1alpha1.Route) error) error {
+	routesForFQDN := groupByFQDN(routes)
+	for _, fqdn := range sortFQDNs(routesForFQDN) {
+		if err := build(fqdn, routesForFQDN[fqdn]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func sortFQDNs(fqdns map[string][]networkingv1alpha1.Route) []string {
 	var fqdnSlice []string
 	for fqdn, _ := range fqdns {
@@ -180,9 +234,11 @@ func sortFQDNs(fqdns map[string][]networkingv1alpha1.Route) []string {
 	return fqdnSlice
 }
 
+// Longer paths sort first so their prefix matches take precedence. The url
+// set by the client is not trusted for this, it may be stale.
 func sortRoutes(routes []networkingv1alpha1.Route) {
 	sort.Slice(routes, func(i, j int) bool {
-		return routes[i].Spec.Url > routes[j].Spec.Url
+		return routes[i].CanonicalURL() > routes[j].CanonicalURL()
 	})
 }
 
@@ -194,6 +250,57 @@ func cloneLabels(template map[string]string) map[string]string {
 	return labels
 }
 
+// Routes without destinations either go to the configured backend or are
+// aborted by Envoy. The backend renders gorouter's "no app mapped" page, so its
+// responses carry gorouter's header for unknown routes. Envoy skips the route's
+// header operations for aborted requests, their header is added by the
+// gateway's router error EnvoyFilter instead.
+func (b *VirtualServiceBuilder) setNoDestinationsResponse(istioRoute *istiov1alpha3.HTTPRoute) {
+	if b.NoDestinationsBackend != "" {
+		istioRoute.Route = []*istiov1alpha3.HTTPRouteDestination{
+			{
+				Destination: &istiov1alpha3.Destination{
+					Host: b.NoDestinationsBackend,
+				},
+			},
+		}
+		istioRoute.Headers = &istiov1alpha3.Headers{
+			Response: &istiov1alpha3.Headers_HeaderOperations{
+				Set: map[string]string{
+					RouterErrorHeader: routerErrorForStatusCode(http.StatusNotFound),
+				},
+			},
+		}
+		return
+	}
+
+	// Istio requires a destination even though the fault aborts every request
+	istioRoute.Route = httpRouteDestinationPlaceholder()
+	istioRoute.Fault = &istiov1alpha3.HTTPFaultInjection{
+		Abort: &istiov1alpha3.HTTPFaultInjection_Abort{
+			Percentage: &istiov1alpha3.Percent{Value: 100},
+			ErrorType: &istiov1alpha3.HTTPFaultInjection_Abort_HttpStatus{
+				HttpStatus: int32(noDestinationsStatusCode(b.NoDestinationsStatusCode)),
+			},
+		},
+	}
+}
+
+func noDestinationsStatusCode(statusCode int) int {
+	if statusCode == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return statusCode
+}
+
+// matches the error codes gorouter uses for unknown routes and routes without endpoints
+func routerErrorForStatusCode(statusCode int) string {
+	if statusCode == http.StatusNotFound {
+		return "unknown_route"
+	}
+	return "no_endpoints"
+}
+
 func httpRouteDestinationPlaceholder() []*istiov1alpha3.HTTPRouteDestination {
 	const PLACEHOLDER_NON_EXISTING_DESTINATION = "no-destinations"
 
@@ -207,15 +314,15 @@ func httpRouteDestinationPlaceholder() []*istiov1alpha3.HTTPRouteDestination {
 }
 
 func destinationsToHttpRouteDestinations(route networkingv1alpha1.Route, destinations []networkingv1alpha1.RouteDestination) ([]*istiov1alpha3.HTTPRouteDestination, error) {
-	err := validateWeights(route, destinations)
+	weights, err := normalizeWeights(route, destinations)
 	if err != nil {
 		return nil, err
 	}
 	httpDestinations := make([]*istiov1alpha3.HTTPRouteDestination, 0)
-	for _, destination := range destinations {
+	for i, destination := range destinations {
 		httpDestination := istiov1alpha3.HTTPRouteDestination{
 			Destination: &istiov1alpha3.Destination{
-				Host: serviceName(destination), // comes from service_builder, will add later
+				Host: destinationHost(route, destination),
 			},
 			Headers: &istiov1alpha3.Headers{
 				Request: &istiov1alpha3.Headers_HeaderOperations{
@@ -227,25 +334,133 @@ func destinationsToHttpRouteDestinations(route networkingv1alpha1.Route, destina
 					},
 				},
 			},
-		}
-		if destination.Weight != nil {
-			httpDestination.Weight = int32(*destination.Weight)
+			Weight: int32(weights[i]),
 		}
 		httpDestinations = append(httpDestinations, &httpDestination)
 	}
-	if destinations[0].Weight == nil {
-		n := len(destinations)
-		for i, _ := range httpDestinations {
-			weight := int(IstioExpectedWeight / n)
-			if i == 0 {
-				// pad the first destination's weight to ensure all weights sum to 100
-				remainder := IstioExpectedWeight - n*weight
-				weight += remainder
-			}
-			httpDestinations[i].Weight = int32(weight)
+	return httpDestinations, nil
+}
+
+// DestinationWeights returns the Istio percentage for each of the route's
+// destinations, in the same order as the destinations. Destinations outside
+// of the active destination set get no traffic.
+func DestinationWeights(route networkingv1alpha1.Route) ([]int, error) {
+	weights := make([]int, len(route.Spec.Destinations))
+	if len(route.Spec.Destinations) == 0 {
+		return weights, nil
+	}
+
+	destinations, err := activeDestinations(route)
+	if err != nil {
+		return nil, err
+	}
+
+	activeWeights, err := normalizeWeights(route, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 0
+	for i, destination := range route.Spec.Destinations {
+		if isActiveDestination(route, destination) {
+			weights[i] = activeWeights[next]
+			next++
 		}
 	}
-	return httpDestinations, nil
+
+	return weights, nil
+}
+
+// ValidateActiveDestinationSet returns an error when the route has destinations
+// but none of them are in its active destination set
+func ValidateActiveDestinationSet(route networkingv1alpha1.Route) error {
+	if len(route.Spec.Destinations) == 0 {
+		return nil
+	}
+	_, err := activeDestinations(route)
+	return err
+}
+
+// ValidateWeights returns an error when the weights of the destinations in the
+// route's active destination set can't be normalized. Routes without active
+// destinations are left to ValidateActiveDestinationSet.
+func ValidateWeights(route networkingv1alpha1.Route) error {
+	if len(route.Spec.Destinations) == 0 {
+		return nil
+	}
+	destinations, err := activeDestinations(route)
+	if err != nil {
+		return nil
+	}
+	return validateWeights(route, destinations)
+}
+
+// For blue/green deploys only the destinations in the route's active set get
+// traffic, so flipping the set swaps every destination in one update
+func activeDestinations(route networkingv1alpha1.Route) ([]networkingv1alpha1.RouteDestination, error) {
+	destinations := []networkingv1alpha1.RouteDestination{}
+	for _, destination := range route.Spec.Destinations {
+		if isActiveDestination(route, destination) {
+			destinations = append(destinations, destination)
+		}
+	}
+
+	if len(destinations) == 0 {
+		msg := fmt.Sprintf(
+			"invalid destinations for route %s: no destinations in active destination set %s",
+			route.ObjectMeta.Name,
+			route.Spec.ActiveDestinationSet)
+		return nil, errors.New(msg)
+	}
+
+	return destinations, nil
+}
+
+func isActiveDestination(route networkingv1alpha1.Route, destination networkingv1alpha1.RouteDestination) bool {
+	return route.Spec.ActiveDestinationSet == "" || destination.Set == route.Spec.ActiveDestinationSet
+}
+
+// Weights are relative (e.g. 1:3), so they are scaled to percentages using the
+// largest remainder method. Destinations without weights are split evenly.
+func normalizeWeights(route networkingv1alpha1.Route, destinations []networkingv1alpha1.RouteDestination) ([]int, error) {
+	err := validateWeights(route, destinations)
+	if err != nil {
+		return nil, err
+	}
+
+	relativeWeights := make([]int, len(destinations))
+	totalWeight := 0
+	for i, d := range destinations {
+		relativeWeights[i] = 1
+		if d.Weight != nil {
+			relativeWeights[i] = *d.Weight
+		}
+		totalWeight += relativeWeights[i]
+	}
+
+	weights := make([]int, len(destinations))
+	remainders := make([]int, len(destinations))
+	allocated := 0
+	for i, relativeWeight := range relativeWeights {
+		weights[i] = relativeWeight * IstioExpectedWeight / totalWeight
+		remainders[i] = relativeWeight * IstioExpectedWeight % totalWeight
+		allocated += weights[i]
+	}
+
+	// hand out what is left to the largest remainders, earlier destinations win ties
+	order := make([]int, len(destinations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for i := 0; allocated < IstioExpectedWeight; i++ {
+		weights[order[i]]++
+		allocated++
+	}
+
+	return weights, nil
 }
 
 func validateWeights(route networkingv1alpha1.Route, destinations []networkingv1alpha1.RouteDestination) error {
@@ -261,24 +476,83 @@ func validateWeights(route networkingv1alpha1.Route, destinations []networkingv1
 		}
 
 		if d.Weight != nil {
+			if *d.Weight < 0 {
+				msg := fmt.Sprintf(
+					"invalid destinations for route %s: weights must not be negative",
+					route.ObjectMeta.Name)
+				return errors.New(msg)
+			}
 			weightSum += *d.Weight
 		}
 	}
 
 	weightsHaveBeenSet := destinations[0].Weight != nil
-	if weightsHaveBeenSet && weightSum != IstioExpectedWeight {
+	if weightsHaveBeenSet && weightSum <= 0 {
 		msg := fmt.Sprintf(
-			"invalid destinations for route %s: weights must sum up to 100",
+			"invalid destinations for route %s: weights must sum up to more than 0",
 			route.ObjectMeta.Name)
 		return errors.New(msg)
 	}
 	return nil
 }
 
+func corsPolicyToIstioCorsPolicy(policy *networkingv1alpha1.RouteCorsPolicy) *istiov1alpha3.CorsPolicy {
+	corsPolicy := &istiov1alpha3.CorsPolicy{
+		AllowMethods:  policy.AllowMethods,
+		AllowHeaders:  policy.AllowHeaders,
+		ExposeHeaders: policy.ExposeHeaders,
+	}
+
+	for _, origin := range policy.AllowOrigins {
+		corsPolicy.AllowOrigins = append(corsPolicy.AllowOrigins, corsOriginToStringMatch(origin))
+	}
+
+	if policy.AllowCredentials != nil {
+		corsPolicy.AllowCredentials = &types.BoolValue{Value: *policy.AllowCredentials}
+	}
+
+	if policy.MaxAge != nil {
+		corsPolicy.MaxAge = &types.Duration{Seconds: int64(*policy.MaxAge)}
+	}
+
+	return corsPolicy
+}
+
+// wildcard origins are matched with a regex, everything else must match exactly
+func corsOriginToStringMatch(origin string) *istiov1alpha3.StringMatch {
+	if origin == "*" {
+		return &istiov1alpha3.StringMatch{
+			MatchType: &istiov1alpha3.StringMatch_Regex{Regex: ".*"},
+		}
+	}
+
+	if strings.Contains(origin, "://*.") {
+		parts := strings.SplitN(origin, "://*.", 2)
+		regex := fmt.Sprintf("%s://[a-zA-Z0-9.-]+\\.%s", regexp.QuoteMeta(parts[0]), regexp.QuoteMeta(parts[1]))
+		return &istiov1alpha3.StringMatch{
+			MatchType: &istiov1alpha3.StringMatch_Regex{Regex: regex},
+		}
+	}
+
+	return &istiov1alpha3.StringMatch{
+		MatchType: &istiov1alpha3.StringMatch_Exact{Exact: origin},
+	}
+}
+
 func intPtr(x int) *int {
 	return &x
 }
 
+// Short service names only resolve within the VirtualService's namespace, so
+// destinations in other namespaces need the fully qualified name
+func destinationHost(route networkingv1alpha1.Route, dest networkingv1alpha1.RouteDestination) string {
+	namespace := DestinationNamespace(route, dest)
+	if namespace == route.ObjectMeta.Namespace {
+		return serviceName(dest)
+	}
+	return fmt.Sprintf("%s.%s.svc.cluster.local", serviceName(dest), namespace)
+}
+
 // service names cannot start with numbers
 func serviceName(dest networkingv1alpha1.RouteDestination) string {
 	return fmt.Sprintf("s-%s", dest.Guid)
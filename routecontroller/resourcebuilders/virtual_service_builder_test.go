@@ -1,11 +1,13 @@
 package resourcebuilders
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	istionetworkingv1alpha3 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/istio/networking/v1alpha3"
 	networkingv1alpha1 "code.cloudfoundry.org/cf-k8s-networking/routecontroller/apis/networking/v1alpha1"
+	gogotypes "github.com/gogo/protobuf/types"
 	istiov1alpha3 "istio.io/api/networking/v1alpha3"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -395,40 +397,66 @@ var _ = Describe("VirtualServiceBuilder", func() {
 				})
 
 				Context("when the weights do not sum up to 100", func() {
-					It("omits the invalid istionetworkingv1alpha3.VirtualService", func() {
-						invalidRoutes := networkingv1alpha1.RouteList{
-							Items: []networkingv1alpha1.Route{
-								constructRoute(routeParams{
-									name:   "route-guid-0",
-									host:   "invalid-route",
-									path:   "/path0",
-									domain: "domain0.example.com",
-									destinations: []routeDestParams{
-										{
-											destGUID: "route-0-destination-guid-0",
-											port:     9000,
-											weight:   intPtr(80),
-											appGUID:  "app-guid-0",
-										},
-										{
-											destGUID: "route-0-destination-guid-1",
-											port:     8080,
-											weight:   intPtr(80),
-											appGUID:  "app-guid-1",
-										},
-									},
-								}),
-							},
+					It("normalizes the relative weights into percentages", func() {
+						routes.Items[0].Spec.Destinations[0].Weight = intPtr(1)
+						routes.Items[0].Spec.Destinations[1].Weight = intPtr(3)
+						routes.Items[0].Spec.Destinations[2].Weight = intPtr(4)
+
+						builder := VirtualServiceBuilder{
+							IstioGateways: []string{"some-gateway0", "some-gateway1"},
 						}
 
-						routes.Items = append(routes.Items, invalidRoutes.Items[0])
+						virtualservices, err := builder.Build(&routes)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(virtualservices[0].Spec.Http[0].Route[0].Weight).To(Equal(int32(13)))
+						Expect(virtualservices[0].Spec.Http[0].Route[1].Weight).To(Equal(int32(37)))
+						Expect(virtualservices[0].Spec.Http[0].Route[2].Weight).To(Equal(int32(50)))
+					})
+
+					It("gives the leftover percentage to the destinations with the largest remainders", func() {
+						routes.Items[0].Spec.Destinations[0].Weight = intPtr(1)
+						routes.Items[0].Spec.Destinations[1].Weight = intPtr(2)
+						routes.Items[0].Spec.Destinations[2].Weight = intPtr(0)
+
+						builder := VirtualServiceBuilder{
+							IstioGateways: []string{"some-gateway0", "some-gateway1"},
+						}
+
+						virtualservices, err := builder.Build(&routes)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(virtualservices[0].Spec.Http[0].Route[0].Weight).To(Equal(int32(33)))
+						Expect(virtualservices[0].Spec.Http[0].Route[1].Weight).To(Equal(int32(67)))
+						Expect(virtualservices[0].Spec.Http[0].Route[2].Weight).To(Equal(int32(0)))
+					})
+				})
+
+				Context("when the weights sum up to 0", func() {
+					It("returns an error", func() {
+						routes.Items[0].Spec.Destinations[0].Weight = intPtr(0)
+						routes.Items[0].Spec.Destinations[1].Weight = intPtr(0)
+						routes.Items[0].Spec.Destinations[2].Weight = intPtr(0)
 
 						builder := VirtualServiceBuilder{
 							IstioGateways: []string{"some-gateway0", "some-gateway1"},
 						}
 
 						_, err := builder.Build(&routes)
-						Expect(err).To(MatchError("invalid destinations for route route-guid-0: weights must sum up to 100"))
+						Expect(err).To(MatchError("invalid destinations for route route-guid-0: weights must sum up to more than 0"))
+					})
+				})
+
+				Context("when a weight is negative", func() {
+					It("returns an error", func() {
+						routes.Items[0].Spec.Destinations[0].Weight = intPtr(-1)
+						routes.Items[0].Spec.Destinations[1].Weight = intPtr(3)
+						routes.Items[0].Spec.Destinations[2].Weight = intPtr(3)
+
+						builder := VirtualServiceBuilder{
+							IstioGateways: []string{"some-gateway0", "some-gateway1"},
+						}
+
+						_, err := builder.Build(&routes)
+						Expect(err).To(MatchError("invalid destinations for route route-guid-0: weights must not be negative"))
 					})
 				})
 
@@ -596,6 +624,31 @@ var _ = Describe("VirtualServiceBuilder", func() {
 					Expect(virtualservice).To(Equal(expectedVirtualServices))
 				})
 
+				It("orders the paths by their canonical url rather than a stale url", func() {
+					routes.Items[1].Spec.Url = "test0.domain0.example.com/a"
+
+					builder := VirtualServiceBuilder{
+						IstioGateways: []string{"some-gateway0", "some-gateway1"},
+					}
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(virtualservices[0].Spec.Http[0].Match[0].Uri.GetPrefix()).To(Equal("/path0/deeper"))
+					Expect(virtualservices[0].Spec.Http[1].Match[0].Uri.GetPrefix()).To(Equal("/path0"))
+				})
+
+				It("matches the normalized paths", func() {
+					routes.Items[0].Spec.Path = "/path0/"
+					routes.Items[1].Spec.Path = "/path0/%64eeper"
+
+					builder := VirtualServiceBuilder{
+						IstioGateways: []string{"some-gateway0", "some-gateway1"},
+					}
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(virtualservices[0].Spec.Http[0].Match[0].Uri.GetPrefix()).To(Equal("/path0/deeper"))
+					Expect(virtualservices[0].Spec.Http[1].Match[0].Uri.GetPrefix()).To(Equal("/path0"))
+				})
+
 				Context("and one of the routes has no destinations", func() {
 					It("sets a placeholder destination to that route", func() {
 						routes = networkingv1alpha1.RouteList{
@@ -801,11 +854,111 @@ var _ = Describe("VirtualServiceBuilder", func() {
 								},
 							}),
 						}
+						expectedVirtualServices[0].Spec.Http[0].Fault = &istiov1alpha3.HTTPFaultInjection{
+							Abort: &istiov1alpha3.HTTPFaultInjection_Abort{
+								Percentage: &istiov1alpha3.Percent{Value: 100},
+								ErrorType:  &istiov1alpha3.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 503},
+							},
+						}
 
 						virtualservice, err := builder.Build(&routes)
 						Expect(err).NotTo(HaveOccurred())
 						Expect(virtualservice).To(Equal(expectedVirtualServices))
 					})
+
+					Context("and the status code is configured as 404", func() {
+						It("aborts with a 404", func() {
+							routes = networkingv1alpha1.RouteList{
+								Items: []networkingv1alpha1.Route{
+									constructRoute(routeParams{
+										name:         "route-guid-0",
+										host:         "test0",
+										domain:       "domain0.example.com",
+										destinations: []routeDestParams{},
+									}),
+								},
+							}
+
+							builder := VirtualServiceBuilder{
+								IstioGateways:            []string{"some-gateway0", "some-gateway1"},
+								NoDestinationsStatusCode: 404,
+							}
+
+							virtualservices, err := builder.Build(&routes)
+							Expect(err).NotTo(HaveOccurred())
+
+							httpRoute := virtualservices[0].Spec.Http[0]
+							Expect(httpRoute.Fault.Abort.ErrorType).To(Equal(&istiov1alpha3.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 404}))
+							// Envoy skips the route's header operations for aborted requests
+							Expect(httpRoute.Headers).To(BeNil())
+						})
+					})
+
+					Context("and a no destinations backend is configured", func() {
+						It("routes to the backend instead of aborting", func() {
+							routes = networkingv1alpha1.RouteList{
+								Items: []networkingv1alpha1.Route{
+									constructRoute(routeParams{
+										name:         "route-guid-0",
+										host:         "test0",
+										domain:       "domain0.example.com",
+										destinations: []routeDestParams{},
+									}),
+								},
+							}
+
+							builder := VirtualServiceBuilder{
+								IstioGateways:         []string{"some-gateway0", "some-gateway1"},
+								NoDestinationsBackend: "no-app-mapped.cf-system.svc.cluster.local",
+							}
+
+							virtualservices, err := builder.Build(&routes)
+							Expect(err).NotTo(HaveOccurred())
+
+							httpRoute := virtualservices[0].Spec.Http[0]
+							Expect(httpRoute.Fault).To(BeNil())
+							Expect(httpRoute.Route).To(Equal([]*istiov1alpha3.HTTPRouteDestination{
+								{
+									Destination: &istiov1alpha3.Destination{
+										Host: "no-app-mapped.cf-system.svc.cluster.local",
+									},
+								},
+							}))
+						})
+
+						It("sets the unknown_route router error on the backend's responses", func() {
+							routes = networkingv1alpha1.RouteList{
+								Items: []networkingv1alpha1.Route{
+									constructRoute(routeParams{
+										name:         "route-guid-0",
+										host:         "test0",
+										domain:       "domain0.example.com",
+										destinations: []routeDestParams{},
+									}),
+								},
+							}
+
+							builder := VirtualServiceBuilder{
+								IstioGateways:            []string{"some-gateway0", "some-gateway1"},
+								NoDestinationsBackend:    "no-app-mapped.cf-system.svc.cluster.local",
+								NoDestinationsStatusCode: 503,
+							}
+
+							virtualservices, err := builder.Build(&routes)
+							Expect(err).NotTo(HaveOccurred())
+
+							spec, err := json.Marshal(virtualservices[0].Spec)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(spec).To(MatchJSON(`{
+								"hosts": ["test0.domain0.example.com"],
+								"gateways": ["some-gateway0", "some-gateway1"],
+								"http": [{
+									"route": [{"destination": {"host": "no-app-mapped.cf-system.svc.cluster.local"}}],
+									"headers": {"response": {"set": {"X-Cf-Routererror": "unknown_route"}}}
+								}]
+							}`))
+						})
+					})
 				})
 
 				Context("when a destination has no weight", func() {
@@ -843,58 +996,544 @@ var _ = Describe("VirtualServiceBuilder", func() {
 				})
 			})
 		})
-	})
 
-	Describe("BuildMutateFunction", func() {
-		It("builds a mutate function that copies desired state to actual resource", func() {
-			actualVirtualService := &istionetworkingv1alpha3.VirtualService{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      VirtualServiceName("test0.domain0.example.com"),
-					Namespace: "workload-namespace",
-					UID:       "some-uid",
-				},
-			}
+		Describe("cors policies", func() {
+			var (
+				routes  networkingv1alpha1.RouteList
+				builder VirtualServiceBuilder
+			)
 
-			desiredVirtualService := constructVirtualService(virtualServiceParams{
-				fqdn: "test0.domain0.example.com",
-				owners: []ownerParams{
-					{
-						routeName: "banana",
-						routeUID:  "ham-ding-er",
-					},
-				},
-				https: []httpParams{
-					{
-						matchPrefix: "/path0",
-						destinations: []destParams{
-							{
-								host:      "s-route-0-destination-guid-0",
-								appGUID:   "app-guid-0",
-								spaceGUID: "space-guid-0",
-								orgGUID:   "org-guid-0",
-								weight:    100,
+			BeforeEach(func() {
+				routes = networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "test0",
+							path:   "/path0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{
+									destGUID: "route-0-destination-guid-0",
+									port:     9000,
+									appGUID:  "app-guid-0",
+								},
+							},
+						}),
+						constructRoute(routeParams{
+							name:   "route-guid-1",
+							host:   "test0",
+							path:   "",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{
+									destGUID: "route-1-destination-guid-0",
+									port:     9000,
+									appGUID:  "app-guid-1",
+								},
 							},
+						}),
+					},
+				}
+
+				builder = VirtualServiceBuilder{
+					IstioGateways: []string{"some-gateway0", "some-gateway1"},
+				}
+			})
+
+			Context("when a route has a cors policy", func() {
+				BeforeEach(func() {
+					// credentials can't be allowed for the "*" origin
+					allowCredentials := false
+					routes.Items[0].Spec.Cors = &networkingv1alpha1.RouteCorsPolicy{
+						AllowOrigins: []string{
+							"https://app.example.com",
+							"https://*.example.org:8443",
+							"*",
 						},
+						AllowMethods:     []string{"GET", "POST"},
+						AllowHeaders:     []string{"Authorization"},
+						ExposeHeaders:    []string{"X-Request-Id"},
+						AllowCredentials: &allowCredentials,
+						MaxAge:           intPtr(600),
+					}
+				})
+
+				It("sets the cors policy on the http route for that route only", func() {
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(virtualservices).To(HaveLen(1))
+					Expect(virtualservices[0].Spec.Http).To(HaveLen(2))
+
+					Expect(virtualservices[0].Spec.Http[0].CorsPolicy).To(Equal(&istiov1alpha3.CorsPolicy{
+						AllowOrigins: []*istiov1alpha3.StringMatch{
+							{MatchType: &istiov1alpha3.StringMatch_Exact{Exact: "https://app.example.com"}},
+							{MatchType: &istiov1alpha3.StringMatch_Regex{Regex: `https://[a-zA-Z0-9.-]+\.example\.org:8443`}},
+							{MatchType: &istiov1alpha3.StringMatch_Regex{Regex: ".*"}},
+						},
+						AllowMethods:     []string{"GET", "POST"},
+						AllowHeaders:     []string{"Authorization"},
+						ExposeHeaders:    []string{"X-Request-Id"},
+						AllowCredentials: &gogotypes.BoolValue{Value: false},
+						MaxAge:           &gogotypes.Duration{Seconds: 600},
+					}))
+					Expect(virtualservices[0].Spec.Http[1].CorsPolicy).To(BeNil())
+				})
+			})
+
+			Context("when a route has an invalid cors origin", func() {
+				BeforeEach(func() {
+					routes.Items[1].Spec.Cors = &networkingv1alpha1.RouteCorsPolicy{
+						AllowOrigins: []string{"https://app.example.com/some/path"},
+					}
+				})
+
+				It("returns an error", func() {
+					_, err := builder.Build(&routes)
+					Expect(err).To(MatchError(`invalid cors policy for route route-guid-1: origin "https://app.example.com/some/path" must be "*" or of the form scheme://host[:port]`))
+				})
+			})
+
+			Context("when a route has a wildcard in the middle of a cors origin", func() {
+				BeforeEach(func() {
+					routes.Items[1].Spec.Cors = &networkingv1alpha1.RouteCorsPolicy{
+						AllowOrigins: []string{"https://app.*.example.com"},
+					}
+				})
+
+				It("returns an error", func() {
+					_, err := builder.Build(&routes)
+					Expect(err).To(MatchError(`invalid cors policy for route route-guid-1: origin "https://app.*.example.com" must be "*" or of the form scheme://host[:port]`))
+				})
+			})
+
+			Context("when a route has a negative cors max age", func() {
+				BeforeEach(func() {
+					routes.Items[1].Spec.Cors = &networkingv1alpha1.RouteCorsPolicy{
+						AllowOrigins: []string{"*"},
+						MaxAge:       intPtr(-1),
+					}
+				})
+
+				It("returns an error", func() {
+					_, err := builder.Build(&routes)
+					Expect(err).To(MatchError("invalid cors policy for route route-guid-1: max age must not be negative"))
+				})
+			})
+		})
+
+		Describe("rate limits", func() {
+			var (
+				routes  networkingv1alpha1.RouteList
+				builder VirtualServiceBuilder
+			)
+
+			BeforeEach(func() {
+				routes = networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "test0",
+							path:   "/path0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-0-destination-guid-0", port: 9000, appGUID: "app-guid-0"},
+							},
+						}),
+						constructRoute(routeParams{
+							name:   "route-guid-1",
+							host:   "test0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-1-destination-guid-0", port: 9000, appGUID: "app-guid-1"},
+							},
+						}),
 					},
-				},
+				}
+				routes.Items[0].Spec.RateLimit = &networkingv1alpha1.RouteRateLimit{RequestsPerSecond: 10}
+
+				builder = VirtualServiceBuilder{
+					IstioGateways: []string{"some-gateway0"},
+				}
 			})
 
-			Expect(len(actualVirtualService.ObjectMeta.OwnerReferences)).To(BeZero())
+			It("names the http route of rate limited routes after the route, so the EnvoyFilter can match it", func() {
+				virtualservices, err := builder.Build(&routes)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(virtualservices[0].Spec.Http).To(HaveLen(2))
 
-			builder := VirtualServiceBuilder{}
-			mutateFn := builder.BuildMutateFunction(actualVirtualService, &desiredVirtualService)
-			err := mutateFn()
-			Expect(err).NotTo(HaveOccurred())
+				Expect(virtualservices[0].Spec.Http[0].Name).To(Equal("route-guid-0"))
+				Expect(virtualservices[0].Spec.Http[1].Name).To(BeEmpty())
+			})
+
+			Context("when the route is internal", func() {
+				BeforeEach(func() {
+					routes.Items[0].Spec.Domain.Internal = true
+					routes.Items[1].Spec.Domain.Internal = true
+				})
+
+				It("leaves the http route unnamed, internal routes are not rate limited", func() {
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(virtualservices[0].Spec.Http[0].Name).To(BeEmpty())
+				})
+			})
+
+			Context("when a route has a response code Envoy can't return", func() {
+				BeforeEach(func() {
+					routes.Items[1].Spec.RateLimit = &networkingv1alpha1.RouteRateLimit{RequestsPerSecond: 10, ResponseCode: intPtr(499)}
+				})
+
+				It("returns an error", func() {
+					_, err := builder.Build(&routes)
+					Expect(err).To(MatchError("route guid route-guid-1 has rate limit response code 499, which is not a known 4xx or 5xx status code"))
+				})
+			})
+
+			Context("when a route has a source range that is neither a CIDR nor an IP", func() {
+				BeforeEach(func() {
+					routes.Items[1].Spec.DeniedSourceRanges = []string{"10.0.0.0/33"}
+				})
+
+				It("returns an error", func() {
+					_, err := builder.Build(&routes)
+					Expect(err).To(MatchError(`route guid route-guid-1 has denied source range "10.0.0.0/33", which is neither a CIDR nor an IP`))
+				})
+			})
+		})
+
+		Describe("blue/green destination sets", func() {
+			var (
+				routes  networkingv1alpha1.RouteList
+				builder VirtualServiceBuilder
+			)
+
+			BeforeEach(func() {
+				routes = networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "test0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "blue-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+								{destGUID: "blue-destination-guid-1", port: 8080, appGUID: "app-guid-0"},
+								{destGUID: "green-destination-guid-0", port: 8080, appGUID: "app-guid-1"},
+							},
+						}),
+					},
+				}
+				routes.Items[0].Spec.Destinations[0].Set = "blue"
+				routes.Items[0].Spec.Destinations[1].Set = "blue"
+				routes.Items[0].Spec.Destinations[2].Set = "green"
+
+				builder = VirtualServiceBuilder{
+					IstioGateways: []string{"some-gateway0", "some-gateway1"},
+				}
+			})
+
+			Context("when the blue set is active", func() {
+				BeforeEach(func() {
+					routes.Items[0].Spec.ActiveDestinationSet = "blue"
+				})
+
+				It("only routes to the blue destinations", func() {
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+
+					httpRoute := virtualservices[0].Spec.Http[0]
+					Expect(httpRoute.Route).To(HaveLen(2))
+					Expect(httpRoute.Route[0].Destination.Host).To(Equal("s-blue-destination-guid-0"))
+					Expect(httpRoute.Route[0].Weight).To(Equal(int32(50)))
+					Expect(httpRoute.Route[1].Destination.Host).To(Equal("s-blue-destination-guid-1"))
+					Expect(httpRoute.Route[1].Weight).To(Equal(int32(50)))
+				})
+			})
+
+			Context("when the green set is active", func() {
+				BeforeEach(func() {
+					routes.Items[0].Spec.ActiveDestinationSet = "green"
+				})
+
+				It("only routes to the green destinations", func() {
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+
+					httpRoute := virtualservices[0].Spec.Http[0]
+					Expect(httpRoute.Route).To(HaveLen(1))
+					Expect(httpRoute.Route[0].Destination.Host).To(Equal("s-green-destination-guid-0"))
+					Expect(httpRoute.Route[0].Weight).To(Equal(int32(100)))
+				})
+			})
+
+			Context("when no set is active", func() {
+				It("routes to every destination", func() {
+					virtualservices, err := builder.Build(&routes)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(virtualservices[0].Spec.Http[0].Route).To(HaveLen(3))
+				})
+			})
+
+			Context("when the active set has no destinations", func() {
+				BeforeEach(func() {
+					routes.Items[0].Spec.ActiveDestinationSet = "purple"
+				})
+
+				It("returns an error", func() {
+					_, err := builder.Build(&routes)
+					Expect(err).To(MatchError("invalid destinations for route route-guid-0: no destinations in active destination set purple"))
+				})
+			})
+		})
+
+		Context("when the CF identity headers are omitted", func() {
+			It("does not set request headers on the destinations", func() {
+				routes := networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "test0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+							},
+						}),
+					},
+				}
+
+				builder := VirtualServiceBuilder{
+					IstioGateways:         []string{"some-gateway0"},
+					OmitCFIdentityHeaders: true,
+				}
+				virtualservices, err := builder.Build(&routes)
+				Expect(err).NotTo(HaveOccurred())
+
+				httpRoute := virtualservices[0].Spec.Http[0]
+				Expect(httpRoute.Route).To(HaveLen(1))
+				Expect(httpRoute.Route[0].Headers).To(BeNil())
+			})
+		})
+
+		Context("when route labels and annotations are propagated", func() {
+			It("copies them from every route, the route matched first winning", func() {
+				routes := networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "test0",
+							path:   "/path0",
+							domain: "domain0.example.com",
+						}),
+						constructRoute(routeParams{
+							name:   "route-guid-1",
+							host:   "test0",
+							path:   "/path0/deeper",
+							domain: "domain0.example.com",
+						}),
+					},
+				}
+				routes.Items[0].ObjectMeta.Labels["team"] = "routing"
+				routes.Items[0].ObjectMeta.Annotations = map[string]string{"example.com/owner": "shorter-path"}
+				routes.Items[1].ObjectMeta.Annotations = map[string]string{
+					"example.com/owner":     "longer-path",
+					"cloudfoundry.org/fqdn": "not-the-fqdn",
+				}
+
+				builder := VirtualServiceBuilder{
+					IstioGateways: []string{"some-gateway0"},
+					Propagation: MetadataPropagation{
+						Prefixes: []string{"example.com/", "cloudfoundry.org/"},
+						Keys:     []string{"team"},
+					},
+				}
+				virtualservices, err := builder.Build(&routes)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(virtualservices).To(HaveLen(1))
+
+				Expect(virtualservices[0].ObjectMeta.Labels).To(HaveKeyWithValue("team", "routing"))
+				Expect(virtualservices[0].ObjectMeta.Labels).To(HaveKeyWithValue("cloudfoundry.org/space_guid", "space-guid-0"))
+				Expect(virtualservices[0].ObjectMeta.Annotations).To(Equal(map[string]string{
+					"cloudfoundry.org/fqdn": "test0.domain0.example.com",
+					"example.com/owner":     "longer-path",
+				}))
+			})
+		})
+
+		Context("when a destination is in another namespace", func() {
+			It("uses the fully qualified service name as the destination host", func() {
+				routes := networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "test0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+								{destGUID: "route-0-destination-guid-1", port: 8080, appGUID: "app-guid-1"},
+								{destGUID: "route-0-destination-guid-2", port: 8080, appGUID: "app-guid-2"},
+							},
+						}),
+					},
+				}
+				routes.Items[0].Spec.Destinations[1].Namespace = "other-namespace"
+				routes.Items[0].Spec.Destinations[2].Namespace = "workload-namespace"
+
+				builder := VirtualServiceBuilder{IstioGateways: []string{"some-gateway0"}}
+				virtualservices, err := builder.Build(&routes)
+				Expect(err).NotTo(HaveOccurred())
+
+				httpRoute := virtualservices[0].Spec.Http[0]
+				Expect(httpRoute.Route).To(HaveLen(3))
+				Expect(httpRoute.Route[0].Destination.Host).To(Equal("s-route-0-destination-guid-0"))
+				Expect(httpRoute.Route[1].Destination.Host).To(Equal("s-route-0-destination-guid-1.other-namespace.svc.cluster.local"))
+				Expect(httpRoute.Route[2].Destination.Host).To(Equal("s-route-0-destination-guid-2"))
+			})
+		})
 
-			Expect(actualVirtualService.ObjectMeta.Name).To(Equal(VirtualServiceName("test0.domain0.example.com")))
-			Expect(actualVirtualService.ObjectMeta.Namespace).To(Equal("workload-namespace"))
-			Expect(actualVirtualService.ObjectMeta.UID).To(Equal(types.UID("some-uid")))
-			Expect(actualVirtualService.ObjectMeta.Labels).To(Equal(desiredVirtualService.ObjectMeta.Labels))
-			Expect(actualVirtualService.ObjectMeta.Annotations).To(Equal(desiredVirtualService.ObjectMeta.Annotations))
-			Expect(len(actualVirtualService.ObjectMeta.OwnerReferences)).NotTo(BeZero())
-			Expect(actualVirtualService.ObjectMeta.OwnerReferences).To(Equal(desiredVirtualService.ObjectMeta.OwnerReferences))
-			Expect(actualVirtualService.Spec).To(Equal(desiredVirtualService.Spec))
+		Context("when hosts only differ in case or encoding", func() {
+			It("builds one VirtualService for their canonical FQDN", func() {
+				routes := networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "Bücher",
+							path:   "/path0",
+							domain: "Domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+							},
+						}),
+						constructRoute(routeParams{
+							name:   "route-guid-1",
+							host:   "xn--bcher-kva",
+							path:   "/path1",
+							domain: "domain0.example.com.",
+							destinations: []routeDestParams{
+								{destGUID: "route-1-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+							},
+						}),
+					},
+				}
+
+				builder := VirtualServiceBuilder{IstioGateways: []string{"some-gateway0"}}
+				virtualservices, err := builder.Build(&routes)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(virtualservices).To(HaveLen(1))
+				Expect(virtualservices[0].ObjectMeta.Name).To(Equal(VirtualServiceName("xn--bcher-kva.domain0.example.com")))
+				Expect(virtualservices[0].Spec.Hosts).To(ConsistOf("xn--bcher-kva.domain0.example.com"))
+				Expect(virtualservices[0].Spec.Http).To(HaveLen(2))
+			})
 		})
+
+		Context("when a route's FQDN is not a valid hostname", func() {
+			It("returns an error", func() {
+				routes := networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{name: "route-guid-0", host: strings.Repeat("a", 64), domain: "domain0.example.com"}),
+					},
+				}
+
+				builder := VirtualServiceBuilder{IstioGateways: []string{"some-gateway0"}}
+				_, err := builder.Build(&routes)
+				Expect(err).To(MatchError(ContainSubstring("must be no more than 63 characters")))
+			})
+		})
+
+		Context("when a route is a wildcard route", func() {
+			var routes networkingv1alpha1.RouteList
+
+			BeforeEach(func() {
+				routes = networkingv1alpha1.RouteList{
+					Items: []networkingv1alpha1.Route{
+						constructRoute(routeParams{
+							name:   "route-guid-0",
+							host:   "*",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-0-destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+							},
+						}),
+						constructRoute(routeParams{
+							name:   "route-guid-1",
+							host:   "test0",
+							domain: "domain0.example.com",
+							destinations: []routeDestParams{
+								{destGUID: "route-1-destination-guid-0", port: 8080, appGUID: "app-guid-1"},
+							},
+						}),
+					},
+				}
+			})
+
+			It("builds a VirtualService with a wildcard host besides the exact hosts of the domain", func() {
+				builder := VirtualServiceBuilder{IstioGateways: []string{"some-gateway0"}}
+				virtualservices, err := builder.Build(&routes)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(virtualservices).To(HaveLen(2))
+				Expect(virtualservices[0].Spec.Hosts).To(ConsistOf("*.domain0.example.com"))
+				Expect(virtualservices[0].ObjectMeta.Name).To(Equal(VirtualServiceName("*.domain0.example.com")))
+				Expect(virtualservices[1].Spec.Hosts).To(ConsistOf("test0.domain0.example.com"))
+			})
+
+			It("returns an error for hosts with a wildcard that is not the whole host", func() {
+				routes.Items[1].Spec.Host = "test*"
+
+				builder := VirtualServiceBuilder{IstioGateways: []string{"some-gateway0"}}
+				_, err := builder.Build(&routes)
+				Expect(err).To(MatchError(`route guid route-guid-1 has host "test*", wildcard routes must have the host "*"`))
+			})
+		})
+	})
+
+})
+
+var _ = Describe("DestinationWeights", func() {
+	It("returns the normalized weight of each destination", func() {
+		route := constructRoute(routeParams{
+			name:   "route-guid-0",
+			host:   "test0",
+			domain: "domain0.example.com",
+			destinations: []routeDestParams{
+				{destGUID: "destination-guid-0", port: 8080, weight: intPtr(1), appGUID: "app-guid-0"},
+				{destGUID: "destination-guid-1", port: 8080, weight: intPtr(3), appGUID: "app-guid-1"},
+			},
+		})
+
+		weights, err := DestinationWeights(route)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(weights).To(Equal([]int{25, 75}))
+	})
+
+	It("returns no traffic for destinations outside of the active destination set", func() {
+		route := constructRoute(routeParams{
+			name:   "route-guid-0",
+			host:   "test0",
+			domain: "domain0.example.com",
+			destinations: []routeDestParams{
+				{destGUID: "destination-guid-0", port: 8080, appGUID: "app-guid-0"},
+				{destGUID: "destination-guid-1", port: 8080, appGUID: "app-guid-1"},
+			},
+		})
+		route.Spec.Destinations[0].Set = "blue"
+		route.Spec.Destinations[1].Set = "green"
+		route.Spec.ActiveDestinationSet = "green"
+
+		weights, err := DestinationWeights(route)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(weights).To(Equal([]int{0, 100}))
+	})
+
+	It("returns no weights for a route without destinations", func() {
+		route := constructRoute(routeParams{
+			name:   "route-guid-0",
+			host:   "test0",
+			domain: "domain0.example.com",
+		})
+
+		weights, err := DestinationWeights(route)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(weights).To(BeEmpty())
 	})
 })
 
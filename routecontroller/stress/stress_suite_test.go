@@ -42,11 +42,36 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(session).Should(gexec.Exit(0))
 
+	// Deploy RouteRollout CRD
+	session, err = kubectl.Run("apply", "-f", "../../config/crd/networking.cloudfoundry.org_routerollouts.yaml")
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(session).Should(gexec.Exit(0))
+
+	// Deploy RouteReferenceGrant CRD
+	session, err = kubectl.Run("apply", "-f", "../../config/crd/networking.cloudfoundry.org_routereferencegrants.yaml")
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(session).Should(gexec.Exit(0))
+
 	// Deploy Istio's Virtual Service CRD
 	session, err = kubectl.Run("apply", "-f", "../integration/fixtures/istio-virtual-service.yaml")
 	Expect(err).NotTo(HaveOccurred())
 	Eventually(session).Should(gexec.Exit(0))
 
+	// Deploy Istio's Service Entry CRD
+	session, err = kubectl.Run("apply", "-f", "../integration/fixtures/istio-service-entry.yaml")
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(session).Should(gexec.Exit(0))
+
+	// Deploy Istio's Envoy Filter CRD
+	session, err = kubectl.Run("apply", "-f", "../integration/fixtures/istio-envoy-filter.yaml")
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(session).Should(gexec.Exit(0))
+
+	// Deploy Istio's Authorization Policy CRD
+	session, err = kubectl.Run("apply", "-f", "../integration/fixtures/istio-authorization-policy.yaml")
+	Expect(err).NotTo(HaveOccurred())
+	Eventually(session).Should(gexec.Exit(0))
+
 	// Add service to reach routecontroller's metrics
 	session, err = kubectl.Run("apply", "-f", "fixtures/service.yml")
 	Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,85 @@
+package acceptance_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cloudfoundry-incubator/cf-test-helpers/cf"
+	"github.com/cloudfoundry-incubator/cf-test-helpers/generator"
+	"github.com/onsi/gomega/gexec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// Internal routes get a ServiceEntry without addresses, which apps can only
+// resolve when the mesh has DNS capture and auto allocation enabled, see
+// doc/internal-routes.md
+var _ = Describe("Internal routes", func() {
+	const internalDomain = "apps.internal"
+
+	var (
+		app1name string
+		app2name string
+		domain   string
+		client   *http.Client
+	)
+
+	BeforeEach(func() {
+		app1name = generator.PrefixedRandomName("ACCEPTANCE", "proxy1")
+		app2name = generator.PrefixedRandomName("ACCEPTANCE", "proxy2")
+
+		_ = pushProxy(app1name)
+		_ = pushProxy(app2name)
+
+		session := cf.Cf("map-route", app2name, internalDomain, "--hostname", app2name)
+		Expect(session.Wait(TestConfig.DefaultTimeoutDuration())).To(gexec.Exit(0), "expected cf map-route to succeed")
+
+		domain = globals.AppsDomain
+
+		tr := &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+		client = &http.Client{Transport: tr}
+	})
+
+	AfterEach(func() {
+		session1 := cf.Cf("delete", app1name, "-f", "-r")
+		session2 := cf.Cf("delete", app2name, "-f", "-r")
+		Expect(session1.Wait(TestConfig.DefaultTimeoutDuration())).To(gexec.Exit(0), "expected cf delete to succeed")
+		Expect(session2.Wait(TestConfig.DefaultTimeoutDuration())).To(gexec.Exit(0), "expected cf delete to succeed")
+	})
+
+	It("runs on a mesh with DNS capture and auto allocation", func() {
+		output, err := kubectl.Run("-n", "istio-system", "get", "configmap", "istio", "-o", "jsonpath={.data.mesh}")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(output)).To(MatchRegexp(`ISTIO_META_DNS_CAPTURE:\s+"true"`))
+		Expect(string(output)).To(MatchRegexp(`ISTIO_META_DNS_AUTO_ALLOCATE:\s+"true"`))
+	})
+
+	It("resolves the internal route from another app", func() {
+		internalRoute := fmt.Sprintf("%s.%s:8080", app2name, internalDomain)
+		route := fmt.Sprintf("http://%s.%s/proxy/%s", app1name, domain, url.QueryEscape(internalRoute))
+
+		// the ServiceEntry reaches the sidecars shortly after the route is mapped.
+		// Once the FQDN resolves, the app either answers or network policies
+		// refuse the connection, but the lookup no longer fails.
+		Eventually(func() string {
+			fmt.Printf("Attempting to reach %s", route)
+			resp, err := client.Get(route)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			buf := new(bytes.Buffer)
+			_, err = buf.ReadFrom(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return buf.String()
+		}).Should(And(
+			MatchRegexp("ListenAddresses|connect error"),
+			Not(MatchRegexp("no such host")),
+		))
+	})
+})